@@ -0,0 +1,17 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+// AllocStrategy tells Allocate which free prefix of a pool to take.
+type AllocStrategy byte
+
+const (
+	// FirstFit takes lowest free prefix.
+	FirstFit AllocStrategy = iota
+	// BestFit takes lowest prefix of the smallest gap it fits in, keeping
+	// large gaps for large allocations.
+	BestFit
+	// Sparse takes prefix in the middle of the largest gap, spreading
+	// allocations over the pool to leave room for them to grow.
+	Sparse
+)
@@ -0,0 +1,81 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i alloc160.go -o alloc_auto.go
+
+// Allocate finds free prefix of given length inside pool ip/mask, sets it
+// to value and returns it. Every entry inside the pool is a reservation
+// that is never allocated over, entries for the pool itself or for its
+// supernets are not. ErrPoolExhausted is returned if nothing fits.
+func (t *Trie160) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix160, error) {
+	if err := checkKey(ip, mask, MAXBITS); err != nil {
+		return Prefix160{}, err
+	}
+	if length > MAXBITS {
+		return Prefix160{}, ErrPrefixTooLong
+	}
+	if length < mask {
+		return Prefix160{}, ErrPoolExhausted
+	}
+	var (
+		gap   Prefix160
+		found bool
+	)
+	t.node.gaps(ip, mask, length, func(p Prefix160) bool {
+		switch {
+		case !found:
+			gap, found = p, true
+			return strategy != FirstFit
+		case strategy == BestFit && p.prefixlen > gap.prefixlen:
+			gap = p
+		case strategy == Sparse && p.prefixlen < gap.prefixlen:
+			gap = p
+		}
+		return true
+	})
+	if !found {
+		return Prefix160{}, ErrPoolExhausted
+	}
+	if strategy == Sparse && gap.prefixlen < length {
+		gap = gap.half(1)
+	}
+	gap.prefixlen = length
+	if _, _, err := t.Append(gap.IP(), length, value); err != nil {
+		return Prefix160{}, err
+	}
+	return gap, nil
+}
+
+// Release gives prefix returned by Allocate back to its pool, it is Remove
+// under the name that pairs with Allocate.
+func (t *Trie160) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate sets value for free prefix of a pool, see Trie160.Allocate.
+func (t *TypedTrie160[V]) Allocate(ip []byte, mask, length byte, value V, strategy AllocStrategy) (Prefix160, error) {
+	return t.trie.Allocate(ip, mask, length, boxValue(value), strategy)
+}
+
+// Release gives allocated prefix back and returns its value.
+func (t *TypedTrie160[V]) Release(ip []byte, mask byte) (bool, V) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds and sets free prefix holding write lock, so concurrent
+// callers never get same prefix.
+func (t *SyncTrie160) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix160, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Allocate(ip, mask, length, value, strategy)
+}
+
+func (t *SyncTrie160) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
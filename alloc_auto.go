@@ -0,0 +1,220 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// Allocate finds free prefix of given length inside pool ip/mask, sets it
+// to value and returns it. Every entry inside the pool is a reservation
+// that is never allocated over, entries for the pool itself or for its
+// supernets are not. ErrPoolExhausted is returned if nothing fits.
+func (t *Trie32) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix32, error) {
+	if err := checkKey(ip, mask, 32); err != nil {
+		return Prefix32{}, err
+	}
+	if length > 32 {
+		return Prefix32{}, ErrPrefixTooLong
+	}
+	if length < mask {
+		return Prefix32{}, ErrPoolExhausted
+	}
+	var (
+		gap   Prefix32
+		found bool
+	)
+	t.node.gaps(ip, mask, length, func(p Prefix32) bool {
+		switch {
+		case !found:
+			gap, found = p, true
+			return strategy != FirstFit
+		case strategy == BestFit && p.prefixlen > gap.prefixlen:
+			gap = p
+		case strategy == Sparse && p.prefixlen < gap.prefixlen:
+			gap = p
+		}
+		return true
+	})
+	if !found {
+		return Prefix32{}, ErrPoolExhausted
+	}
+	if strategy == Sparse && gap.prefixlen < length {
+		gap = gap.half(1)
+	}
+	gap.prefixlen = length
+	if _, _, err := t.Append(gap.IP(), length, value); err != nil {
+		return Prefix32{}, err
+	}
+	return gap, nil
+}
+
+// Release gives prefix returned by Allocate back to its pool, it is Remove
+// under the name that pairs with Allocate.
+func (t *Trie32) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate sets value for free prefix of a pool, see Trie32.Allocate.
+func (t *TypedTrie32[V]) Allocate(ip []byte, mask, length byte, value V, strategy AllocStrategy) (Prefix32, error) {
+	return t.trie.Allocate(ip, mask, length, boxValue(value), strategy)
+}
+
+// Release gives allocated prefix back and returns its value.
+func (t *TypedTrie32[V]) Release(ip []byte, mask byte) (bool, V) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds and sets free prefix holding write lock, so concurrent
+// callers never get same prefix.
+func (t *SyncTrie32) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Allocate(ip, mask, length, value, strategy)
+}
+
+func (t *SyncTrie32) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds free prefix of given length inside pool ip/mask, sets it
+// to value and returns it. Every entry inside the pool is a reservation
+// that is never allocated over, entries for the pool itself or for its
+// supernets are not. ErrPoolExhausted is returned if nothing fits.
+func (t *Trie64) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix64, error) {
+	if err := checkKey(ip, mask, 64); err != nil {
+		return Prefix64{}, err
+	}
+	if length > 64 {
+		return Prefix64{}, ErrPrefixTooLong
+	}
+	if length < mask {
+		return Prefix64{}, ErrPoolExhausted
+	}
+	var (
+		gap   Prefix64
+		found bool
+	)
+	t.node.gaps(ip, mask, length, func(p Prefix64) bool {
+		switch {
+		case !found:
+			gap, found = p, true
+			return strategy != FirstFit
+		case strategy == BestFit && p.prefixlen > gap.prefixlen:
+			gap = p
+		case strategy == Sparse && p.prefixlen < gap.prefixlen:
+			gap = p
+		}
+		return true
+	})
+	if !found {
+		return Prefix64{}, ErrPoolExhausted
+	}
+	if strategy == Sparse && gap.prefixlen < length {
+		gap = gap.half(1)
+	}
+	gap.prefixlen = length
+	if _, _, err := t.Append(gap.IP(), length, value); err != nil {
+		return Prefix64{}, err
+	}
+	return gap, nil
+}
+
+// Release gives prefix returned by Allocate back to its pool, it is Remove
+// under the name that pairs with Allocate.
+func (t *Trie64) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate sets value for free prefix of a pool, see Trie64.Allocate.
+func (t *TypedTrie64[V]) Allocate(ip []byte, mask, length byte, value V, strategy AllocStrategy) (Prefix64, error) {
+	return t.trie.Allocate(ip, mask, length, boxValue(value), strategy)
+}
+
+// Release gives allocated prefix back and returns its value.
+func (t *TypedTrie64[V]) Release(ip []byte, mask byte) (bool, V) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds and sets free prefix holding write lock, so concurrent
+// callers never get same prefix.
+func (t *SyncTrie64) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Allocate(ip, mask, length, value, strategy)
+}
+
+func (t *SyncTrie64) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds free prefix of given length inside pool ip/mask, sets it
+// to value and returns it. Every entry inside the pool is a reservation
+// that is never allocated over, entries for the pool itself or for its
+// supernets are not. ErrPoolExhausted is returned if nothing fits.
+func (t *Trie128) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix128, error) {
+	if err := checkKey(ip, mask, 128); err != nil {
+		return Prefix128{}, err
+	}
+	if length > 128 {
+		return Prefix128{}, ErrPrefixTooLong
+	}
+	if length < mask {
+		return Prefix128{}, ErrPoolExhausted
+	}
+	var (
+		gap   Prefix128
+		found bool
+	)
+	t.node.gaps(ip, mask, length, func(p Prefix128) bool {
+		switch {
+		case !found:
+			gap, found = p, true
+			return strategy != FirstFit
+		case strategy == BestFit && p.prefixlen > gap.prefixlen:
+			gap = p
+		case strategy == Sparse && p.prefixlen < gap.prefixlen:
+			gap = p
+		}
+		return true
+	})
+	if !found {
+		return Prefix128{}, ErrPoolExhausted
+	}
+	if strategy == Sparse && gap.prefixlen < length {
+		gap = gap.half(1)
+	}
+	gap.prefixlen = length
+	if _, _, err := t.Append(gap.IP(), length, value); err != nil {
+		return Prefix128{}, err
+	}
+	return gap, nil
+}
+
+// Release gives prefix returned by Allocate back to its pool, it is Remove
+// under the name that pairs with Allocate.
+func (t *Trie128) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate sets value for free prefix of a pool, see Trie128.Allocate.
+func (t *TypedTrie128[V]) Allocate(ip []byte, mask, length byte, value V, strategy AllocStrategy) (Prefix128, error) {
+	return t.trie.Allocate(ip, mask, length, boxValue(value), strategy)
+}
+
+// Release gives allocated prefix back and returns its value.
+func (t *TypedTrie128[V]) Release(ip []byte, mask byte) (bool, V) {
+	return t.Remove(ip, mask)
+}
+
+// Allocate finds and sets free prefix holding write lock, so concurrent
+// callers never get same prefix.
+func (t *SyncTrie128) Allocate(ip []byte, mask, length byte, value unsafe.Pointer, strategy AllocStrategy) (Prefix128, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Allocate(ip, mask, length, value, strategy)
+}
+
+func (t *SyncTrie128) Release(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	return t.Remove(ip, mask)
+}
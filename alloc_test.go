@@ -0,0 +1,104 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestTrieAllocate(t *testing.T) {
+	pool := []byte{10, 20, 0, 0}
+	allocate := func(T *TypedTrie32[string], n int, length byte, strategy AllocStrategy) []string {
+		var res []string
+		for i := 0; i < n; i++ {
+			p, err := T.Allocate(pool, 24, length, "x", strategy)
+			if err != nil {
+				res = append(res, err.Error())
+				continue
+			}
+			res = append(res, p.String())
+		}
+		return res
+	}
+	var tests = []struct {
+		strategy AllocStrategy
+		want     []string
+	}{
+		{FirstFit, []string{"10.20.0.64/27", "10.20.0.96/27", "10.20.0.160/27"}},
+		{BestFit, []string{"10.20.0.160/27", "10.20.0.64/27", "10.20.0.96/27"}},
+		{Sparse, []string{"10.20.0.96/27", "10.20.0.224/27", "10.20.0.64/27"}},
+	}
+	for _, tst := range tests {
+		// pool is an entry inside a supernet, neither counts as reservation
+		T := mkTrie32("10.0.0.0/8", "site", "10.20.0.0/24", "pool", "10.20.0.0/26", "gw", "10.20.0.128/27", "rsv")
+		if got := allocate(T, 3, 27, tst.strategy); !slices.Equal(got, tst.want) {
+			t.Errorf("Strategy %d: expected\n%v\ngot\n%v", tst.strategy, tst.want, got)
+		}
+		if got := allocate(T, 1, 25, tst.strategy); got[0] != ErrPoolExhausted.Error() {
+			t.Errorf("Strategy %d: expected pool to have no /25, got %v", tst.strategy, got)
+		}
+	}
+
+	T := new(TypedTrie32[string])
+	got := allocate(T, 3, 26, Sparse)
+	if want := []string{"10.20.0.128/26", "10.20.0.64/26", "10.20.0.0/26"}; !slices.Equal(got, want) {
+		t.Errorf("Sparse in empty pool: expected\n%v\ngot\n%v", want, got)
+	}
+	if ok, value := T.Release([]byte{10, 20, 0, 64}, 26); !ok || value != "x" {
+		t.Error("Unable to release 10.20.0.64/26")
+	}
+	if p, _ := T.Allocate(pool, 24, 26, "y", FirstFit); p.String() != "10.20.0.64/26" {
+		t.Errorf("Expected released prefix to be reused, got %v", p)
+	}
+	if _, err := T.Allocate(pool, 24, 23, "y", FirstFit); err != ErrPoolExhausted {
+		t.Errorf("Expected ErrPoolExhausted for prefix larger than pool, got %v", err)
+	}
+}
+
+func TestTrieAllocatePoolKey(t *testing.T) {
+	T := new(TypedTrie32[string])
+	// key needs to be long enough for the pool only, bits past its mask
+	// are ignored
+	for _, key := range [][]byte{{10, 20}, {10, 20, 5}, {10, 20, 5, 7}} {
+		p, err := T.Allocate(key, 16, 24, "x", FirstFit)
+		if err != nil {
+			t.Errorf("Pool key %v: %v", key, err)
+			continue
+		}
+		T.Release(p.IP(), p.Bits())
+		if p.String() != "10.20.0.0/24" {
+			t.Errorf("Pool key %v: expected 10.20.0.0/24, got %v", key, p)
+		}
+	}
+	if _, err := T.Allocate([]byte{10}, 16, 24, "x", FirstFit); err != ErrKeyTooShort {
+		t.Errorf("Expected ErrKeyTooShort for short pool key, got %v", err)
+	}
+	if _, err := T.Allocate([]byte{10, 20, 0, 0}, 33, 33, "x", FirstFit); err != ErrPrefixTooLong {
+		t.Errorf("Expected ErrPrefixTooLong for pool mask, got %v", err)
+	}
+	if _, err := T.Allocate([]byte{10, 20, 0, 0}, 16, 33, "x", FirstFit); err != ErrPrefixTooLong {
+		t.Errorf("Expected ErrPrefixTooLong for allocation length, got %v", err)
+	}
+}
+
+func TestSyncTrieAllocate(t *testing.T) {
+	T := new(SyncTrie32)
+	done := make(chan netip.Prefix)
+	for i := 0; i < 16; i++ {
+		go func() {
+			p, err := T.Allocate([]byte{192, 168, 0, 0}, 24, 28, nil, FirstFit)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- prefix4(p.IP(), p.Bits())
+		}()
+	}
+	seen := make(map[netip.Prefix]bool)
+	for i := 0; i < 16; i++ {
+		p := <-done
+		if seen[p] {
+			t.Errorf("Prefix %v allocated twice", p)
+		}
+		seen[p] = true
+	}
+}
@@ -0,0 +1,57 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+func TestTrieClone(t *testing.T) {
+	var T = new(Trie128)
+	var values [3]int
+	T.MustSet([]byte{0x20, 1, 0xd, 0xb8}, 32, unsafe.Pointer(&values[0]))
+	T.MustSet([]byte{0x20, 1, 0xd, 0xb8, 0, 1}, 48, unsafe.Pointer(&values[1]))
+	T.MustSet([]byte{0x20, 1, 0xd, 0xb8, 0, 2}, 48, unsafe.Pointer(&values[2]))
+	T.Remove([]byte{0x20, 1, 0xd, 0xb8}, 32) // leaves a dummy behind
+
+	C := T.Clone(nil)
+	if C.Stats().Dummies != 1 || C.Len() != 2 {
+		t.Errorf("Clone has different structure: %+v", C.Stats())
+	}
+	var orig, copied []*Node128
+	T.Root().Drill(func(n *Node128) { orig = append(orig, n) })
+	C.Root().Drill(func(n *Node128) { copied = append(copied, n) })
+	if len(orig) != len(copied) {
+		t.Fatal("Clone has different number of nodes")
+	}
+	for i := range orig {
+		if orig[i] == copied[i] || orig[i].Prefix() != copied[i].Prefix() || orig[i].IsDummy() != copied[i].IsDummy() || orig[i].Data() != copied[i].Data() {
+			t.Errorf("Node %d differs or is shared: %v %v", i, orig[i].Prefix(), copied[i].Prefix())
+		}
+	}
+
+	// what-if change does not touch original
+	C.MustSet([]byte{0x20, 1, 0xd, 0xb8}, 32, nil)
+	C.Remove([]byte{0x20, 1, 0xd, 0xb8, 0, 1}, 48)
+	if _, _, ln, _ := T.Get([]byte{0x20, 1, 0xd, 0xb8, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, 128); ln != 48 {
+		t.Error("Original lost 2001:db8:1::/48, got match /", ln)
+	}
+	if T.Len() != 2 || C.Len() != 2 || C.Stats().Dummies != 0 {
+		t.Errorf("Counters got mixed: original %+v, clone %+v", T.Stats(), C.Stats())
+	}
+}
+
+func TestTypedTrieClone(t *testing.T) {
+	var T = new(TypedTrie32[[]string])
+	T.Insert(netip.MustParsePrefix("10.0.0.0/8"), []string{"a"})
+	C := T.Clone(func(v []string) []string { return append([]string(nil), v...) })
+	_, v, _, _ := C.Lookup(netip.MustParseAddr("10.1.1.1"))
+	v[0] = "b"
+	if _, v, _, _ = T.Lookup(netip.MustParseAddr("10.1.1.1")); v[0] != "a" {
+		t.Error("Value was not copied")
+	}
+	C.Insert(netip.MustParsePrefix("10.1.0.0/16"), nil)
+	if T.Len() != 1 || C.Len() != 2 {
+		t.Error("Clone counters are not its own", T.Len(), C.Len())
+	}
+}
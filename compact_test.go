@@ -0,0 +1,63 @@
+package iptrie
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTrieReuseNodes(t *testing.T) {
+	var T = new(Trie32)
+	var value int
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 64; i++ {
+			T.MustSet([]byte{10, byte(round), byte(i), 0}, 24, unsafe.Pointer(&value))
+		}
+		for i := 0; i < 64; i++ {
+			if ok, v := T.Remove([]byte{10, byte(round), byte(i), 0}, 24); !ok || v != unsafe.Pointer(&value) {
+				t.Fatal("Unable to remove", round, i)
+			}
+		}
+	}
+	s := T.Stats()
+	if T.Root() != nil || s.Entries != 0 || s.Dummies != 0 {
+		t.Errorf("Trie should be empty, got %+v", s)
+	}
+	// one round needs 64 entries and 63 dummies, rest comes from free list
+	if s.Pooled > 127+20 {
+		t.Error("Removed nodes were not reused, pooled nodes:", s.Pooled)
+	}
+}
+
+func TestTrieCompact(t *testing.T) {
+	var T = new(Trie32)
+	var values [256]int
+	for i := range values {
+		T.MustSet([]byte{10, byte(i), 0, 0}, 16, unsafe.Pointer(&values[i]))
+		T.MustSet([]byte{10, byte(i), 1, 0}, 24, nil)
+	}
+	for i := range values {
+		T.Remove([]byte{10, byte(i), 1, 0}, 24)
+	}
+	before := T.Stats()
+	T.Compact()
+	after := T.Stats()
+	if after.Pooled != 0 || after.Entries != before.Entries || after.Dummies != before.Dummies || after.MaxDepth != before.MaxDepth {
+		t.Errorf("Compact changed structure: before %+v, after %+v", before, after)
+	}
+	for i := range values {
+		_, _, ln, value := T.Get([]byte{10, byte(i), 1, 1}, 32)
+		if ln != 16 || value != unsafe.Pointer(&values[i]) {
+			t.Error("Wrong match after compact for", i, ln)
+		}
+	}
+	// compacted trie still works as usual
+	T.MustSet([]byte{10, 0, 1, 0}, 24, nil)
+	if ok, _ := T.Remove([]byte{10, 1, 0, 0}, 16); !ok || T.Len() != 256 {
+		t.Error("Unexpected entries after changing compacted trie:", T.Len())
+	}
+	var empty Trie32
+	empty.Compact()
+	if empty.Root() != nil {
+		t.Error("Compact of empty trie created a root")
+	}
+}
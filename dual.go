@@ -0,0 +1,191 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"net/netip"
+)
+
+// MappedPolicy tells DualTrie what to do with IPv4-mapped IPv6 addresses
+// (::ffff:a.b.c.d).
+type MappedPolicy byte
+
+const (
+	// MappedAsIPv4 unmaps them, ::ffff:1.2.3.0/120 is the same as 1.2.3.0/24.
+	// Mapped prefixes shorter than /96 can't be unmapped and stay IPv6.
+	MappedAsIPv4 MappedPolicy = iota
+	// MappedAsIPv6 keeps them in IPv6 trie apart from IPv4 entries.
+	MappedAsIPv6
+)
+
+// DualTrie keeps IPv4 and IPv6 prefixes behind one API. Everything that
+// goes over all entries visits IPv4 first and IPv6 second.
+type DualTrie[V any] struct {
+	v4     TypedTrie32[V]
+	v6     TypedTrie128[V]
+	mapped MappedPolicy
+}
+
+// NewDualTrie returns empty trie using policy for IPv4-mapped addresses.
+// Zero DualTrie is ready to use with MappedAsIPv4.
+func NewDualTrie[V any](policy MappedPolicy) *DualTrie[V] {
+	return &DualTrie[V]{mapped: policy}
+}
+
+// Policy returns how IPv4-mapped IPv6 addresses are handled.
+func (t *DualTrie[V]) Policy() MappedPolicy {
+	return t.mapped
+}
+
+// V4 gives access to IPv4 part of the trie.
+func (t *DualTrie[V]) V4() *TypedTrie32[V] {
+	return &t.v4
+}
+
+// V6 gives access to IPv6 part of the trie.
+func (t *DualTrie[V]) V6() *TypedTrie128[V] {
+	return &t.v6
+}
+
+// unmap applies mapped policy, result is either IPv4 or IPv6 prefix.
+func (t *DualTrie[V]) unmap(p netip.Prefix) netip.Prefix {
+	if t.mapped == MappedAsIPv4 && p.Addr().Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p
+}
+
+// Insert sets value for prefix p replacing previous one.
+func (t *DualTrie[V]) Insert(p netip.Prefix, value V) error {
+	if !p.IsValid() {
+		return ErrInvalidPrefix
+	}
+	if p = t.unmap(p); p.Addr().Is4() {
+		return t.v4.Insert(p, value)
+	}
+	return t.v6.Insert(p, value)
+}
+
+// Delete removes prefix p, it returns false if p was not in the trie.
+func (t *DualTrie[V]) Delete(p netip.Prefix) (bool, error) {
+	if !p.IsValid() {
+		return false, ErrInvalidPrefix
+	}
+	if p = t.unmap(p); p.Addr().Is4() {
+		return t.v4.Delete(p)
+	}
+	return t.v6.Delete(p)
+}
+
+// Lookup finds most specific prefix containing addr. With MappedAsIPv4
+// match for IPv4-mapped address is an IPv4 prefix.
+func (t *DualTrie[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool, error) {
+	return t.LookupPrefix(hostPrefix(addr))
+}
+
+// LookupPrefix finds p itself or most specific prefix containing it.
+func (t *DualTrie[V]) LookupPrefix(p netip.Prefix) (netip.Prefix, V, bool, error) {
+	if !p.IsValid() {
+		var zero V
+		return netip.Prefix{}, zero, false, ErrInvalidPrefix
+	}
+	if p = t.unmap(p); p.Addr().Is4() {
+		return t.v4.LookupPrefix(p)
+	}
+	return t.v6.LookupPrefix(p)
+}
+
+// All iterates over IPv4 entries and then IPv6 ones, both in ascending order.
+func (t *DualTrie[V]) All() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		for p, v := range t.v4.All() {
+			if !yield(prefix4(p.IP(), p.Bits()), v) {
+				return
+			}
+		}
+		for p, v := range t.v6.All() {
+			if !yield(prefix6(p.IP(), p.Bits()), v) {
+				return
+			}
+		}
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *DualTrie[V]) Backward() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		for p, v := range t.v6.Backward() {
+			if !yield(prefix6(p.IP(), p.Bits()), v) {
+				return
+			}
+		}
+		for p, v := range t.v4.Backward() {
+			if !yield(prefix4(p.IP(), p.Bits()), v) {
+				return
+			}
+		}
+	}
+}
+
+// Walk calls f for every entry in same order as All.
+func (t *DualTrie[V]) Walk(f func(netip.Prefix, V)) {
+	for p, v := range t.All() {
+		f(p, v)
+	}
+}
+
+// Len returns number of entries in both families.
+func (t *DualTrie[V]) Len() int {
+	return t.v4.Len() + t.v6.Len()
+}
+
+// SetCodec sets codec for values of both families, build it with TypedCodec.
+func (t *DualTrie[V]) SetCodec(codec ValueCodec) {
+	t.v4.SetCodec(codec)
+	t.v6.SetCodec(codec)
+}
+
+// WriteTo writes mapped policy byte followed by IPv4 and IPv6 snapshots.
+func (t *DualTrie[V]) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write([]byte{byte(t.mapped)})
+	if err != nil {
+		return int64(n), err
+	}
+	n4, err := t.v4.WriteTo(w)
+	if err != nil {
+		return int64(n) + n4, err
+	}
+	n6, err := t.v6.WriteTo(w)
+	return int64(n) + n4 + n6, err
+}
+
+// ReadFrom replaces content and policy of the trie with what WriteTo wrote.
+// Trie stays as it was unless both snapshots are good.
+func (t *DualTrie[V]) ReadFrom(r io.Reader) (int64, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	policy, err := br.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if MappedPolicy(policy) > MappedAsIPv6 {
+		return 1, ErrSnapshotFormat
+	}
+	c := DualTrie[V]{mapped: MappedPolicy(policy)}
+	c.v4.trie.codec, c.v6.trie.codec = t.v4.trie.codec, t.v6.trie.codec
+	n4, err := c.v4.ReadFrom(br)
+	if err != nil {
+		return 1 + n4, err
+	}
+	n6, err := c.v6.ReadFrom(br)
+	if err != nil {
+		return 1 + n4 + n6, err
+	}
+	*t = c
+	return 1 + n4 + n6, nil
+}
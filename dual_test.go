@@ -0,0 +1,99 @@
+package iptrie
+
+import (
+	"bytes"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDualTrie(t *testing.T) {
+	for _, policy := range []MappedPolicy{MappedAsIPv4, MappedAsIPv6} {
+		T := NewDualTrie[string](policy)
+		for _, s := range []string{"2001:db8::/32", "10.0.0.0/8", "::ffff:10.1.0.0/112", "10.1.2.0/24", "::/0"} {
+			if err := T.Insert(netip.MustParsePrefix(s), s); err != nil {
+				t.Error("Unable to insert", s, err)
+			}
+		}
+
+		var walked []string
+		T.Walk(func(p netip.Prefix, v string) {
+			walked = append(walked, p.String()+"="+v)
+		})
+		want := "10.0.0.0/8=10.0.0.0/8 10.1.0.0/16=::ffff:10.1.0.0/112 10.1.2.0/24=10.1.2.0/24 ::/0=::/0 2001:db8::/32=2001:db8::/32"
+		if policy == MappedAsIPv6 {
+			want = "10.0.0.0/8=10.0.0.0/8 10.1.2.0/24=10.1.2.0/24 ::/0=::/0 ::ffff:10.1.0.0/112=::ffff:10.1.0.0/112 2001:db8::/32=2001:db8::/32"
+		}
+		if got := strings.Join(walked, " "); got != want {
+			t.Errorf("Policy %d: expected walk\n%s\ngot\n%s", policy, want, got)
+		}
+		if T.Len() != 5 {
+			t.Errorf("Policy %d: expected 5 entries, got %d", policy, T.Len())
+		}
+
+		match, _, _, err := T.Lookup(netip.MustParseAddr("::ffff:10.1.3.4"))
+		if policy == MappedAsIPv4 && (err != nil || match.String() != "10.1.0.0/16") {
+			t.Errorf("Expected mapped address to match 10.1.0.0/16, got %s (%v)", match, err)
+		}
+		if policy == MappedAsIPv6 && (err != nil || match.String() != "::ffff:10.1.0.0/112") {
+			t.Errorf("Expected mapped address to match ::ffff:10.1.0.0/112, got %s (%v)", match, err)
+		}
+		match, _, _, _ = T.Lookup(netip.MustParseAddr("10.1.3.4"))
+		if policy == MappedAsIPv4 && match.String() != "10.1.0.0/16" {
+			t.Errorf("Expected 10.1.0.0/16 for 10.1.3.4, got %s", match)
+		}
+		if policy == MappedAsIPv6 && match.String() != "10.0.0.0/8" {
+			t.Errorf("Expected 10.0.0.0/8 for 10.1.3.4, got %s", match)
+		}
+		if match, _, _, _ = T.Lookup(netip.MustParseAddr("2001:db9::1")); match.String() != "::/0" {
+			t.Errorf("Expected ::/0 for 2001:db9::1, got %s", match)
+		}
+	}
+}
+
+func TestDualTrieSnapshot(t *testing.T) {
+	D := NewDualTrie[string](MappedAsIPv6)
+	for _, s := range []string{"2001:db8::/32", "10.0.0.0/8", "::ffff:10.1.0.0/112"} {
+		D.Insert(netip.MustParsePrefix(s), s)
+	}
+	D.SetCodec(stringCodec)
+	var buf bytes.Buffer
+	if _, err := D.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	R := new(DualTrie[string])
+	R.SetCodec(stringCodec)
+	if n, err := R.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+		t.Fatal("Unable to read snapshot", n, err)
+	}
+	var got []string
+	for p, v := range R.All() {
+		got = append(got, p.String()+"="+v)
+	}
+	want := []string{"10.0.0.0/8=10.0.0.0/8", "::ffff:10.1.0.0/112=::ffff:10.1.0.0/112", "2001:db8::/32=2001:db8::/32"}
+	if !slices.Equal(got, want) || R.Len() != 3 {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if R.Policy() != MappedAsIPv6 {
+		t.Error("Mapped policy was not restored")
+	}
+	if _, v, ok, err := R.Lookup(netip.MustParseAddr("::ffff:10.1.2.3")); err != nil || !ok || v != "::ffff:10.1.0.0/112" {
+		t.Errorf("Unexpected lookup of mapped address: %q", v)
+	}
+
+	// broken IPv6 part leaves trie untouched
+	for _, bad := range [][]byte{data[:len(data)-1], append([]byte{7}, data[1:]...)} {
+		E := new(DualTrie[string])
+		E.SetCodec(stringCodec)
+		E.Insert(netip.MustParsePrefix("192.0.2.0/24"), "old")
+		if _, err := E.ReadFrom(bytes.NewReader(bad)); err == nil {
+			t.Error("Expected error for broken snapshot")
+		}
+		if E.Len() != 1 || E.Policy() != MappedAsIPv4 {
+			t.Error("Failed ReadFrom changed the trie")
+		}
+	}
+}
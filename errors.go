@@ -0,0 +1,41 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "errors"
+
+var (
+	// ErrInvalidPrefix is returned for zero or otherwise unusable netip values.
+	ErrInvalidPrefix = errors.New("iptrie: invalid prefix")
+	// ErrFamilyMismatch is returned when address family does not match trie width,
+	// e.g. IPv6 (including IPv4-mapped IPv6) address used with 32 bit trie.
+	ErrFamilyMismatch = errors.New("iptrie: address family does not match trie width")
+	// ErrPrefixTooLong is returned for masks longer than trie width.
+	ErrPrefixTooLong = errors.New("iptrie: prefix longer than trie width")
+	// ErrKeyTooShort is returned when key has less bytes than mask needs.
+	ErrKeyTooShort = errors.New("iptrie: key too short for prefix length")
+	// ErrKeyTooLong is returned when key has more bytes than trie width,
+	// e.g. 16 byte IPv4-in-IPv6 slice passed to 32 bit trie.
+	ErrKeyTooLong = errors.New("iptrie: key longer than trie width")
+	// ErrHostBits is returned in Strict mode for keys with bits set past the mask.
+	ErrHostBits = errors.New("iptrie: host bits set past prefix length")
+	// ErrCorruptTrie means trie structure is broken, it is never caused by input alone.
+	ErrCorruptTrie = errors.New("iptrie: corrupt trie")
+	// ErrInvalidRange is returned for ranges that end before they start.
+	ErrInvalidRange = errors.New("iptrie: range end is before its start")
+	// ErrPoolExhausted is returned when pool has no free prefix of requested length.
+	ErrPoolExhausted = errors.New("iptrie: no free prefix in pool")
+	// ErrCompressMismatch is returned if compressed trie fails to match original.
+	ErrCompressMismatch = errors.New("iptrie: compressed trie does not match original")
+)
+
+// checkKey makes sure key/ln could be used with trie of maxbits width.
+func checkKey(key []byte, ln, maxbits byte) error {
+	if ln > maxbits {
+		return ErrPrefixTooLong
+	}
+	if len(key) < (int(ln)+7)/8 {
+		return ErrKeyTooShort
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package iptrie
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrieErrors(t *testing.T) {
+	var T = new(Trie32)
+	if _, _, err := T.Set([]byte{1, 2, 3, 4}, 33, nil); err != ErrPrefixTooLong {
+		t.Error("Expected ErrPrefixTooLong, got", err)
+	}
+	if _, _, err := T.Append([]byte{1, 2}, 24, nil); err != ErrKeyTooShort {
+		t.Error("Expected ErrKeyTooShort, got", err)
+	}
+	if _, _, err := T.GetNode(nil, 8); err != ErrKeyTooShort {
+		t.Error("Expected ErrKeyTooShort, got", err)
+	}
+	if _, node, err := T.Set(nil, 0, nil); err != nil || node == nil || node.Bits() != 0 {
+		t.Error("Zero length prefix does not need a key", err)
+	}
+	T.MustSet([]byte{10, 0, 0, 0}, 8, nil)
+	if exact, ip, _, _ := T.Get([]byte{10}, 24); exact || ip != nil {
+		t.Error("Short key should not match anything")
+	}
+	if ok, _ := T.Remove([]byte{10}, 16); ok {
+		t.Error("Nothing to remove for short key")
+	}
+	if ok, _ := T.Remove([]byte{10, 1}, 16); ok {
+		t.Error("Nothing to remove for missing key")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustSet should panic on error")
+		}
+	}()
+	T.MustSet([]byte{1}, 16, nil)
+}
+
+func TestCorruptTrieAllocatesNothing(t *testing.T) {
+	var T = new(Trie32)
+	T.MustSet([]byte{10, 0, 0, 0}, 8, nil)
+	_, child := T.MustSet([]byte{10, 1, 0, 0}, 16, nil)
+	before := T.Stats()
+
+	// starting below root makes 10.2.0.0/16 go in front of 10.0.0.0/8,
+	// which is its own supernet
+	if _, _, err := T.addToNode(child, []byte{10, 2, 0, 0}, 16, nil, false); !errors.Is(err, ErrCorruptTrie) {
+		t.Fatal("Expected ErrCorruptTrie, got", err)
+	}
+	after := T.Stats()
+	if after.Entries != before.Entries || after.Dummies != before.Dummies || after.Pooled != before.Pooled {
+		t.Errorf("Failed insert changed counters from %+v to %+v", before, after)
+	}
+}
@@ -0,0 +1,75 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"errors"
+	"os"
+)
+
+// Image is a read-only trie in a flat pointer-free layout that is queried
+// in place, e.g. straight from a memory mapped file shared by processes.
+// All integers are little endian:
+//
+//	magic    [4]byte "IPTI"
+//	version  byte    1
+//	width    byte    32, 64, 128 or 160
+//	reserved [2]byte
+//	nodes    uint32  number of node records
+//	reserved [4]byte
+//	blob     uint64  length of value blob
+//	records  nodes records of imageNodeSize+width/8 bytes
+//	blob     bytes values point to
+//
+// Record holds one trie node, root is record 0 and children always come
+// after their parent (nodes are in preorder), so index 0 means no child:
+//
+//	prefixlen byte
+//	flags     byte    1 real node (not dummy), 2 has value
+//	reserved  [2]byte
+//	a         uint32  index of upper half child
+//	b         uint32  index of lower half child
+//	offset    uint32  value offset in blob
+//	length    uint32  value length
+//	key       width/8 bytes, masked to prefixlen
+
+const (
+	imageMagic      = "IPTI"
+	imageVersion    = 1
+	imageHeaderSize = 24
+	imageNodeSize   = 20 // record size without key
+
+	imageReal  = 1
+	imageValue = 2
+)
+
+var (
+	// ErrImageFormat is returned for data that is not a valid trie image.
+	ErrImageFormat = errors.New("iptrie: malformed trie image")
+	// ErrImageWidth is returned for image of a trie of other width.
+	ErrImageWidth = errors.New("iptrie: image width does not match")
+)
+
+// prefixMatch tells if first ln bits of a and b are the same.
+func prefixMatch(a, b []byte, ln byte) bool {
+	full := ln / 8
+	for i := byte(0); i < full; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	if rem := ln % 8; rem != 0 {
+		return (a[full]^b[full])&^(0xff>>rem) == 0
+	}
+	return true
+}
+
+// openImage maps file at path into memory.
+func openImage(path string) ([]byte, func([]byte) error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return mapFile(f)
+}
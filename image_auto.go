@@ -0,0 +1,519 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// WriteImage writes trie as image for Image32, format is described in
+// image.go. Values are encoded with codec set by SetCodec into blob of the
+// image, without codec there are no values.
+func (t *Trie32) WriteImage(w io.Writer) (int64, error) {
+	var nodes []*Node32
+	if t.node != nil {
+		t.node.Drill(func(n *Node32) { nodes = append(nodes, n) })
+	}
+	if uint64(len(nodes)) > math.MaxUint32 {
+		return 0, ErrImageFormat
+	}
+	index := make(map[*Node32]uint32, len(nodes))
+	for i, n := range nodes {
+		index[n] = uint32(i)
+	}
+
+	var blob []byte
+	recSize := imageNodeSize + 32/8
+	buf := make([]byte, imageHeaderSize, imageHeaderSize+len(nodes)*recSize)
+	copy(buf, imageMagic)
+	buf[4], buf[5] = imageVersion, 32
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(nodes)))
+	for _, n := range nodes {
+		rec := make([]byte, recSize)
+		rec[0] = n.prefixlen
+		if n.dummy == 0 {
+			rec[1] = imageReal
+			if n.data != nil && t.codec != nil {
+				value, err := t.codec.EncodeValue(n.data)
+				if err != nil {
+					return 0, err
+				}
+				if uint64(len(blob))+uint64(len(value)) > math.MaxUint32 {
+					return 0, ErrImageFormat
+				}
+				rec[1] |= imageValue
+				binary.LittleEndian.PutUint32(rec[12:], uint32(len(blob)))
+				binary.LittleEndian.PutUint32(rec[16:], uint32(len(value)))
+				blob = append(blob, value...)
+			}
+		}
+		if n.a != nil {
+			binary.LittleEndian.PutUint32(rec[4:], index[n.a])
+		}
+		if n.b != nil {
+			binary.LittleEndian.PutUint32(rec[8:], index[n.b])
+		}
+		for i, word := range n.bits {
+			binary.BigEndian.PutUint32(rec[imageNodeSize+4*i:], word)
+		}
+		buf = append(buf, rec...)
+	}
+	binary.LittleEndian.PutUint64(buf[16:], uint64(len(blob)))
+
+	n, err := w.Write(buf)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(blob)
+	return int64(n + m), err
+}
+
+// Image32 is read-only Trie32 queried in place, see image.go. It never
+// copies data it is made of.
+type Image32 struct {
+	nodes []byte
+	blob  []byte
+	count uint32
+	data  []byte
+	unmap func([]byte) error
+}
+
+// NewImage32 checks header of image data and returns image using it.
+func NewImage32(data []byte) (*Image32, error) {
+	if len(data) < imageHeaderSize || string(data[:4]) != imageMagic || data[4] != imageVersion {
+		return nil, ErrImageFormat
+	}
+	if data[5] != 32 {
+		return nil, ErrImageWidth
+	}
+	img := &Image32{count: binary.LittleEndian.Uint32(data[8:])}
+	size := uint64(img.count) * (imageNodeSize + 32/8)
+	blob := binary.LittleEndian.Uint64(data[16:])
+	if rest := uint64(len(data) - imageHeaderSize); size > rest || blob != rest-size {
+		return nil, ErrImageFormat
+	}
+	img.nodes = data[imageHeaderSize : imageHeaderSize+size]
+	img.blob = data[imageHeaderSize+size:]
+	return img, nil
+}
+
+// OpenImage32 maps image file into memory (reads it where mmap is not
+// supported). Close releases the mapping.
+func OpenImage32(path string) (*Image32, error) {
+	data, unmap, err := openImage(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := NewImage32(data)
+	if err != nil {
+		if unmap != nil {
+			unmap(data)
+		}
+		return nil, err
+	}
+	img.data, img.unmap = data, unmap
+	return img, nil
+}
+
+// Close releases mapping made by OpenImage32, image and all slices it
+// returned must not be used after that.
+func (img *Image32) Close() error {
+	if img.unmap == nil {
+		return nil
+	}
+	err := img.unmap(img.data)
+	img.unmap, img.nodes, img.blob, img.count = nil, nil, nil, 0
+	return err
+}
+
+// Get has same semantics as Trie32.Get but returns value bytes from blob
+// instead of a pointer. Returned slices point into the image and must not
+// be changed.
+func (img *Image32) Get(ip []byte, mask byte) (bool, []byte, byte, []byte) {
+	if checkKey(ip, mask, 32) != nil || img.count == 0 {
+		return false, nil, 0, nil
+	}
+	const recSize = imageNodeSize + 32/8
+	var best []byte
+	for i := uint32(0); ; {
+		rec := img.nodes[int(i)*recSize : int(i+1)*recSize]
+		ln := rec[0]
+		if ln > mask || ln > 32 || !prefixMatch(rec[imageNodeSize:], ip, ln) {
+			break
+		}
+		if rec[1]&imageReal != 0 {
+			best = rec
+		}
+		if ln == mask {
+			break
+		}
+		next := binary.LittleEndian.Uint32(rec[8:])
+		if hasBit8(ip, ln+1) {
+			next = binary.LittleEndian.Uint32(rec[4:])
+		}
+		if next <= i || next >= img.count {
+			break // no child, broken images end up here too
+		}
+		i = next
+	}
+	if best == nil {
+		return false, nil, 0, nil
+	}
+	ln := best[0]
+	key := best[imageNodeSize : imageNodeSize+4*((int(ln)+31)/32)]
+	var value []byte
+	if best[1]&imageValue != 0 {
+		off, size := uint64(binary.LittleEndian.Uint32(best[12:])), uint64(binary.LittleEndian.Uint32(best[16:]))
+		if off+size <= uint64(len(img.blob)) {
+			value = img.blob[off : off+size]
+		}
+	}
+	return ln == mask, key, ln, value
+}
+
+// WriteImage writes trie as image, see Trie32.WriteImage.
+func (t *TypedTrie32[V]) WriteImage(w io.Writer) (int64, error) {
+	return t.trie.WriteImage(w)
+}
+
+// WriteImage writes trie as image for Image64, format is described in
+// image.go. Values are encoded with codec set by SetCodec into blob of the
+// image, without codec there are no values.
+func (t *Trie64) WriteImage(w io.Writer) (int64, error) {
+	var nodes []*Node64
+	if t.node != nil {
+		t.node.Drill(func(n *Node64) { nodes = append(nodes, n) })
+	}
+	if uint64(len(nodes)) > math.MaxUint32 {
+		return 0, ErrImageFormat
+	}
+	index := make(map[*Node64]uint32, len(nodes))
+	for i, n := range nodes {
+		index[n] = uint32(i)
+	}
+
+	var blob []byte
+	recSize := imageNodeSize + 64/8
+	buf := make([]byte, imageHeaderSize, imageHeaderSize+len(nodes)*recSize)
+	copy(buf, imageMagic)
+	buf[4], buf[5] = imageVersion, 64
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(nodes)))
+	for _, n := range nodes {
+		rec := make([]byte, recSize)
+		rec[0] = n.prefixlen
+		if n.dummy == 0 {
+			rec[1] = imageReal
+			if n.data != nil && t.codec != nil {
+				value, err := t.codec.EncodeValue(n.data)
+				if err != nil {
+					return 0, err
+				}
+				if uint64(len(blob))+uint64(len(value)) > math.MaxUint32 {
+					return 0, ErrImageFormat
+				}
+				rec[1] |= imageValue
+				binary.LittleEndian.PutUint32(rec[12:], uint32(len(blob)))
+				binary.LittleEndian.PutUint32(rec[16:], uint32(len(value)))
+				blob = append(blob, value...)
+			}
+		}
+		if n.a != nil {
+			binary.LittleEndian.PutUint32(rec[4:], index[n.a])
+		}
+		if n.b != nil {
+			binary.LittleEndian.PutUint32(rec[8:], index[n.b])
+		}
+		for i, word := range n.bits {
+			binary.BigEndian.PutUint32(rec[imageNodeSize+4*i:], word)
+		}
+		buf = append(buf, rec...)
+	}
+	binary.LittleEndian.PutUint64(buf[16:], uint64(len(blob)))
+
+	n, err := w.Write(buf)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(blob)
+	return int64(n + m), err
+}
+
+// Image64 is read-only Trie64 queried in place, see image.go. It never
+// copies data it is made of.
+type Image64 struct {
+	nodes []byte
+	blob  []byte
+	count uint32
+	data  []byte
+	unmap func([]byte) error
+}
+
+// NewImage64 checks header of image data and returns image using it.
+func NewImage64(data []byte) (*Image64, error) {
+	if len(data) < imageHeaderSize || string(data[:4]) != imageMagic || data[4] != imageVersion {
+		return nil, ErrImageFormat
+	}
+	if data[5] != 64 {
+		return nil, ErrImageWidth
+	}
+	img := &Image64{count: binary.LittleEndian.Uint32(data[8:])}
+	size := uint64(img.count) * (imageNodeSize + 64/8)
+	blob := binary.LittleEndian.Uint64(data[16:])
+	if rest := uint64(len(data) - imageHeaderSize); size > rest || blob != rest-size {
+		return nil, ErrImageFormat
+	}
+	img.nodes = data[imageHeaderSize : imageHeaderSize+size]
+	img.blob = data[imageHeaderSize+size:]
+	return img, nil
+}
+
+// OpenImage64 maps image file into memory (reads it where mmap is not
+// supported). Close releases the mapping.
+func OpenImage64(path string) (*Image64, error) {
+	data, unmap, err := openImage(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := NewImage64(data)
+	if err != nil {
+		if unmap != nil {
+			unmap(data)
+		}
+		return nil, err
+	}
+	img.data, img.unmap = data, unmap
+	return img, nil
+}
+
+// Close releases mapping made by OpenImage64, image and all slices it
+// returned must not be used after that.
+func (img *Image64) Close() error {
+	if img.unmap == nil {
+		return nil
+	}
+	err := img.unmap(img.data)
+	img.unmap, img.nodes, img.blob, img.count = nil, nil, nil, 0
+	return err
+}
+
+// Get has same semantics as Trie64.Get but returns value bytes from blob
+// instead of a pointer. Returned slices point into the image and must not
+// be changed.
+func (img *Image64) Get(ip []byte, mask byte) (bool, []byte, byte, []byte) {
+	if checkKey(ip, mask, 64) != nil || img.count == 0 {
+		return false, nil, 0, nil
+	}
+	const recSize = imageNodeSize + 64/8
+	var best []byte
+	for i := uint32(0); ; {
+		rec := img.nodes[int(i)*recSize : int(i+1)*recSize]
+		ln := rec[0]
+		if ln > mask || ln > 64 || !prefixMatch(rec[imageNodeSize:], ip, ln) {
+			break
+		}
+		if rec[1]&imageReal != 0 {
+			best = rec
+		}
+		if ln == mask {
+			break
+		}
+		next := binary.LittleEndian.Uint32(rec[8:])
+		if hasBit8(ip, ln+1) {
+			next = binary.LittleEndian.Uint32(rec[4:])
+		}
+		if next <= i || next >= img.count {
+			break // no child, broken images end up here too
+		}
+		i = next
+	}
+	if best == nil {
+		return false, nil, 0, nil
+	}
+	ln := best[0]
+	key := best[imageNodeSize : imageNodeSize+4*((int(ln)+31)/32)]
+	var value []byte
+	if best[1]&imageValue != 0 {
+		off, size := uint64(binary.LittleEndian.Uint32(best[12:])), uint64(binary.LittleEndian.Uint32(best[16:]))
+		if off+size <= uint64(len(img.blob)) {
+			value = img.blob[off : off+size]
+		}
+	}
+	return ln == mask, key, ln, value
+}
+
+// WriteImage writes trie as image, see Trie64.WriteImage.
+func (t *TypedTrie64[V]) WriteImage(w io.Writer) (int64, error) {
+	return t.trie.WriteImage(w)
+}
+
+// WriteImage writes trie as image for Image128, format is described in
+// image.go. Values are encoded with codec set by SetCodec into blob of the
+// image, without codec there are no values.
+func (t *Trie128) WriteImage(w io.Writer) (int64, error) {
+	var nodes []*Node128
+	if t.node != nil {
+		t.node.Drill(func(n *Node128) { nodes = append(nodes, n) })
+	}
+	if uint64(len(nodes)) > math.MaxUint32 {
+		return 0, ErrImageFormat
+	}
+	index := make(map[*Node128]uint32, len(nodes))
+	for i, n := range nodes {
+		index[n] = uint32(i)
+	}
+
+	var blob []byte
+	recSize := imageNodeSize + 128/8
+	buf := make([]byte, imageHeaderSize, imageHeaderSize+len(nodes)*recSize)
+	copy(buf, imageMagic)
+	buf[4], buf[5] = imageVersion, 128
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(nodes)))
+	for _, n := range nodes {
+		rec := make([]byte, recSize)
+		rec[0] = n.prefixlen
+		if n.dummy == 0 {
+			rec[1] = imageReal
+			if n.data != nil && t.codec != nil {
+				value, err := t.codec.EncodeValue(n.data)
+				if err != nil {
+					return 0, err
+				}
+				if uint64(len(blob))+uint64(len(value)) > math.MaxUint32 {
+					return 0, ErrImageFormat
+				}
+				rec[1] |= imageValue
+				binary.LittleEndian.PutUint32(rec[12:], uint32(len(blob)))
+				binary.LittleEndian.PutUint32(rec[16:], uint32(len(value)))
+				blob = append(blob, value...)
+			}
+		}
+		if n.a != nil {
+			binary.LittleEndian.PutUint32(rec[4:], index[n.a])
+		}
+		if n.b != nil {
+			binary.LittleEndian.PutUint32(rec[8:], index[n.b])
+		}
+		for i, word := range n.bits {
+			binary.BigEndian.PutUint32(rec[imageNodeSize+4*i:], word)
+		}
+		buf = append(buf, rec...)
+	}
+	binary.LittleEndian.PutUint64(buf[16:], uint64(len(blob)))
+
+	n, err := w.Write(buf)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(blob)
+	return int64(n + m), err
+}
+
+// Image128 is read-only Trie128 queried in place, see image.go. It never
+// copies data it is made of.
+type Image128 struct {
+	nodes []byte
+	blob  []byte
+	count uint32
+	data  []byte
+	unmap func([]byte) error
+}
+
+// NewImage128 checks header of image data and returns image using it.
+func NewImage128(data []byte) (*Image128, error) {
+	if len(data) < imageHeaderSize || string(data[:4]) != imageMagic || data[4] != imageVersion {
+		return nil, ErrImageFormat
+	}
+	if data[5] != 128 {
+		return nil, ErrImageWidth
+	}
+	img := &Image128{count: binary.LittleEndian.Uint32(data[8:])}
+	size := uint64(img.count) * (imageNodeSize + 128/8)
+	blob := binary.LittleEndian.Uint64(data[16:])
+	if rest := uint64(len(data) - imageHeaderSize); size > rest || blob != rest-size {
+		return nil, ErrImageFormat
+	}
+	img.nodes = data[imageHeaderSize : imageHeaderSize+size]
+	img.blob = data[imageHeaderSize+size:]
+	return img, nil
+}
+
+// OpenImage128 maps image file into memory (reads it where mmap is not
+// supported). Close releases the mapping.
+func OpenImage128(path string) (*Image128, error) {
+	data, unmap, err := openImage(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := NewImage128(data)
+	if err != nil {
+		if unmap != nil {
+			unmap(data)
+		}
+		return nil, err
+	}
+	img.data, img.unmap = data, unmap
+	return img, nil
+}
+
+// Close releases mapping made by OpenImage128, image and all slices it
+// returned must not be used after that.
+func (img *Image128) Close() error {
+	if img.unmap == nil {
+		return nil
+	}
+	err := img.unmap(img.data)
+	img.unmap, img.nodes, img.blob, img.count = nil, nil, nil, 0
+	return err
+}
+
+// Get has same semantics as Trie128.Get but returns value bytes from blob
+// instead of a pointer. Returned slices point into the image and must not
+// be changed.
+func (img *Image128) Get(ip []byte, mask byte) (bool, []byte, byte, []byte) {
+	if checkKey(ip, mask, 128) != nil || img.count == 0 {
+		return false, nil, 0, nil
+	}
+	const recSize = imageNodeSize + 128/8
+	var best []byte
+	for i := uint32(0); ; {
+		rec := img.nodes[int(i)*recSize : int(i+1)*recSize]
+		ln := rec[0]
+		if ln > mask || ln > 128 || !prefixMatch(rec[imageNodeSize:], ip, ln) {
+			break
+		}
+		if rec[1]&imageReal != 0 {
+			best = rec
+		}
+		if ln == mask {
+			break
+		}
+		next := binary.LittleEndian.Uint32(rec[8:])
+		if hasBit8(ip, ln+1) {
+			next = binary.LittleEndian.Uint32(rec[4:])
+		}
+		if next <= i || next >= img.count {
+			break // no child, broken images end up here too
+		}
+		i = next
+	}
+	if best == nil {
+		return false, nil, 0, nil
+	}
+	ln := best[0]
+	key := best[imageNodeSize : imageNodeSize+4*((int(ln)+31)/32)]
+	var value []byte
+	if best[1]&imageValue != 0 {
+		off, size := uint64(binary.LittleEndian.Uint32(best[12:])), uint64(binary.LittleEndian.Uint32(best[16:]))
+		if off+size <= uint64(len(img.blob)) {
+			value = img.blob[off : off+size]
+		}
+	}
+	return ln == mask, key, ln, value
+}
+
+// WriteImage writes trie as image, see Trie128.WriteImage.
+func (t *TypedTrie128[V]) WriteImage(w io.Writer) (int64, error) {
+	return t.trie.WriteImage(w)
+}
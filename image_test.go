@@ -0,0 +1,75 @@
+package iptrie
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImage(t *testing.T) {
+	rnd := rand.New(rand.NewSource(22))
+	T := new(TypedTrie32[string])
+	T.SetCodec(stringCodec)
+	for i := 0; i < 3000; i++ {
+		ip := []byte{10, byte(rnd.Intn(8)), byte(rnd.Intn(256)), byte(rnd.Intn(256))}
+		T.Set(ip, byte(8+rnd.Intn(25)), string(rune('a'+rnd.Intn(26))))
+	}
+	T.Set([]byte{0, 0, 0, 0}, 0, "default")
+	T.Set([]byte{10, 0, 0, 0}, 16, "") // value encodes to nothing
+
+	path := filepath.Join(t.TempDir(), "trie.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = T.WriteImage(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	img, err := OpenImage32(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	for i := 0; i < 20000; i++ {
+		ip := []byte{10, byte(rnd.Intn(9)), byte(rnd.Intn(256)), byte(rnd.Intn(256))}
+		mask := byte(rnd.Intn(33))
+		exact, key, ln, value := T.Get(ip, mask)
+		iexact, ikey, iln, ivalue := img.Get(ip, mask)
+		if exact != iexact || !bytes.Equal(key, ikey) || ln != iln || value != string(ivalue) {
+			t.Fatalf("Get(%v/%d): trie has %v %v/%d=%q, image has %v %v/%d=%q", ip, mask, exact, key, ln, value, iexact, ikey, iln, ivalue)
+		}
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	var buf bytes.Buffer
+	mkTrie32("10.0.0.0/8", "a").WriteImage(&buf)
+	data := buf.Bytes()
+	if _, err := NewImage128(data); err != ErrImageWidth {
+		t.Errorf("Expected ErrImageWidth, got %v", err)
+	}
+	if _, err := NewImage32(data[:len(data)-1]); err != ErrImageFormat {
+		t.Errorf("Expected ErrImageFormat for truncated image, got %v", err)
+	}
+	if _, err := NewImage32(append([]byte("XXXX"), data[4:]...)); err != ErrImageFormat {
+		t.Errorf("Expected ErrImageFormat for bad magic, got %v", err)
+	}
+	img, err := NewImage32(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ln, _ := img.Get([]byte{10, 1, 1, 1}, 32); ln != 8 {
+		t.Errorf("Expected 10.0.0.0/8 match, got /%d", ln)
+	}
+	empty := new(bytes.Buffer)
+	new(Trie64).WriteImage(empty)
+	if img, err := NewImage64(empty.Bytes()); err != nil {
+		t.Error(err)
+	} else if _, key, _, _ := img.Get([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 64); key != nil {
+		t.Error("Expected no match in empty image")
+	}
+}
@@ -1,7 +1,12 @@
 // Package iptrie implements trie for keeping IP/mask info
 package iptrie
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unsafe"
+)
 
 // Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
 
@@ -24,5 +29,51 @@ func mkuint32(key []byte, ln byte) uint32 {
 	if ln > 8 && len(key) > 1 {
 		return (uint32(key[0])<<24 | uint32(key[1])<<16) & mask
 	}
+	if len(key) == 0 {
+		return 0 // only possible for zero length prefix
+	}
 	return (uint32(key[0]) << 24) & mask
 }
+
+// boxValue moves value to the heap so typed tries can keep it behind
+// unsafe.Pointer without hiding it from garbage collector.
+func boxValue[V any](value V) unsafe.Pointer {
+	p := new(V)
+	*p = value
+	return unsafe.Pointer(p)
+}
+
+func unboxValue[V any](p unsafe.Pointer) V {
+	if p == nil {
+		var zero V
+		return zero
+	}
+	return *(*V)(p)
+}
+
+// prefixString formats key of a trie with given width: IPv4 and IPv6
+// notation for 32 and 128 bits, colon separated hex words for others.
+func prefixString(ip []byte, ln, width byte) string {
+	switch width {
+	case 32:
+		return prefix4(ip, ln).String()
+	case 128:
+		return prefix6(ip, ln).String()
+	}
+	key := make([]byte, width/8)
+	copy(key, ip)
+	words := make([]string, 0, len(key)/2)
+	for i := 0; i < len(key); i += 2 {
+		words = append(words, fmt.Sprintf("%02x%02x", key[i], key[i+1]))
+	}
+	return fmt.Sprintf("%s/%d", strings.Join(words, ":"), ln)
+}
+
+// Stats describes structure of a trie.
+type Stats struct {
+	Entries  int   // nodes with values (not dummies)
+	Dummies  int   // intermediate nodes
+	Pooled   int   // allocated nodes not in use, preallocated or removed
+	MaxDepth int   // nodes on the longest path from root, 0 for empty trie
+	Lengths  []int // Lengths[n] is number of entries with /n prefix
+}
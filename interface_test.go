@@ -12,11 +12,11 @@ import (
 
 func TestTrieInterface(t *testing.T) {
 	var T = new(Trie32)
-	a, _ := T.Append([]byte{1, 2, 3, 4}, 24, nil)
-	if !a {
-		t.Error("Unable to insert!")
+	a, _, err := T.Append([]byte{1, 2, 3, 4}, 24, nil)
+	if !a || err != nil {
+		t.Error("Unable to insert!", err)
 	}
-	b, _ := T.Append([]byte{1, 2, 3, 0}, 24, nil)
+	b, _ := T.MustAppend([]byte{1, 2, 3, 0}, 24, nil)
 	if b {
 		t.Error("Should not be possible to replace with append!")
 	}
@@ -39,7 +39,7 @@ func TestTrieGetNode(t *testing.T) {
 
 	var T = new(Trie32)
 	for _, tst := range insert {
-		ok, node := T.GetNode(tst[:4], tst[4])
+		ok, node, _ := T.GetNode(tst[:4], tst[4])
 		if !ok {
 			t.Error("Node was not added", tst[:4], tst[4])
 		}
@@ -57,7 +57,7 @@ func TestTrieGetNodeV6(t *testing.T) {
 
 	var T = new(Trie128)
 	for _, tst := range insert {
-		ok, node := T.GetNode(tst[:len(tst)-1], tst[len(tst)-1])
+		ok, node := T.MustGetNode(tst[:len(tst)-1], tst[len(tst)-1])
 		if !ok {
 			t.Error("Node was not added", tst[:len(tst)-1], tst[len(tst)-1])
 		}
@@ -0,0 +1,252 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"iter"
+	"unsafe"
+)
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i iter160.go -o iter_auto.go
+
+// Prefix160 is a key stored in Trie160. It is a plain value, getting one
+// from a node does not allocate.
+type Prefix160 struct {
+	bits      [MAXBITS / 32]uint32
+	prefixlen byte
+}
+
+func (node *Node160) Prefix() Prefix160 {
+	return Prefix160{node.bits, node.prefixlen}
+}
+
+func (p Prefix160) Bits() byte {
+	return p.prefixlen
+}
+
+// IP returns same bytes as Node160.IP.
+func (p Prefix160) IP() []byte {
+	node := Node160{prefixlen: p.prefixlen, bits: p.bits}
+	return node.IP()
+}
+
+func (p Prefix160) String() string {
+	return prefixString(p.IP(), p.prefixlen, MAXBITS)
+}
+
+// all calls yield for real nodes of subtree in ascending order: node
+// first, then lower (b) and upper (a) halves. It returns false once
+// yield asked to stop.
+func (node *Node160) all(yield func(*Node160) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.dummy == 0 && !yield(node) {
+		return false
+	}
+	return node.b.all(yield) && node.a.all(yield)
+}
+
+// backward is all in reverse.
+func (node *Node160) backward(yield func(*Node160) bool) bool {
+	if node == nil {
+		return true
+	}
+	if !node.a.backward(yield) || !node.b.backward(yield) {
+		return false
+	}
+	return node.dummy != 0 || yield(node)
+}
+
+// All iterates over entries in ascending address order, shorter prefix
+// goes before longer one with same address. Dummy nodes are skipped.
+// Trie must not be changed while iterating.
+func (t *Trie160) All() iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.node.all(func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *Trie160) Backward() iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.node.backward(func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// All iterates over entries in ascending address order, see Trie160.All.
+func (t *TypedTrie160[V]) All() iter.Seq2[Prefix160, V] {
+	return func(yield func(Prefix160, V) bool) {
+		t.trie.node.all(func(n *Node160) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *TypedTrie160[V]) Backward() iter.Seq2[Prefix160, V] {
+	return func(yield func(Prefix160, V) bool) {
+		t.trie.node.backward(func(n *Node160) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// All iterates over entries of version current at the time of the call.
+func (t *PersistentTrie160) All() iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.root.Load().all(func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries of current version in reverse order.
+func (t *PersistentTrie160) Backward() iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.root.Load().backward(func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// cover returns topmost node inside key/ln, which is key/ln itself if it
+// is in the trie. It returns nil when nothing is stored under key/ln.
+func (node *Node160) cover(key []byte, ln byte) *Node160 {
+	exact, parent, _ := node.findBestMatch(key, ln)
+	if exact {
+		return parent
+	}
+	down := node // nothing matched, only root could be inside
+	if parent != nil {
+		if hasBit8(key, parent.prefixlen+1) {
+			down = parent.a
+		} else {
+			down = parent.b
+		}
+	}
+	if down == nil || down.prefixlen < ln {
+		return nil
+	}
+	var q Node160
+	q.setKey(key, ln)
+	if down.bitsMatched(q.bits[:], ln) < ln {
+		return nil
+	}
+	return down
+}
+
+// subnets iterates over real nodes under ip/mask, ip/mask itself included
+// only when self is true.
+func (node *Node160) subnets(ip []byte, mask byte, self bool, yield func(*Node160) bool) {
+	if checkKey(ip, mask, MAXBITS) != nil {
+		return
+	}
+	if node = node.cover(ip, mask); node == nil {
+		return
+	}
+	if node.prefixlen == mask && !self {
+		if node.b.all(yield) {
+			node.a.all(yield)
+		}
+		return
+	}
+	node.all(yield)
+}
+
+// Subnets iterates over entries inside ip/mask in same order as All.
+// Entry for ip/mask itself is included only when self is true.
+func (t *Trie160) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.node.subnets(ip, mask, self, func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Subnets iterates over entries inside ip/mask, see Trie160.Subnets.
+func (t *TypedTrie160[V]) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix160, V] {
+	return func(yield func(Prefix160, V) bool) {
+		t.trie.node.subnets(ip, mask, self, func(n *Node160) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// supernets calls yield for real nodes on lookup path of key/ln.
+func (node *Node160) supernets(key []byte, ln byte, yield func(*Node160) bool) {
+	if checkKey(key, ln, MAXBITS) != nil {
+		return
+	}
+	for node != nil && node.match(key, ln) {
+		if node.dummy == 0 && !yield(node) {
+			return
+		}
+		if node.prefixlen == ln {
+			return
+		}
+		if hasBit8(key, node.prefixlen+1) {
+			node = node.a
+		} else {
+			node = node.b
+		}
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask from least to
+// most specific, ip/mask itself included if it is stored. Last one is what
+// Get returns. Nothing is allocated per step.
+func (t *Trie160) Supernets(ip []byte, mask byte) iter.Seq2[Prefix160, unsafe.Pointer] {
+	return func(yield func(Prefix160, unsafe.Pointer) bool) {
+		t.node.supernets(ip, mask, func(n *Node160) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask, see Trie160.Supernets.
+func (t *TypedTrie160[V]) Supernets(ip []byte, mask byte) iter.Seq2[Prefix160, V] {
+	return func(yield func(Prefix160, V) bool) {
+		t.trie.node.supernets(ip, mask, func(n *Node160) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// gaps calls yield for parts of p not covered by entries of subtree s, as
+// few and as large prefixes as possible, skipping ones longer than maxLen.
+// Entry at p itself counts only when self is true.
+func (s side160) gaps(p Prefix160, self bool, maxLen byte, yield func(Prefix160) bool) bool {
+	switch {
+	case self && s.sub != nil && s.sub.prefixlen == p.prefixlen && s.sub.dummy == 0:
+		return true
+	case !s.deeper(p.prefixlen):
+		return p.prefixlen > maxLen || yield(p)
+	case p.prefixlen >= maxLen:
+		return true // what is left is smaller than maxLen
+	}
+	return s.half(p.prefixlen, 0).gaps(p.half(0), true, maxLen, yield) &&
+		s.half(p.prefixlen, 1).gaps(p.half(1), true, maxLen, yield)
+}
+
+// Gaps iterates in ascending order over the smallest set of prefixes inside
+// ip/mask that no entry covers. Entry for ip/mask itself is not counted as
+// covering. Gaps longer than maxLen are skipped, use MAXBITS to get all of
+// them. Only subtree of ip/mask is visited.
+func (t *Trie160) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix160] {
+	return func(yield func(Prefix160) bool) {
+		covered := false
+		t.node.supernets(ip, mask, func(n *Node160) bool {
+			covered = n.prefixlen < mask
+			return !covered
+		})
+		if !covered {
+			t.node.gaps(ip, mask, maxLen, yield)
+		}
+	}
+}
+
+// gaps is Gaps ignoring entries outside of ip/mask.
+func (node *Node160) gaps(ip []byte, mask, maxLen byte, yield func(Prefix160) bool) {
+	if checkKey(ip, mask, MAXBITS) != nil {
+		return
+	}
+	if maxLen > MAXBITS {
+		maxLen = MAXBITS
+	}
+	var q Node160
+	q.setKey(ip, mask)
+	side160{sub: node.cover(ip, mask)}.gaps(q.Prefix(), false, maxLen, yield)
+}
+
+// Gaps iterates over free prefixes inside ip/mask, see Trie160.Gaps.
+func (t *TypedTrie160[V]) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix160] {
+	return t.trie.Gaps(ip, mask, maxLen)
+}
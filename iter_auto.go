@@ -0,0 +1,725 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"iter"
+	"unsafe"
+)
+
+// Prefix32 is a key stored in Trie32. It is a plain value, getting one
+// from a node does not allocate.
+type Prefix32 struct {
+	bits      [32 / 32]uint32
+	prefixlen byte
+}
+
+func (node *Node32) Prefix() Prefix32 {
+	return Prefix32{node.bits, node.prefixlen}
+}
+
+func (p Prefix32) Bits() byte {
+	return p.prefixlen
+}
+
+// IP returns same bytes as Node32.IP.
+func (p Prefix32) IP() []byte {
+	node := Node32{prefixlen: p.prefixlen, bits: p.bits}
+	return node.IP()
+}
+
+func (p Prefix32) String() string {
+	return prefixString(p.IP(), p.prefixlen, 32)
+}
+
+// all calls yield for real nodes of subtree in ascending order: node
+// first, then lower (b) and upper (a) halves. It returns false once
+// yield asked to stop.
+func (node *Node32) all(yield func(*Node32) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.dummy == 0 && !yield(node) {
+		return false
+	}
+	return node.b.all(yield) && node.a.all(yield)
+}
+
+// backward is all in reverse.
+func (node *Node32) backward(yield func(*Node32) bool) bool {
+	if node == nil {
+		return true
+	}
+	if !node.a.backward(yield) || !node.b.backward(yield) {
+		return false
+	}
+	return node.dummy != 0 || yield(node)
+}
+
+// All iterates over entries in ascending address order, shorter prefix
+// goes before longer one with same address. Dummy nodes are skipped.
+// Trie must not be changed while iterating.
+func (t *Trie32) All() iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.node.all(func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *Trie32) Backward() iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.node.backward(func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// All iterates over entries in ascending address order, see Trie32.All.
+func (t *TypedTrie32[V]) All() iter.Seq2[Prefix32, V] {
+	return func(yield func(Prefix32, V) bool) {
+		t.trie.node.all(func(n *Node32) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *TypedTrie32[V]) Backward() iter.Seq2[Prefix32, V] {
+	return func(yield func(Prefix32, V) bool) {
+		t.trie.node.backward(func(n *Node32) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// All iterates over entries of version current at the time of the call.
+func (t *PersistentTrie32) All() iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.root.Load().all(func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries of current version in reverse order.
+func (t *PersistentTrie32) Backward() iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.root.Load().backward(func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// cover returns topmost node inside key/ln, which is key/ln itself if it
+// is in the trie. It returns nil when nothing is stored under key/ln.
+func (node *Node32) cover(key []byte, ln byte) *Node32 {
+	exact, parent, _ := node.findBestMatch(key, ln)
+	if exact {
+		return parent
+	}
+	down := node // nothing matched, only root could be inside
+	if parent != nil {
+		if hasBit8(key, parent.prefixlen+1) {
+			down = parent.a
+		} else {
+			down = parent.b
+		}
+	}
+	if down == nil || down.prefixlen < ln {
+		return nil
+	}
+	var q Node32
+	q.setKey(key, ln)
+	if down.bitsMatched(q.bits[:], ln) < ln {
+		return nil
+	}
+	return down
+}
+
+// subnets iterates over real nodes under ip/mask, ip/mask itself included
+// only when self is true.
+func (node *Node32) subnets(ip []byte, mask byte, self bool, yield func(*Node32) bool) {
+	if checkKey(ip, mask, 32) != nil {
+		return
+	}
+	if node = node.cover(ip, mask); node == nil {
+		return
+	}
+	if node.prefixlen == mask && !self {
+		if node.b.all(yield) {
+			node.a.all(yield)
+		}
+		return
+	}
+	node.all(yield)
+}
+
+// Subnets iterates over entries inside ip/mask in same order as All.
+// Entry for ip/mask itself is included only when self is true.
+func (t *Trie32) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.node.subnets(ip, mask, self, func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Subnets iterates over entries inside ip/mask, see Trie32.Subnets.
+func (t *TypedTrie32[V]) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix32, V] {
+	return func(yield func(Prefix32, V) bool) {
+		t.trie.node.subnets(ip, mask, self, func(n *Node32) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// supernets calls yield for real nodes on lookup path of key/ln.
+func (node *Node32) supernets(key []byte, ln byte, yield func(*Node32) bool) {
+	if checkKey(key, ln, 32) != nil {
+		return
+	}
+	for node != nil && node.match(key, ln) {
+		if node.dummy == 0 && !yield(node) {
+			return
+		}
+		if node.prefixlen == ln {
+			return
+		}
+		if hasBit8(key, node.prefixlen+1) {
+			node = node.a
+		} else {
+			node = node.b
+		}
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask from least to
+// most specific, ip/mask itself included if it is stored. Last one is what
+// Get returns. Nothing is allocated per step.
+func (t *Trie32) Supernets(ip []byte, mask byte) iter.Seq2[Prefix32, unsafe.Pointer] {
+	return func(yield func(Prefix32, unsafe.Pointer) bool) {
+		t.node.supernets(ip, mask, func(n *Node32) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask, see Trie32.Supernets.
+func (t *TypedTrie32[V]) Supernets(ip []byte, mask byte) iter.Seq2[Prefix32, V] {
+	return func(yield func(Prefix32, V) bool) {
+		t.trie.node.supernets(ip, mask, func(n *Node32) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// gaps calls yield for parts of p not covered by entries of subtree s, as
+// few and as large prefixes as possible, skipping ones longer than maxLen.
+// Entry at p itself counts only when self is true.
+func (s side32) gaps(p Prefix32, self bool, maxLen byte, yield func(Prefix32) bool) bool {
+	switch {
+	case self && s.sub != nil && s.sub.prefixlen == p.prefixlen && s.sub.dummy == 0:
+		return true
+	case !s.deeper(p.prefixlen):
+		return p.prefixlen > maxLen || yield(p)
+	case p.prefixlen >= maxLen:
+		return true // what is left is smaller than maxLen
+	}
+	return s.half(p.prefixlen, 0).gaps(p.half(0), true, maxLen, yield) &&
+		s.half(p.prefixlen, 1).gaps(p.half(1), true, maxLen, yield)
+}
+
+// Gaps iterates in ascending order over the smallest set of prefixes inside
+// ip/mask that no entry covers. Entry for ip/mask itself is not counted as
+// covering. Gaps longer than maxLen are skipped, use 32 to get all of
+// them. Only subtree of ip/mask is visited.
+func (t *Trie32) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix32] {
+	return func(yield func(Prefix32) bool) {
+		covered := false
+		t.node.supernets(ip, mask, func(n *Node32) bool {
+			covered = n.prefixlen < mask
+			return !covered
+		})
+		if !covered {
+			t.node.gaps(ip, mask, maxLen, yield)
+		}
+	}
+}
+
+// gaps is Gaps ignoring entries outside of ip/mask.
+func (node *Node32) gaps(ip []byte, mask, maxLen byte, yield func(Prefix32) bool) {
+	if checkKey(ip, mask, 32) != nil {
+		return
+	}
+	if maxLen > 32 {
+		maxLen = 32
+	}
+	var q Node32
+	q.setKey(ip, mask)
+	side32{sub: node.cover(ip, mask)}.gaps(q.Prefix(), false, maxLen, yield)
+}
+
+// Gaps iterates over free prefixes inside ip/mask, see Trie32.Gaps.
+func (t *TypedTrie32[V]) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix32] {
+	return t.trie.Gaps(ip, mask, maxLen)
+}
+
+// Prefix64 is a key stored in Trie64. It is a plain value, getting one
+// from a node does not allocate.
+type Prefix64 struct {
+	bits      [64 / 32]uint32
+	prefixlen byte
+}
+
+func (node *Node64) Prefix() Prefix64 {
+	return Prefix64{node.bits, node.prefixlen}
+}
+
+func (p Prefix64) Bits() byte {
+	return p.prefixlen
+}
+
+// IP returns same bytes as Node64.IP.
+func (p Prefix64) IP() []byte {
+	node := Node64{prefixlen: p.prefixlen, bits: p.bits}
+	return node.IP()
+}
+
+func (p Prefix64) String() string {
+	return prefixString(p.IP(), p.prefixlen, 64)
+}
+
+// all calls yield for real nodes of subtree in ascending order: node
+// first, then lower (b) and upper (a) halves. It returns false once
+// yield asked to stop.
+func (node *Node64) all(yield func(*Node64) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.dummy == 0 && !yield(node) {
+		return false
+	}
+	return node.b.all(yield) && node.a.all(yield)
+}
+
+// backward is all in reverse.
+func (node *Node64) backward(yield func(*Node64) bool) bool {
+	if node == nil {
+		return true
+	}
+	if !node.a.backward(yield) || !node.b.backward(yield) {
+		return false
+	}
+	return node.dummy != 0 || yield(node)
+}
+
+// All iterates over entries in ascending address order, shorter prefix
+// goes before longer one with same address. Dummy nodes are skipped.
+// Trie must not be changed while iterating.
+func (t *Trie64) All() iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.node.all(func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *Trie64) Backward() iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.node.backward(func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// All iterates over entries in ascending address order, see Trie64.All.
+func (t *TypedTrie64[V]) All() iter.Seq2[Prefix64, V] {
+	return func(yield func(Prefix64, V) bool) {
+		t.trie.node.all(func(n *Node64) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *TypedTrie64[V]) Backward() iter.Seq2[Prefix64, V] {
+	return func(yield func(Prefix64, V) bool) {
+		t.trie.node.backward(func(n *Node64) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// All iterates over entries of version current at the time of the call.
+func (t *PersistentTrie64) All() iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.root.Load().all(func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries of current version in reverse order.
+func (t *PersistentTrie64) Backward() iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.root.Load().backward(func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// cover returns topmost node inside key/ln, which is key/ln itself if it
+// is in the trie. It returns nil when nothing is stored under key/ln.
+func (node *Node64) cover(key []byte, ln byte) *Node64 {
+	exact, parent, _ := node.findBestMatch(key, ln)
+	if exact {
+		return parent
+	}
+	down := node // nothing matched, only root could be inside
+	if parent != nil {
+		if hasBit8(key, parent.prefixlen+1) {
+			down = parent.a
+		} else {
+			down = parent.b
+		}
+	}
+	if down == nil || down.prefixlen < ln {
+		return nil
+	}
+	var q Node64
+	q.setKey(key, ln)
+	if down.bitsMatched(q.bits[:], ln) < ln {
+		return nil
+	}
+	return down
+}
+
+// subnets iterates over real nodes under ip/mask, ip/mask itself included
+// only when self is true.
+func (node *Node64) subnets(ip []byte, mask byte, self bool, yield func(*Node64) bool) {
+	if checkKey(ip, mask, 64) != nil {
+		return
+	}
+	if node = node.cover(ip, mask); node == nil {
+		return
+	}
+	if node.prefixlen == mask && !self {
+		if node.b.all(yield) {
+			node.a.all(yield)
+		}
+		return
+	}
+	node.all(yield)
+}
+
+// Subnets iterates over entries inside ip/mask in same order as All.
+// Entry for ip/mask itself is included only when self is true.
+func (t *Trie64) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.node.subnets(ip, mask, self, func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Subnets iterates over entries inside ip/mask, see Trie64.Subnets.
+func (t *TypedTrie64[V]) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix64, V] {
+	return func(yield func(Prefix64, V) bool) {
+		t.trie.node.subnets(ip, mask, self, func(n *Node64) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// supernets calls yield for real nodes on lookup path of key/ln.
+func (node *Node64) supernets(key []byte, ln byte, yield func(*Node64) bool) {
+	if checkKey(key, ln, 64) != nil {
+		return
+	}
+	for node != nil && node.match(key, ln) {
+		if node.dummy == 0 && !yield(node) {
+			return
+		}
+		if node.prefixlen == ln {
+			return
+		}
+		if hasBit8(key, node.prefixlen+1) {
+			node = node.a
+		} else {
+			node = node.b
+		}
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask from least to
+// most specific, ip/mask itself included if it is stored. Last one is what
+// Get returns. Nothing is allocated per step.
+func (t *Trie64) Supernets(ip []byte, mask byte) iter.Seq2[Prefix64, unsafe.Pointer] {
+	return func(yield func(Prefix64, unsafe.Pointer) bool) {
+		t.node.supernets(ip, mask, func(n *Node64) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask, see Trie64.Supernets.
+func (t *TypedTrie64[V]) Supernets(ip []byte, mask byte) iter.Seq2[Prefix64, V] {
+	return func(yield func(Prefix64, V) bool) {
+		t.trie.node.supernets(ip, mask, func(n *Node64) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// gaps calls yield for parts of p not covered by entries of subtree s, as
+// few and as large prefixes as possible, skipping ones longer than maxLen.
+// Entry at p itself counts only when self is true.
+func (s side64) gaps(p Prefix64, self bool, maxLen byte, yield func(Prefix64) bool) bool {
+	switch {
+	case self && s.sub != nil && s.sub.prefixlen == p.prefixlen && s.sub.dummy == 0:
+		return true
+	case !s.deeper(p.prefixlen):
+		return p.prefixlen > maxLen || yield(p)
+	case p.prefixlen >= maxLen:
+		return true // what is left is smaller than maxLen
+	}
+	return s.half(p.prefixlen, 0).gaps(p.half(0), true, maxLen, yield) &&
+		s.half(p.prefixlen, 1).gaps(p.half(1), true, maxLen, yield)
+}
+
+// Gaps iterates in ascending order over the smallest set of prefixes inside
+// ip/mask that no entry covers. Entry for ip/mask itself is not counted as
+// covering. Gaps longer than maxLen are skipped, use 64 to get all of
+// them. Only subtree of ip/mask is visited.
+func (t *Trie64) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix64] {
+	return func(yield func(Prefix64) bool) {
+		covered := false
+		t.node.supernets(ip, mask, func(n *Node64) bool {
+			covered = n.prefixlen < mask
+			return !covered
+		})
+		if !covered {
+			t.node.gaps(ip, mask, maxLen, yield)
+		}
+	}
+}
+
+// gaps is Gaps ignoring entries outside of ip/mask.
+func (node *Node64) gaps(ip []byte, mask, maxLen byte, yield func(Prefix64) bool) {
+	if checkKey(ip, mask, 64) != nil {
+		return
+	}
+	if maxLen > 64 {
+		maxLen = 64
+	}
+	var q Node64
+	q.setKey(ip, mask)
+	side64{sub: node.cover(ip, mask)}.gaps(q.Prefix(), false, maxLen, yield)
+}
+
+// Gaps iterates over free prefixes inside ip/mask, see Trie64.Gaps.
+func (t *TypedTrie64[V]) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix64] {
+	return t.trie.Gaps(ip, mask, maxLen)
+}
+
+// Prefix128 is a key stored in Trie128. It is a plain value, getting one
+// from a node does not allocate.
+type Prefix128 struct {
+	bits      [128 / 32]uint32
+	prefixlen byte
+}
+
+func (node *Node128) Prefix() Prefix128 {
+	return Prefix128{node.bits, node.prefixlen}
+}
+
+func (p Prefix128) Bits() byte {
+	return p.prefixlen
+}
+
+// IP returns same bytes as Node128.IP.
+func (p Prefix128) IP() []byte {
+	node := Node128{prefixlen: p.prefixlen, bits: p.bits}
+	return node.IP()
+}
+
+func (p Prefix128) String() string {
+	return prefixString(p.IP(), p.prefixlen, 128)
+}
+
+// all calls yield for real nodes of subtree in ascending order: node
+// first, then lower (b) and upper (a) halves. It returns false once
+// yield asked to stop.
+func (node *Node128) all(yield func(*Node128) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.dummy == 0 && !yield(node) {
+		return false
+	}
+	return node.b.all(yield) && node.a.all(yield)
+}
+
+// backward is all in reverse.
+func (node *Node128) backward(yield func(*Node128) bool) bool {
+	if node == nil {
+		return true
+	}
+	if !node.a.backward(yield) || !node.b.backward(yield) {
+		return false
+	}
+	return node.dummy != 0 || yield(node)
+}
+
+// All iterates over entries in ascending address order, shorter prefix
+// goes before longer one with same address. Dummy nodes are skipped.
+// Trie must not be changed while iterating.
+func (t *Trie128) All() iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.node.all(func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *Trie128) Backward() iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.node.backward(func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// All iterates over entries in ascending address order, see Trie128.All.
+func (t *TypedTrie128[V]) All() iter.Seq2[Prefix128, V] {
+	return func(yield func(Prefix128, V) bool) {
+		t.trie.node.all(func(n *Node128) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// Backward iterates over entries in exactly reverse order of All.
+func (t *TypedTrie128[V]) Backward() iter.Seq2[Prefix128, V] {
+	return func(yield func(Prefix128, V) bool) {
+		t.trie.node.backward(func(n *Node128) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// All iterates over entries of version current at the time of the call.
+func (t *PersistentTrie128) All() iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.root.Load().all(func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Backward iterates over entries of current version in reverse order.
+func (t *PersistentTrie128) Backward() iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.root.Load().backward(func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// cover returns topmost node inside key/ln, which is key/ln itself if it
+// is in the trie. It returns nil when nothing is stored under key/ln.
+func (node *Node128) cover(key []byte, ln byte) *Node128 {
+	exact, parent, _ := node.findBestMatch(key, ln)
+	if exact {
+		return parent
+	}
+	down := node // nothing matched, only root could be inside
+	if parent != nil {
+		if hasBit8(key, parent.prefixlen+1) {
+			down = parent.a
+		} else {
+			down = parent.b
+		}
+	}
+	if down == nil || down.prefixlen < ln {
+		return nil
+	}
+	var q Node128
+	q.setKey(key, ln)
+	if down.bitsMatched(q.bits[:], ln) < ln {
+		return nil
+	}
+	return down
+}
+
+// subnets iterates over real nodes under ip/mask, ip/mask itself included
+// only when self is true.
+func (node *Node128) subnets(ip []byte, mask byte, self bool, yield func(*Node128) bool) {
+	if checkKey(ip, mask, 128) != nil {
+		return
+	}
+	if node = node.cover(ip, mask); node == nil {
+		return
+	}
+	if node.prefixlen == mask && !self {
+		if node.b.all(yield) {
+			node.a.all(yield)
+		}
+		return
+	}
+	node.all(yield)
+}
+
+// Subnets iterates over entries inside ip/mask in same order as All.
+// Entry for ip/mask itself is included only when self is true.
+func (t *Trie128) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.node.subnets(ip, mask, self, func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Subnets iterates over entries inside ip/mask, see Trie128.Subnets.
+func (t *TypedTrie128[V]) Subnets(ip []byte, mask byte, self bool) iter.Seq2[Prefix128, V] {
+	return func(yield func(Prefix128, V) bool) {
+		t.trie.node.subnets(ip, mask, self, func(n *Node128) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// supernets calls yield for real nodes on lookup path of key/ln.
+func (node *Node128) supernets(key []byte, ln byte, yield func(*Node128) bool) {
+	if checkKey(key, ln, 128) != nil {
+		return
+	}
+	for node != nil && node.match(key, ln) {
+		if node.dummy == 0 && !yield(node) {
+			return
+		}
+		if node.prefixlen == ln {
+			return
+		}
+		if hasBit8(key, node.prefixlen+1) {
+			node = node.a
+		} else {
+			node = node.b
+		}
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask from least to
+// most specific, ip/mask itself included if it is stored. Last one is what
+// Get returns. Nothing is allocated per step.
+func (t *Trie128) Supernets(ip []byte, mask byte) iter.Seq2[Prefix128, unsafe.Pointer] {
+	return func(yield func(Prefix128, unsafe.Pointer) bool) {
+		t.node.supernets(ip, mask, func(n *Node128) bool { return yield(n.Prefix(), n.data) })
+	}
+}
+
+// Supernets iterates over every entry containing ip/mask, see Trie128.Supernets.
+func (t *TypedTrie128[V]) Supernets(ip []byte, mask byte) iter.Seq2[Prefix128, V] {
+	return func(yield func(Prefix128, V) bool) {
+		t.trie.node.supernets(ip, mask, func(n *Node128) bool { return yield(n.Prefix(), unboxValue[V](n.data)) })
+	}
+}
+
+// gaps calls yield for parts of p not covered by entries of subtree s, as
+// few and as large prefixes as possible, skipping ones longer than maxLen.
+// Entry at p itself counts only when self is true.
+func (s side128) gaps(p Prefix128, self bool, maxLen byte, yield func(Prefix128) bool) bool {
+	switch {
+	case self && s.sub != nil && s.sub.prefixlen == p.prefixlen && s.sub.dummy == 0:
+		return true
+	case !s.deeper(p.prefixlen):
+		return p.prefixlen > maxLen || yield(p)
+	case p.prefixlen >= maxLen:
+		return true // what is left is smaller than maxLen
+	}
+	return s.half(p.prefixlen, 0).gaps(p.half(0), true, maxLen, yield) &&
+		s.half(p.prefixlen, 1).gaps(p.half(1), true, maxLen, yield)
+}
+
+// Gaps iterates in ascending order over the smallest set of prefixes inside
+// ip/mask that no entry covers. Entry for ip/mask itself is not counted as
+// covering. Gaps longer than maxLen are skipped, use 128 to get all of
+// them. Only subtree of ip/mask is visited.
+func (t *Trie128) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix128] {
+	return func(yield func(Prefix128) bool) {
+		covered := false
+		t.node.supernets(ip, mask, func(n *Node128) bool {
+			covered = n.prefixlen < mask
+			return !covered
+		})
+		if !covered {
+			t.node.gaps(ip, mask, maxLen, yield)
+		}
+	}
+}
+
+// gaps is Gaps ignoring entries outside of ip/mask.
+func (node *Node128) gaps(ip []byte, mask, maxLen byte, yield func(Prefix128) bool) {
+	if checkKey(ip, mask, 128) != nil {
+		return
+	}
+	if maxLen > 128 {
+		maxLen = 128
+	}
+	var q Node128
+	q.setKey(ip, mask)
+	side128{sub: node.cover(ip, mask)}.gaps(q.Prefix(), false, maxLen, yield)
+}
+
+// Gaps iterates over free prefixes inside ip/mask, see Trie128.Gaps.
+func (t *TypedTrie128[V]) Gaps(ip []byte, mask, maxLen byte) iter.Seq[Prefix128] {
+	return t.trie.Gaps(ip, mask, maxLen)
+}
@@ -0,0 +1,163 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestTrieAll(t *testing.T) {
+	var T = new(TypedTrie32[int])
+	for i, s := range []string{"10.1.0.0/16", "10.0.0.0/8", "192.168.0.0/24", "10.1.0.0/24", "10.0.0.0/16", "0.0.0.0/0", "10.255.0.0/16", "10.128.0.0/9"} {
+		T.Insert(netip.MustParsePrefix(s), i)
+	}
+	want := []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/16", "10.1.0.0/16", "10.1.0.0/24", "10.128.0.0/9", "10.255.0.0/16", "192.168.0.0/24"}
+
+	var got []string
+	for p, v := range T.All() {
+		got = append(got, p.String())
+		if _, value, _, _ := T.LookupPrefix(netip.MustParsePrefix(p.String())); value != v {
+			t.Errorf("Value %d does not match %d for %s", v, value, p)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected ascending order\n%v\ngot\n%v", want, got)
+	}
+
+	got = got[:0]
+	for p := range T.Backward() {
+		got = append(got, p.String())
+	}
+	slices.Reverse(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected descending order\n%v\ngot\n%v", want, got)
+	}
+
+	got = got[:0]
+	for p := range T.Trie().All() {
+		if p.Bits() > 8 {
+			break
+		}
+		got = append(got, p.String())
+	}
+	if !slices.Equal(got, []string{"0.0.0.0/0", "10.0.0.0/8"}) {
+		t.Error("Expected iteration to stop after 10.0.0.0/8, got", got)
+	}
+}
+
+func TestTrieAllWide(t *testing.T) {
+	var T = new(Trie160)
+	T.MustSet([]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xfd, 0xe8}, 160, nil)
+	T.MustSet([]byte{0x20, 1, 0xd, 0xb8}, 32, nil)
+	var got []string
+	for p := range T.All() {
+		got = append(got, p.String())
+	}
+	want := []string{"2001:0db8:0000:0000:0000:0000:0000:0000:0000:0000/32", "2001:0db8:0000:0000:0000:0000:0000:0000:0000:fde8/160"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestTrieSubnets(t *testing.T) {
+	var T = new(Trie32)
+	for _, s := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.2.0.0/16", "11.0.0.0/8", "10.1.128.0/17"} {
+		p := netip.MustParsePrefix(s)
+		ip := p.Addr().As4()
+		T.MustSet(ip[:], byte(p.Bits()), nil)
+	}
+	var tests = []struct {
+		query string
+		self  bool
+		want  []string
+	}{
+		{"10.0.0.0/8", true, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.1.128.0/17", "10.2.0.0/16"}},
+		{"10.0.0.0/8", false, []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.1.128.0/17", "10.2.0.0/16"}},
+		{"10.1.0.0/17", true, []string{"10.1.2.0/24", "10.1.3.0/24"}},
+		{"10.1.2.0/23", false, []string{"10.1.2.0/24", "10.1.3.0/24"}},
+		{"10.0.0.0/7", false, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.1.128.0/17", "10.2.0.0/16", "11.0.0.0/8"}},
+		{"10.3.0.0/16", true, nil},
+		{"12.0.0.0/8", true, nil},
+		{"10.1.2.0/24", false, nil},
+	}
+	for _, tst := range tests {
+		p := netip.MustParsePrefix(tst.query)
+		ip := p.Addr().As4()
+		var got []string
+		for s := range T.Subnets(ip[:], byte(p.Bits()), tst.self) {
+			got = append(got, s.String())
+		}
+		if !slices.Equal(got, tst.want) {
+			t.Errorf("Subnets of %s (self=%t): expected %v, got %v", tst.query, tst.self, tst.want, got)
+		}
+	}
+}
+
+func TestTrieSupernets(t *testing.T) {
+	var T = new(TypedTrie32[int])
+	for i, s := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.1.2.128/25"} {
+		T.Insert(netip.MustParsePrefix(s), i)
+	}
+	var got []string
+	var values []int
+	for p, v := range T.Supernets([]byte{10, 1, 2, 200}, 32) {
+		got = append(got, p.String())
+		values = append(values, v)
+	}
+	if want := []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.128/25"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if !slices.Equal(values, []int{0, 1, 2, 3, 5}) {
+		t.Error("Unexpected values", values)
+	}
+
+	got = got[:0]
+	for p := range T.Supernets([]byte{10, 1, 0, 0}, 16) {
+		got = append(got, p.String())
+	}
+	if want := []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	var trie = T.Trie()
+	shallow := testing.AllocsPerRun(100, func() {
+		for range trie.Supernets([]byte{11, 0, 0, 0}, 32) {
+		}
+	})
+	deep := testing.AllocsPerRun(100, func() {
+		for range trie.Supernets([]byte{10, 1, 2, 200}, 32) {
+		}
+	})
+	if deep > shallow {
+		t.Errorf("Supernets allocates per step: %v allocations for 1 match, %v for 5", shallow, deep)
+	}
+}
+
+func TestTrieGaps(t *testing.T) {
+	T := mkTrie32("10.20.0.0/16", "net", "10.20.0.0/24", "a", "10.20.2.0/23", "b", "10.20.128.0/17", "c", "10.20.1.0/26", "d", "11.0.0.0/8", "e")
+	gaps := func(p string, maxLen byte) []string {
+		var res []string
+		pfx := netip.MustParsePrefix(p)
+		ip := pfx.Addr().As4()
+		for g := range T.Gaps(ip[:], byte(pfx.Bits()), maxLen) {
+			res = append(res, g.String())
+		}
+		return res
+	}
+	want := []string{"10.20.1.64/26", "10.20.1.128/25", "10.20.4.0/22", "10.20.8.0/21", "10.20.16.0/20", "10.20.32.0/19", "10.20.64.0/18"}
+	if got := gaps("10.20.0.0/16", 32); !slices.Equal(got, want) {
+		t.Errorf("Expected gaps\n%v\ngot\n%v", want, got)
+	}
+	if got := gaps("10.20.0.0/16", 24); !slices.Equal(got, want[2:]) {
+		t.Errorf("Expected gaps of /24 or more\n%v\ngot\n%v", want[2:], got)
+	}
+	if got := gaps("10.20.128.0/18", 32); got != nil {
+		t.Errorf("Expected no gaps inside covered prefix, got %v", got)
+	}
+	if got := gaps("12.0.0.0/8", 32); !slices.Equal(got, []string{"12.0.0.0/8"}) {
+		t.Errorf("Expected whole empty prefix, got %v", got)
+	}
+	if got := gaps("11.0.0.0/8", 32); !slices.Equal(got, []string{"11.0.0.0/8"}) {
+		t.Errorf("Entry for prefix itself should not cover it, got %v", got)
+	}
+}
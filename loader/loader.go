@@ -0,0 +1,337 @@
+// Package loader streams text tables of prefixes (or address ranges) and
+// values into iptrie tries.
+package loader
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"unsafe"
+
+	"github.com/asergeyev/iptrie"
+)
+
+// Format tells how fields of a line are separated.
+type Format byte
+
+const (
+	// CSV is comma separated, fields could be quoted but not span lines.
+	CSV Format = iota
+	// TSV is tab separated.
+	TSV
+	// Whitespace separates fields by any run of spaces and tabs.
+	Whitespace
+)
+
+// Mode tells what to do with lines that fail to parse or insert.
+type Mode byte
+
+const (
+	// FailFast stops at first bad line.
+	FailFast Mode = iota
+	// Collect skips bad lines and reports all of them at the end.
+	Collect
+)
+
+// Config describes input. Columns are numbered from 1, 0 means no column.
+// Zero Config reads CSV with prefixes in first column and no values.
+type Config struct {
+	Format Format
+	// Comment starts lines that are skipped, e.g. "#". Blank lines are
+	// always skipped.
+	Comment string
+	// SkipLines is number of header lines to skip.
+	SkipLines int
+	// Prefix is column with CIDR prefix or single address, it is 1 unless
+	// Start or End is set.
+	Prefix int
+	// Start and End are columns with first and last address of a range,
+	// used instead of Prefix. Both of them have to be set.
+	Start, End int
+	// Value is column with value.
+	Value int
+	Mode  Mode
+}
+
+var (
+	// ErrMissingColumn is returned for lines with less columns than Config needs.
+	ErrMissingColumn = errors.New("loader: missing column")
+	// ErrBadConfig is returned by Load for Config with negative columns or
+	// without a way to read addresses: it needs Prefix or both Start and End.
+	ErrBadConfig = errors.New("loader: bad config")
+	// ErrMixedFamilies is returned for ranges with IPv4 start and IPv6 end or
+	// other way around.
+	ErrMixedFamilies = errors.New("loader: range start and end are of different families")
+)
+
+// LineError is error of a particular input line.
+type LineError struct {
+	Line int // starting from 1
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// LineErrors are bad lines found in Collect mode.
+type LineErrors []*LineError
+
+func (e LineErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more bad lines)", e[0], len(e)-1)
+}
+
+func (e LineErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Record is one parsed line.
+type Record struct {
+	Line       int
+	Start, End netip.Addr   // first and last address, zone is dropped
+	Prefix     netip.Prefix // masked prefix, valid only for prefix lines
+	Value      string       // empty without Value column
+	Fields     []string     // all fields of the line
+}
+
+// Keys returns Start and End as keys of a trie of width bits:
+//
+//   - 32 bit keys are IPv4 addresses.
+//   - 64 bit keys are IPv6 /64 networks, so rows have to cover whole /64s
+//     and IPv4 rows are rejected.
+//   - 128 bit keys are IPv6 addresses, IPv4 is mapped into ::ffff:0:0/96.
+//   - Wider keys begin with the 128 bit key and are padded, so a range
+//     covers all keys that begin with its addresses.
+//
+// Rows that can't be stored are ErrFamilyMismatch or ErrPrefixTooLong.
+func (r Record) Keys(width int) (start, end []byte, err error) {
+	from, to := r.Start, r.End
+	switch {
+	case from.Is4() && width == 64:
+		return nil, nil, fmt.Errorf("%w: 64 bit trie keeps IPv6 /64 networks, not IPv4", iptrie.ErrFamilyMismatch)
+	case from.Is4() && width > 32:
+		from, to = netip.AddrFrom16(from.As16()), netip.AddrFrom16(to.As16())
+	}
+	start, end = from.AsSlice(), to.AsSlice()
+	if width == 64 && len(start) == 16 {
+		if !filled(start[8:], 0) || !filled(end[8:], 0xff) {
+			return nil, nil, fmt.Errorf("%w: 64 bit trie keeps whole /64 networks only", iptrie.ErrPrefixTooLong)
+		}
+		return start[:8], end[:8], nil
+	}
+	if len(start)*8 > width {
+		return nil, nil, iptrie.ErrFamilyMismatch
+	}
+	for len(start)*8 < width {
+		start, end = append(start, 0), append(end, 0xff)
+	}
+	return start, end, nil
+}
+
+// filled tells if all bytes of b are c.
+func filled(b []byte, c byte) bool {
+	for _, x := range b {
+		if x != c {
+			return false
+		}
+	}
+	return true
+}
+
+// Inserter is any width of iptrie.Trie (Trie32, Trie64, Trie128, Trie160).
+// Typed tries are loaded thru their Trie, see IntoTyped.
+type Inserter interface {
+	Width() byte
+	InsertRange(start, end []byte, value unsafe.Pointer) (int, error)
+}
+
+// Into returns insert function for Load that puts records into t with
+// values made by value (nil value leaves them nil). Keys are made for
+// width of t, see Record.Keys.
+func Into(t Inserter, value func(Record) (unsafe.Pointer, error)) func(Record) error {
+	width := int(t.Width())
+	return func(r Record) error {
+		start, end, err := r.Keys(width)
+		if err != nil {
+			return err
+		}
+		var p unsafe.Pointer
+		if value != nil {
+			if p, err = value(r); err != nil {
+				return err
+			}
+		}
+		_, err = t.InsertRange(start, end, p)
+		return err
+	}
+}
+
+// IntoTyped is Into for typed tries, t is Trie of iptrie.TypedTrie32 or
+// other width with values of type V.
+func IntoTyped[V any](t Inserter, value func(Record) (V, error)) func(Record) error {
+	return Into(t, func(r Record) (unsafe.Pointer, error) {
+		v, err := value(r)
+		if err != nil {
+			return nil, err
+		}
+		return unsafe.Pointer(&v), nil // typed tries keep *V
+	})
+}
+
+// Load reads records from r and calls insert for each of them. Errors of
+// parsing and of insert are reported as *LineError, in Collect mode Load
+// goes on and returns LineErrors with all of them at the end. Errors of
+// reading stop Load in both modes.
+func Load(r io.Reader, cfg Config, insert func(Record) error) error {
+	if cfg.Prefix == 0 && cfg.Start == 0 && cfg.End == 0 {
+		cfg.Prefix = 1
+	}
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	var bad LineErrors
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line <= cfg.SkipLines || text == "" || cfg.Comment != "" && strings.HasPrefix(text, cfg.Comment) {
+			continue
+		}
+		rec, err := cfg.parse(text)
+		if err == nil {
+			rec.Line = line
+			err = insert(rec)
+		}
+		if err != nil {
+			lerr := &LineError{line, err}
+			if cfg.Mode == FailFast {
+				return lerr
+			}
+			bad = append(bad, lerr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(bad) > 0 {
+		return bad
+	}
+	return nil
+}
+
+func (cfg *Config) check() error {
+	switch {
+	case cfg.Prefix < 0 || cfg.Start < 0 || cfg.End < 0 || cfg.Value < 0:
+		return fmt.Errorf("%w: negative column", ErrBadConfig)
+	case cfg.Prefix != 0 && (cfg.Start != 0 || cfg.End != 0):
+		return fmt.Errorf("%w: both prefix and range columns", ErrBadConfig)
+	case cfg.Prefix == 0 && (cfg.Start == 0 || cfg.End == 0):
+		return fmt.Errorf("%w: range needs both start and end columns", ErrBadConfig)
+	}
+	return nil
+}
+
+func (cfg *Config) split(text string) ([]string, error) {
+	var fields []string
+	switch cfg.Format {
+	case Whitespace:
+		return strings.Fields(text), nil
+	case TSV:
+		fields = strings.Split(text, "\t")
+	default:
+		if !strings.Contains(text, `"`) {
+			fields = strings.Split(text, ",")
+			break
+		}
+		var err error
+		if fields, err = csv.NewReader(strings.NewReader(text)).Read(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+func (cfg *Config) parse(text string) (rec Record, err error) {
+	if rec.Fields, err = cfg.split(text); err != nil {
+		return
+	}
+	field := func(col int) (string, error) {
+		if col > len(rec.Fields) {
+			return "", ErrMissingColumn
+		}
+		return rec.Fields[col-1], nil
+	}
+
+	if cfg.Value != 0 {
+		if rec.Value, err = field(cfg.Value); err != nil {
+			return
+		}
+	}
+	if cfg.Prefix != 0 {
+		var s string
+		if s, err = field(cfg.Prefix); err != nil {
+			return
+		}
+		if rec.Prefix, err = parsePrefix(s); err != nil {
+			return
+		}
+		rec.Start, rec.End = iptrie.RangeOf(rec.Prefix)
+		return
+	}
+
+	var start, end string
+	if start, err = field(cfg.Start); err != nil {
+		return
+	}
+	if end, err = field(cfg.End); err != nil {
+		return
+	}
+	if rec.Start, err = netip.ParseAddr(start); err != nil {
+		return
+	}
+	if rec.End, err = netip.ParseAddr(end); err != nil {
+		return
+	}
+	rec.Start, rec.End = rec.Start.WithZone(""), rec.End.WithZone("")
+	switch {
+	case rec.Start.Is4() != rec.End.Is4():
+		err = ErrMixedFamilies
+	case rec.End.Less(rec.Start):
+		err = iptrie.ErrInvalidRange
+	}
+	return
+}
+
+// parsePrefix takes CIDR prefix or single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.WithZone("")
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	return p.Masked(), err
+}
@@ -0,0 +1,187 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/asergeyev/iptrie"
+)
+
+func collect(t *testing.T, input string, cfg Config) ([]string, error) {
+	t.Helper()
+	var res []string
+	err := Load(strings.NewReader(input), cfg, func(r Record) error {
+		res = append(res, fmt.Sprintf("%d:%v-%v=%s", r.Line, r.Start, r.End, r.Value))
+		return nil
+	})
+	return res, err
+}
+
+func TestLoad(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+		cfg   Config
+		want  []string
+	}{
+		{"csv", "prefix,value\n# comment\n10.0.0.0/8, a\n\n\"10.1.0.0/16\",\"b, c\"\n", Config{SkipLines: 1, Comment: "#", Value: 2},
+			[]string{"3:10.0.0.0-10.255.255.255=a", "5:10.1.0.0-10.1.255.255=b, c"}},
+		{"tsv", "x\t10.0.0.5/24\n", Config{Format: TSV, Prefix: 2, Value: 1},
+			[]string{"1:10.0.0.0-10.0.0.255=x"}},
+		{"whitespace range", "10.0.0.1   10.0.0.6\tx y\n2001:db8::  2001:db8::ff z\n", Config{Format: Whitespace, Start: 1, End: 2, Value: 3},
+			[]string{"1:10.0.0.1-10.0.0.6=x", "2:2001:db8::-2001:db8::ff=z"}},
+		{"single address", "192.168.1.1\n", Config{},
+			[]string{"1:192.168.1.1-192.168.1.1="}},
+	}
+	for _, tst := range tests {
+		got, err := collect(t, tst.input, tst.cfg)
+		if err != nil {
+			t.Errorf("%s: %v", tst.name, err)
+		}
+		if !slices.Equal(got, tst.want) {
+			t.Errorf("%s: expected\n%v\ngot\n%v", tst.name, tst.want, got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	input := "10.0.0.0/8,a\nbogus,b\n10.0.0.9,10.0.0.1,c\n11.0.0.0/8\n::1,10.0.0.1,d\n"
+	_, err := collect(t, input, Config{Value: 2})
+	var lerr *LineError
+	if !errors.As(err, &lerr) || lerr.Line != 2 {
+		t.Errorf("Expected error on line 2, got %v", err)
+	}
+
+	got, err := collect(t, input, Config{Start: 1, End: 2, Value: 3, Mode: Collect})
+	var bad LineErrors
+	if !errors.As(err, &bad) || len(bad) != 5 {
+		t.Fatalf("Expected 5 bad lines, got %v", err)
+	}
+	if bad[2].Line != 3 || !errors.Is(bad[2], iptrie.ErrInvalidRange) {
+		t.Errorf("Expected invalid range on line 3, got %v", bad[2])
+	}
+	if bad[3].Line != 4 || !errors.Is(bad[3], ErrMissingColumn) {
+		t.Errorf("Expected missing column on line 4, got %v", bad[3])
+	}
+	if bad[4].Line != 5 || !errors.Is(bad[4], ErrMixedFamilies) {
+		t.Errorf("Expected mixed families on line 5, got %v", bad[4])
+	}
+	if len(got) != 0 {
+		t.Errorf("Expected no good lines, got %v", got)
+	}
+}
+
+func TestLoadBadConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{Start: 1},
+		{End: 2},
+		{Value: 2, Start: 1},
+		{Prefix: 1, Start: 2, End: 3},
+		{Prefix: 1, End: 2},
+		{Prefix: -1},
+		{Start: -1, End: 2},
+		{Start: 1, End: -2},
+		{Value: -1},
+	} {
+		called := false
+		err := Load(strings.NewReader("10.0.0.0,10.0.0.1,10.0.0.2\n"), cfg, func(Record) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, ErrBadConfig) || called {
+			t.Errorf("Expected ErrBadConfig for %+v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestLoadInto(t *testing.T) {
+	input := "10.0.0.0/8,a\n10.0.0.0,10.0.0.2,b\n2001:db8::/32,c\n"
+	value := func(r Record) (unsafe.Pointer, error) {
+		s := r.Value
+		return unsafe.Pointer(&s), nil
+	}
+
+	T := new(iptrie.Trie32)
+	err := Load(strings.NewReader(input), Config{Start: 1, End: 2, Value: 3, Mode: Collect}, Into(T, value))
+	var bad LineErrors
+	if !errors.As(err, &bad) || len(bad) != 2 || bad[1].Line != 3 {
+		t.Errorf("Expected prefix lines to fail as ranges, got %v", err)
+	}
+	err = Load(strings.NewReader(input), Config{Value: 2, Mode: Collect}, Into(T, value))
+	if !errors.As(err, &bad) || len(bad) != 1 || bad[0].Line != 3 || !errors.Is(err, iptrie.ErrFamilyMismatch) {
+		t.Errorf("Expected IPv6 line to fail for 32 bit trie, got %v", err)
+	}
+	if _, _, ln, v := T.Get([]byte{10, 0, 0, 2}, 32); ln != 32 || *(*string)(v) != "b" {
+		t.Errorf("Expected 10.0.0.2/32=b, got /%d", ln)
+	}
+	if _, _, ln, v := T.Get([]byte{10, 0, 0, 3}, 32); ln != 8 || *(*string)(v) != "a" {
+		t.Errorf("Expected 10.0.0.0/8=a, got /%d", ln)
+	}
+
+	M := new(iptrie.Trie128)
+	if err := Load(strings.NewReader("1.2.3.0/24,a\n2001:db8::/32,b\n"), Config{Value: 2}, Into(M, value)); err != nil {
+		t.Fatal(err)
+	}
+	mapped := netip.MustParseAddr("::ffff:1.2.3.4").AsSlice()
+	if _, _, ln, v := M.Get(mapped, 128); ln != 120 || *(*string)(v) != "a" {
+		t.Errorf("Expected ::ffff:1.2.3.0/120=a in 128 bit trie, got /%d", ln)
+	}
+	if _, _, _, v := M.Get(netip.MustParseAddr("102:300::1").AsSlice(), 128); v != nil {
+		t.Errorf("IPv4 row should not be stored as 102:300::/24")
+	}
+	if _, _, ln, v := M.Get(netip.MustParseAddr("2001:db8::1").AsSlice(), 128); ln != 32 || *(*string)(v) != "b" {
+		t.Errorf("Expected 2001:db8::/32=b in 128 bit trie, got /%d", ln)
+	}
+
+	W := new(iptrie.Trie160)
+	if err := Load(strings.NewReader("2001:db8::/32,c\n"), Config{Value: 2}, Into(W, value)); err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 20)
+	copy(key, []byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	if _, _, ln, v := W.Get(key, 160); ln != 32 || *(*string)(v) != "c" {
+		t.Errorf("Expected 2001:db8::/32=c in 160 bit trie, got /%d", ln)
+	}
+}
+
+func TestLoadInto64(t *testing.T) {
+	T := new(iptrie.TypedTrie64[string])
+	insert := IntoTyped(T.Trie(), func(r Record) (string, error) {
+		return r.Value, nil
+	})
+	err := Load(strings.NewReader("2001:db8::/48,a\n10.0.0.0/8,c\n2001:db8:0:7::/80,d\n"), Config{Value: 2, Mode: Collect}, insert)
+	var bad LineErrors
+	if !errors.As(err, &bad) || len(bad) != 2 {
+		t.Fatalf("Expected 2 bad lines, got %v", err)
+	}
+	if bad[0].Line != 2 || !errors.Is(bad[0], iptrie.ErrFamilyMismatch) {
+		t.Errorf("Expected IPv4 line to fail for 64 bit trie, got %v", bad[0])
+	}
+	if bad[1].Line != 3 || !errors.Is(bad[1], iptrie.ErrPrefixTooLong) {
+		t.Errorf("Expected /80 to fail for 64 bit trie, got %v", bad[1])
+	}
+
+	input := "2001:db8:0:5::,2001:db8:0:6:ffff:ffff:ffff:ffff,b\n2001:db8:0:8::1,2001:db8:0:8::ff,e\n"
+	err = Load(strings.NewReader(input), Config{Start: 1, End: 2, Value: 3, Mode: Collect}, insert)
+	if !errors.As(err, &bad) || len(bad) != 1 || bad[0].Line != 2 || !errors.Is(err, iptrie.ErrPrefixTooLong) {
+		t.Errorf("Expected range inside of a /64 to fail, got %v", err)
+	}
+	for _, tst := range []struct {
+		key   []byte
+		ln    byte
+		value string
+	}{
+		{[]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 5}, 64, "b"},
+		{[]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 6}, 64, "b"},
+		{[]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 7}, 48, "a"},
+	} {
+		if _, _, ln, v := T.Get(tst.key, 64); ln != tst.ln || v != tst.value {
+			t.Errorf("Expected %v to match /%d=%s, got /%d=%s", tst.key, tst.ln, tst.value, ln, v)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads whole file where mmap is not available.
+func mapFile(f *os.File) ([]byte, func([]byte) error, error) {
+	data, err := io.ReadAll(f)
+	return data, nil, err
+}
@@ -0,0 +1,208 @@
+package mmdb
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Data section types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxNesting limits depth of maps and arrays, so broken data can't run
+// decoder out of stack.
+const maxNesting = 512
+
+// decoder reads values of a data section. Values found thru pointers are
+// cached by offset, so records sharing data share value too.
+type decoder struct {
+	data  []byte
+	cache map[int]any
+}
+
+func (d *decoder) errorf(offset int, format string, args ...any) error {
+	return fmt.Errorf("%w: data at %d: %s", ErrFormat, offset, fmt.Sprintf(format, args...))
+}
+
+// control reads control byte (and extension bytes) at offset, it returns
+// type, size and offset of the payload.
+func (d *decoder) control(offset int) (typ, size, next int, err error) {
+	if offset < 0 || offset >= len(d.data) {
+		return 0, 0, 0, d.errorf(offset, "out of data section")
+	}
+	ctrl := d.data[offset]
+	next = offset + 1
+	typ = int(ctrl >> 5)
+	if typ == typePointer {
+		return typ, int(ctrl & 0x1f), next, nil
+	}
+	if typ == typeExtended {
+		if next >= len(d.data) {
+			return 0, 0, 0, d.errorf(offset, "truncated type")
+		}
+		typ = 7 + int(d.data[next])
+		next++
+		if typ < typeInt32 {
+			return 0, 0, 0, d.errorf(offset, "bad extended type %d", typ)
+		}
+	}
+	size = int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28 // 1, 2 or 3 more bytes
+		if next+n > len(d.data) {
+			return 0, 0, 0, d.errorf(offset, "truncated size")
+		}
+		extra := 0
+		for _, b := range d.data[next : next+n] {
+			extra = extra<<8 | int(b)
+		}
+		next += n
+		size = []int{29, 285, 65821}[n-1] + extra
+	}
+	return typ, size, next, nil
+}
+
+// decode returns value at offset and offset of what follows it.
+func (d *decoder) decode(offset, depth int) (any, int, error) {
+	typ, size, next, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		ptr, after, err := d.pointer(size, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		if v, ok := d.cache[ptr]; ok {
+			return v, after, nil
+		}
+		if t, _, _, err := d.control(ptr); err == nil && t == typePointer {
+			return nil, 0, d.errorf(ptr, "pointer to pointer")
+		}
+		v, _, err := d.decode(ptr, depth)
+		if err == nil && d.cache != nil {
+			d.cache[ptr] = v
+		}
+		return v, after, err
+	}
+	if depth > maxNesting {
+		return nil, 0, d.errorf(offset, "nested too deep")
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for i := 0; i < size; i++ {
+			var k, v any
+			if k, next, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, d.errorf(offset, "map key is %T", k)
+			}
+			if v, next, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, next, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for i := 0; i < size; i++ {
+			var v any
+			if v, next, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, next, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, d.errorf(offset, "bool of size %d", size)
+		}
+		return size == 1, next, nil
+	}
+
+	if next+size > len(d.data) {
+		return nil, 0, d.errorf(offset, "value is out of data section")
+	}
+	b := d.data[next : next+size]
+	next += size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, d.errorf(offset, "double of size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, d.errorf(offset, "float of size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeInt32, typeUint64:
+		if size > []int{typeUint16: 2, typeUint32: 4, typeInt32: 4, typeUint64: 8}[typ] {
+			return nil, 0, d.errorf(offset, "integer of size %d", size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		switch typ {
+		case typeUint16:
+			return uint16(n), next, nil
+		case typeUint32:
+			return uint32(n), next, nil
+		case typeInt32:
+			return int32(uint32(n)), next, nil
+		}
+		return n, next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, d.errorf(offset, "integer of size %d", size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, d.errorf(offset, "unsupported type %d", typ)
+}
+
+// pointer decodes pointer with size bits from control byte.
+func (d *decoder) pointer(size, next int) (int, int, error) {
+	n := size>>3 + 1
+	if next+n > len(d.data) {
+		return 0, 0, d.errorf(next, "truncated pointer")
+	}
+	p := 0
+	if n < 4 {
+		p = size & 7
+	}
+	for _, b := range d.data[next : next+n] {
+		p = p<<8 | int(b)
+	}
+	p += []int{0, 2048, 526336, 0}[n-1]
+	return p, next + n, nil
+}
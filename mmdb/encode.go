@@ -0,0 +1,289 @@
+package mmdb
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"sort"
+
+	"github.com/asergeyev/iptrie"
+)
+
+// encoder builds data section, equal values are stored once.
+type encoder struct {
+	data    []byte
+	offsets map[string]int
+}
+
+// control appends control byte for typ and size.
+func control(b []byte, typ, size int) []byte {
+	var ext []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		ext, size = []byte{byte(size - 29)}, 29
+	case size < 65821:
+		size -= 285
+		ext, size = []byte{byte(size >> 8), byte(size)}, 30
+	default:
+		size -= 65821
+		ext, size = []byte{byte(size >> 16), byte(size >> 8), byte(size)}, 31
+	}
+	if typ > 7 {
+		b = append(b, byte(size), byte(typ-7))
+	} else {
+		b = append(b, byte(typ<<5|size))
+	}
+	return append(b, ext...)
+}
+
+// uintValue appends unsigned integer using as few bytes as possible.
+func uintValue(b []byte, typ int, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	v := bytes.TrimLeft(buf[:], "\x00")
+	return append(control(b, typ, len(v)), v...)
+}
+
+// value appends encoded v to b.
+func value(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return append(control(b, typeString, len(v)), v...), nil
+	case []byte:
+		return append(control(b, typeBytes, len(v)), v...), nil
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		return control(b, typeBool, size), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(control(b, typeDouble, 8), math.Float64bits(v)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(control(b, typeFloat, 4), math.Float32bits(v)), nil
+	case uint16:
+		return uintValue(b, typeUint16, uint64(v)), nil
+	case uint32:
+		return uintValue(b, typeUint32, uint64(v)), nil
+	case uint64:
+		return uintValue(b, typeUint64, v), nil
+	case int32:
+		if v < 0 {
+			// only full size int32 could be negative
+			return binary.BigEndian.AppendUint32(control(b, typeInt32, 4), uint32(v)), nil
+		}
+		return uintValue(b, typeInt32, uint64(v)), nil
+	case int:
+		switch {
+		case v >= 0 && uint64(v) <= math.MaxUint32:
+			return uintValue(b, typeUint32, uint64(v)), nil
+		case v > 0:
+			return uintValue(b, typeUint64, uint64(v)), nil
+		case v >= math.MinInt32:
+			return value(b, int32(v))
+		}
+	case uint:
+		if v <= math.MaxUint32 {
+			return uintValue(b, typeUint32, uint64(v)), nil
+		}
+		return uintValue(b, typeUint64, uint64(v)), nil
+	case *big.Int:
+		if v.Sign() >= 0 && v.BitLen() <= 128 {
+			n := v.Bytes()
+			return append(control(b, typeUint128, len(n)), n...), nil
+		}
+	case []any:
+		var err error
+		b = control(b, typeArray, len(v))
+		for _, item := range v {
+			if b, err = value(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var err error
+		b = control(b, typeMap, len(v))
+		for _, k := range keys {
+			b, _ = value(b, k)
+			if b, err = value(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("mmdb: can't encode %T value %v", v, v)
+}
+
+// add stores v in data section and returns its offset.
+func (e *encoder) add(v any) (int, error) {
+	b, err := value(nil, v)
+	if err != nil {
+		return 0, err
+	}
+	if offset, ok := e.offsets[string(b)]; ok {
+		return offset, nil
+	}
+	offset := len(e.data)
+	e.offsets[string(b)] = offset
+	e.data = append(e.data, b...)
+	return offset, nil
+}
+
+// record is a search tree record before node numbers and data offsets
+// are final: node index, data offset or nothing.
+type record struct {
+	kind byte // 0 empty, 1 node, 2 data
+	n    int
+}
+
+type entry struct {
+	key   [16]byte
+	bits  int
+	value int // data offset
+}
+
+type builder struct {
+	entries []entry
+	nodes   [][2]record // children go before parents
+}
+
+// build returns record for key/depth made of entries lo..hi, all of them
+// inside key/depth. Cover is record of the closest entry above.
+func (b *builder) build(lo, hi int, key [16]byte, depth, width int, cover record) record {
+	if lo < hi && b.entries[lo].bits == depth {
+		cover = record{2, b.entries[lo].value}
+		lo++
+	}
+	if lo == hi || depth == width {
+		return cover
+	}
+	mask := byte(0x80) >> (depth % 8)
+	mid := lo + sort.Search(hi-lo, func(i int) bool { return b.entries[lo+i].key[depth/8]&mask != 0 })
+	left := b.build(lo, mid, key, depth+1, width, cover)
+	key[depth/8] |= mask
+	right := b.build(mid, hi, key, depth+1, width, cover)
+	if left.kind != 1 && left == right {
+		return left // both halves have same data
+	}
+	b.nodes = append(b.nodes, [2]record{left, right})
+	return record{1, len(b.nodes) - 1}
+}
+
+// Write writes t as database. IPv4 entries go to ::/96 of IPv6 database,
+// with IPVersion 4 t must have no IPv6 entries. No IPv4 aliases are made.
+// NodeCount and RecordSize of meta are set to what was written. DatabaseType
+// and Description are required, readers reject databases without them.
+func Write(w io.Writer, t *iptrie.DualTrie[any], meta *Metadata) (int64, error) {
+	if meta.DatabaseType == "" || len(meta.Description) == 0 {
+		return 0, fmt.Errorf("mmdb: database type and description are required")
+	}
+	if meta.IPVersion == 0 {
+		meta.IPVersion = 6
+	}
+	width := 128
+	switch meta.IPVersion {
+	case 4:
+		if t.V6().Len() != 0 {
+			return 0, fmt.Errorf("mmdb: IPv6 entries in IPv4 database")
+		}
+		width = 32
+	case 6:
+	default:
+		return 0, fmt.Errorf("mmdb: ip version %d", meta.IPVersion)
+	}
+
+	e := encoder{offsets: make(map[string]int)}
+	var b builder
+	for p, v := range t.All() {
+		offset, err := e.add(v)
+		if err != nil {
+			return 0, fmt.Errorf("%v: %w", p, err)
+		}
+		ent := entry{bits: p.Bits(), value: offset}
+		if p.Addr().Is4() {
+			a := p.Addr().As4()
+			if width == 128 {
+				copy(ent.key[12:], a[:])
+				ent.bits += 96
+			} else {
+				copy(ent.key[:], a[:])
+			}
+		} else {
+			ent.key = p.Addr().As16()
+		}
+		b.entries = append(b.entries, ent)
+	}
+	slices.SortFunc(b.entries, func(x, y entry) int {
+		if c := bytes.Compare(x.key[:], y.key[:]); c != 0 {
+			return c
+		}
+		return x.bits - y.bits
+	})
+
+	root := b.build(0, len(b.entries), [16]byte{}, 0, width, record{})
+	if root.kind != 1 {
+		// root is always a node
+		b.nodes = append(b.nodes, [2]record{root, root})
+	}
+	count := len(b.nodes)
+	largest := uint64(count) + dataSeparator + uint64(len(e.data))
+	switch {
+	case largest < 1<<24:
+		meta.RecordSize = 24
+	case largest < 1<<28:
+		meta.RecordSize = 28
+	case largest < 1<<32:
+		meta.RecordSize = 32
+	default:
+		return 0, ErrTooLarge
+	}
+	meta.NodeCount = uint32(count)
+
+	out := make([]byte, 0, count*int(meta.RecordSize)/4+dataSeparator)
+	for i := count - 1; i >= 0; i-- {
+		// root is last, numbers go in reverse
+		var rec [2]uint32
+		for j, r := range b.nodes[i] {
+			switch r.kind {
+			case 0:
+				rec[j] = uint32(count)
+			case 1:
+				rec[j] = uint32(count - 1 - r.n)
+			case 2:
+				rec[j] = uint32(count + dataSeparator + r.n)
+			}
+		}
+		out = appendNode(out, meta.RecordSize, rec[0], rec[1])
+	}
+	out = append(out, make([]byte, dataSeparator)...)
+	out = append(out, e.data...)
+	out = append(out, metadataMarker...)
+	out, _ = value(out, meta.toMap())
+
+	n, err := w.Write(out)
+	return int64(n), err
+}
+
+func appendNode(b []byte, size uint16, left, right uint32) []byte {
+	switch size {
+	case 24:
+		return append(b, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	case 28:
+		return append(b, byte(left>>16), byte(left>>8), byte(left),
+			byte(left>>24&0x0f)<<4|byte(right>>24&0x0f), byte(right>>16), byte(right>>8), byte(right))
+	}
+	return append(b, byte(left>>24), byte(left>>16), byte(left>>8), byte(left), byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+}
@@ -0,0 +1,266 @@
+// Package mmdb reads and writes MaxMind DB files (format version 2.0)
+// to and from iptrie tries.
+//
+// Values are map[string]any, []any, string, []byte, bool, float64,
+// float32, uint16, uint32, uint64, int32 and *big.Int (uint128). Writer
+// also takes int and uint and picks the smallest fitting type for them.
+//
+// IPv4 data of IPv6 databases lives at ::a.b.c.d (::/96), it goes to IPv4
+// part of a DualTrie and back.
+package mmdb
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/asergeyev/iptrie"
+)
+
+// metadataMarker starts metadata section at the end of file.
+const metadataMarker = "\xab\xcd\xefMaxMind.com"
+
+// dataSeparator is size of zero bytes between search tree and data.
+const dataSeparator = 16
+
+var (
+	// ErrFormat is returned for files that are not valid MaxMind DBs.
+	ErrFormat = errors.New("mmdb: invalid database")
+	// ErrTooLarge is returned when database does not fit 32 bit records.
+	ErrTooLarge = errors.New("mmdb: database too large")
+)
+
+// Metadata describes database.
+type Metadata struct {
+	NodeCount    uint32 // set by Write
+	RecordSize   uint16 // set by Write: 24, 28 or 32
+	IPVersion    uint16 // 4 or 6, Write uses 6 for 0
+	DatabaseType string
+	Languages    []string
+	Description  map[string]string
+	BuildEpoch   uint64
+	// FormatMajor and FormatMinor are 2 and 0, Write ignores them.
+	FormatMajor, FormatMinor uint16
+}
+
+func (m *Metadata) fromMap(v any) error {
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: metadata is not a map", ErrFormat)
+	}
+	number := func(key string) uint64 {
+		switch n := meta[key].(type) {
+		case uint16:
+			return uint64(n)
+		case uint32:
+			return uint64(n)
+		case uint64:
+			return n
+		}
+		return 0
+	}
+	m.NodeCount = uint32(number("node_count"))
+	m.RecordSize = uint16(number("record_size"))
+	m.IPVersion = uint16(number("ip_version"))
+	m.BuildEpoch = number("build_epoch")
+	m.FormatMajor = uint16(number("binary_format_major_version"))
+	m.FormatMinor = uint16(number("binary_format_minor_version"))
+	m.DatabaseType, _ = meta["database_type"].(string)
+	if langs, ok := meta["languages"].([]any); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				m.Languages = append(m.Languages, s)
+			}
+		}
+	}
+	if desc, ok := meta["description"].(map[string]any); ok {
+		m.Description = make(map[string]string, len(desc))
+		for k, v := range desc {
+			m.Description[k], _ = v.(string)
+		}
+	}
+	switch {
+	case m.FormatMajor != 2:
+		return fmt.Errorf("%w: unsupported format version %d", ErrFormat, m.FormatMajor)
+	case m.RecordSize != 24 && m.RecordSize != 28 && m.RecordSize != 32:
+		return fmt.Errorf("%w: record size %d", ErrFormat, m.RecordSize)
+	case m.IPVersion != 4 && m.IPVersion != 6:
+		return fmt.Errorf("%w: ip version %d", ErrFormat, m.IPVersion)
+	}
+	return nil
+}
+
+func (m *Metadata) toMap() map[string]any {
+	langs := make([]any, len(m.Languages))
+	for i, l := range m.Languages {
+		langs[i] = l
+	}
+	desc := make(map[string]any, len(m.Description))
+	for k, v := range m.Description {
+		desc[k] = v
+	}
+	return map[string]any{
+		"node_count":                  m.NodeCount,
+		"record_size":                 m.RecordSize,
+		"ip_version":                  m.IPVersion,
+		"database_type":               m.DatabaseType,
+		"languages":                   langs,
+		"description":                 desc,
+		"build_epoch":                 m.BuildEpoch,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+	}
+}
+
+// Open reads database file, see Read.
+func Open(path string) (*iptrie.DualTrie[any], *Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Read(data)
+}
+
+// Read builds trie straight from search tree and data section of database.
+// Every network with data becomes an entry, records that share data share
+// decoded value. IPv4 aliases of IPv6 databases (::ffff:0:0/96, 2002::/16
+// and alike, records that point at the node of ::/96) are skipped, other
+// subtrees shared by several networks are read for each of them. Walk of
+// the tree is limited to walkLimit times NodeCount nodes, so files made of
+// heavily shared nodes fail with ErrFormat instead of loading forever.
+func Read(data []byte) (*iptrie.DualTrie[any], *Metadata, error) {
+	at := bytes.LastIndex(data, []byte(metadataMarker))
+	if at < 0 {
+		return nil, nil, fmt.Errorf("%w: no metadata", ErrFormat)
+	}
+	meta := new(Metadata)
+	md := decoder{data: data[at+len(metadataMarker):]}
+	v, _, err := md.decode(0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = meta.fromMap(v); err != nil {
+		return nil, nil, err
+	}
+
+	treeSize := int(meta.NodeCount) * int(meta.RecordSize) / 4
+	if treeSize+dataSeparator > at {
+		return nil, nil, fmt.Errorf("%w: search tree is larger than file", ErrFormat)
+	}
+	r := reader{
+		tree: data[:treeSize],
+		meta: meta,
+		d:    decoder{data: data[treeSize+dataSeparator : at], cache: make(map[int]any)},
+		ipv4: meta.NodeCount,
+		left: walkLimit * uint64(meta.NodeCount),
+		trie: iptrie.NewDualTrie[any](iptrie.MappedAsIPv4),
+	}
+	if meta.IPVersion == 6 {
+		r.ipv4 = r.ipv4Start()
+	}
+	if err = r.walk(0, [16]byte{}, 0); err != nil {
+		return nil, nil, err
+	}
+	return r.trie, meta, nil
+}
+
+type reader struct {
+	tree []byte
+	meta *Metadata
+	d    decoder
+	ipv4 uint32 // node of ::/96 where aliases point, NodeCount if none
+	left uint64 // nodes walk could still visit
+	trie *iptrie.DualTrie[any]
+}
+
+// walkLimit is how many times walk could visit every node on average.
+// Shared subtrees are visited more than once but a proper tree never
+// comes close to that.
+const walkLimit = 4
+
+// ipv4Start finds node of ::/96, it is NodeCount if tree ends before that.
+func (r *reader) ipv4Start() uint32 {
+	node := uint32(0)
+	for i := 0; i < 96 && node < r.meta.NodeCount; i++ {
+		node, _ = r.records(node)
+	}
+	if node > r.meta.NodeCount {
+		return r.meta.NodeCount
+	}
+	return node
+}
+
+// records returns left (bit 0) and right (bit 1) records of node.
+func (r *reader) records(node uint32) (uint32, uint32) {
+	switch r.meta.RecordSize {
+	case 24:
+		b := r.tree[node*6:]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5])
+	case 28:
+		b := r.tree[node*7:]
+		return uint32(b[3]&0xf0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+			uint32(b[3]&0x0f)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	}
+	b := r.tree[node*8:]
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]),
+		uint32(b[4])<<24 | uint32(b[5])<<16 | uint32(b[6])<<8 | uint32(b[7])
+}
+
+// walk goes over subtree of node that is at key/depth.
+func (r *reader) walk(node uint32, key [16]byte, depth int) error {
+	width := 128
+	if r.meta.IPVersion == 4 {
+		width = 32
+	}
+	if depth >= width {
+		return fmt.Errorf("%w: search tree deeper than %d bits", ErrFormat, width)
+	}
+	if r.left == 0 {
+		return fmt.Errorf("%w: search tree shares too many subtrees", ErrFormat)
+	}
+	r.left--
+	left, right := r.records(node)
+	for bit, rec := range []uint32{left, right} {
+		k := key
+		if bit != 0 {
+			k[depth/8] |= 0x80 >> (depth % 8)
+		}
+		switch {
+		case rec == r.ipv4 && (depth+1 != 96 || k != [16]byte{}):
+			// alias of IPv4 subtree
+		case rec < r.meta.NodeCount:
+			if err := r.walk(rec, k, depth+1); err != nil {
+				return err
+			}
+		case rec > r.meta.NodeCount:
+			offset := int(rec - r.meta.NodeCount - dataSeparator)
+			value, ok := r.d.cache[offset]
+			if !ok {
+				var err error
+				if value, _, err = r.d.decode(offset, 0); err != nil {
+					return err
+				}
+				r.d.cache[offset] = value
+			}
+			if err := r.trie.Insert(r.prefix(k, depth+1), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prefix turns key/ln of search tree into IPv4 prefix where it is IPv4.
+func (r *reader) prefix(key [16]byte, ln int) netip.Prefix {
+	if r.meta.IPVersion == 4 {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte(key[:4])), ln)
+	}
+	if ln >= 96 && key == [16]byte{12: key[12], 13: key[13], 14: key[14], 15: key[15]} {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte(key[12:])), ln-96)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(key), ln)
+}
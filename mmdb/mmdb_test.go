@@ -0,0 +1,276 @@
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/asergeyev/iptrie"
+)
+
+func TestControl(t *testing.T) {
+	var tests = []struct {
+		typ, size int
+		want      []byte
+	}{
+		{typeString, 3, []byte{0x43}},
+		{typeString, 29, []byte{0x5d, 0}},
+		{typeString, 500, []byte{0x5e, 0, 215}},
+		{typeString, 70000, []byte{0x5f, 0, 0x10, 0x53}},
+		{typeUint64, 2, []byte{0x02, 0x02}},
+		{typeBool, 1, []byte{0x01, 0x07}},
+	}
+	for _, tst := range tests {
+		got := control(nil, tst.typ, tst.size)
+		if !bytes.Equal(got, tst.want) {
+			t.Errorf("control(%d, %d): expected %x, got %x", tst.typ, tst.size, tst.want, got)
+			continue
+		}
+		d := decoder{data: append(got, make([]byte, tst.size)...)}
+		if typ, size, next, err := d.control(0); err != nil || typ != tst.typ || size != tst.size || next != len(got) {
+			t.Errorf("decoding %x: got type %d size %d next %d err %v", got, typ, size, next, err)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	values := []any{
+		"", "hello", []byte{1, 2}, true, false, 1.5, float32(-2.25),
+		uint16(0), uint16(0x1234), uint32(7), uint64(1) << 60, int32(-5), int32(300),
+		new(big.Int).Lsh(big.NewInt(1), 100),
+		[]any{"a", uint32(1)},
+		map[string]any{"en": "Germany", "geoname_id": uint32(2921044), "nested": map[string]any{}},
+	}
+	for _, v := range values {
+		b, err := value(nil, v)
+		if err != nil {
+			t.Errorf("%T %v: %v", v, v, err)
+			continue
+		}
+		d := decoder{data: b}
+		got, next, err := d.decode(0, 0)
+		if err != nil || next != len(b) || !reflect.DeepEqual(got, v) {
+			t.Errorf("%T %v: decoded %T %v, next %d of %d, err %v", v, v, got, got, next, len(b), err)
+		}
+	}
+	if b, _ := value(nil, -7); !bytes.Equal(b, []byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xf9}) {
+		t.Errorf("Expected int -7 to be full int32, got %x", b)
+	}
+	if _, err := value(nil, struct{}{}); err == nil {
+		t.Error("Expected error for unsupported type")
+	}
+}
+
+func TestPointer(t *testing.T) {
+	// map {"a": "x"} at 0 and pointer to it at 5
+	data := []byte{0xe1, 0x41, 'a', 0x41, 'x', 0x20, 0x00}
+	d := decoder{data: data, cache: make(map[int]any)}
+	v, next, err := d.decode(5, 0)
+	if err != nil || next != 7 || !reflect.DeepEqual(v, map[string]any{"a": "x"}) {
+		t.Errorf("Expected pointer to map, got %v next %d err %v", v, next, err)
+	}
+	d = decoder{data: []byte{0x20, 0x02, 0x20, 0x00}}
+	if _, _, err = d.decode(0, 0); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected pointer to pointer to fail, got %v", err)
+	}
+}
+
+func roundTrip(t *testing.T, T *iptrie.DualTrie[any], meta *Metadata) (*iptrie.DualTrie[any], *Metadata) {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Write(&buf, T, meta); err != nil {
+		t.Fatal(err)
+	}
+	R, rmeta, err := Read(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return R, rmeta
+}
+
+func TestRoundTrip(t *testing.T) {
+	de := map[string]any{"country": map[string]any{"iso_code": "DE", "geoname_id": uint32(2921044)}}
+	us := map[string]any{"country": map[string]any{"iso_code": "US"}}
+	T := new(iptrie.DualTrie[any])
+	T.Insert(netip.MustParsePrefix("1.0.0.0/8"), de)
+	T.Insert(netip.MustParsePrefix("1.2.0.0/16"), us)
+	T.Insert(netip.MustParsePrefix("1.2.3.0/24"), de)
+	T.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	T.Insert(netip.MustParsePrefix("2001:db8:1::/48"), uint64(42))
+
+	meta := &Metadata{DatabaseType: "Test", Languages: []string{"en"}, Description: map[string]string{"en": "test"}, BuildEpoch: 1}
+	R, rmeta := roundTrip(t, T, meta)
+	if rmeta.DatabaseType != "Test" || rmeta.IPVersion != 6 || rmeta.RecordSize != 24 || rmeta.NodeCount != meta.NodeCount ||
+		!reflect.DeepEqual(rmeta.Languages, meta.Languages) || !reflect.DeepEqual(rmeta.Description, meta.Description) {
+		t.Errorf("Metadata differs: %+v and %+v", meta, rmeta)
+	}
+	for _, addr := range []string{"1.1.1.1", "1.2.1.1", "1.2.3.4", "1.3.0.0", "2.0.0.0", "2001:db8::1", "2001:db8:1::1", "2001:db9::", "::1"} {
+		a := netip.MustParseAddr(addr)
+		_, want, wok, _ := T.Lookup(a)
+		_, got, gok, _ := R.Lookup(a)
+		if wok != gok || !reflect.DeepEqual(want, got) {
+			t.Errorf("Lookup %s: expected %v, got %v", addr, want, got)
+		}
+	}
+
+	V4 := new(iptrie.DualTrie[any])
+	V4.Insert(netip.MustParsePrefix("10.0.0.0/8"), true)
+	R, rmeta = roundTrip(t, V4, &Metadata{IPVersion: 4, DatabaseType: "Test", Description: meta.Description})
+	if _, v, ok, _ := R.Lookup(netip.MustParseAddr("10.1.1.1")); !ok || v != true || rmeta.IPVersion != 4 {
+		t.Errorf("Expected IPv4 database with 10.0.0.0/8, got %v %v", v, rmeta)
+	}
+	if _, err := Write(new(bytes.Buffer), T, &Metadata{IPVersion: 4, DatabaseType: "Test", Description: meta.Description}); err == nil {
+		t.Error("Expected IPv6 entries to fail in IPv4 database")
+	}
+	for _, bad := range []*Metadata{{Description: meta.Description}, {DatabaseType: "Test"}, {DatabaseType: "Test", Description: map[string]string{}}} {
+		if _, err := Write(new(bytes.Buffer), T, bad); err == nil {
+			t.Errorf("Expected error for metadata without type or description: %+v", bad)
+		}
+	}
+}
+
+func TestRecordSizes(t *testing.T) {
+	for _, size := range []uint16{24, 28, 32} {
+		var b []byte
+		left, right := uint32(1<<(size-1)|0x123456), uint32(0xabcd)
+		b = appendNode(b, size, left, right)
+		r := reader{tree: b, meta: &Metadata{RecordSize: size}}
+		if l, rr := r.records(0); l != left || rr != right {
+			t.Errorf("Record size %d: expected %x %x, got %x %x", size, left, right, l, rr)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, _, err := Read([]byte("nothing")); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat, got %v", err)
+	}
+	var buf bytes.Buffer
+	T := new(iptrie.DualTrie[any])
+	T.Insert(netip.MustParsePrefix("10.0.0.0/8"), "x")
+	Write(&buf, T, &Metadata{DatabaseType: "Test", Description: map[string]string{"en": "test"}})
+	data := buf.Bytes()
+	if _, _, err := Read(data[bytes.Index(data, []byte(metadataMarker))-20:]); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat for cut search tree, got %v", err)
+	}
+}
+
+func TestReadSharedTooMuch(t *testing.T) {
+	// every node points twice to the next one, that is 2^30 networks
+	const n = 30
+	var data []byte
+	for i := uint32(0); i < n; i++ {
+		data = appendNode(data, 24, i+1, i+1)
+	}
+	data = appendNode(data, 24, n+1+dataSeparator, n+1+dataSeparator)
+	data = append(data, make([]byte, dataSeparator)...)
+	data, _ = value(data, "x")
+	data = append(data, metadataMarker...)
+	data, _ = value(data, (&Metadata{NodeCount: n + 1, RecordSize: 24, IPVersion: 4}).toMap())
+
+	if _, _, err := Read(data); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat, got %v", err)
+	}
+}
+
+func TestReadSharedSubtree(t *testing.T) {
+	// node 0 has both halves pointing to node 1, its data is for /2
+	var data []byte
+	data = appendNode(data, 24, 1, 1)
+	data = appendNode(data, 24, 2+dataSeparator, 2)
+	data = append(data, make([]byte, dataSeparator)...)
+	data, _ = value(data, "x")
+	data = append(data, metadataMarker...)
+	data, _ = value(data, (&Metadata{NodeCount: 2, RecordSize: 24, IPVersion: 4}).toMap())
+
+	T, _, err := Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for p, v := range T.All() {
+		got = append(got, p.String()+"="+v.(string))
+	}
+	if want := []string{"0.0.0.0/2=x", "128.0.0.0/2=x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// handTree builds search tree of IPv6 database record by record. Records
+// are node indexes, -1 for empty ones and -2-offset for data.
+type handTree [][2]int
+
+// set points record of p at rec adding nodes on the way, it returns node
+// the record belongs to.
+func (h *handTree) set(p netip.Prefix, rec int) int {
+	if len(*h) == 0 {
+		*h = append(*h, [2]int{-1, -1})
+	}
+	key, node := p.Addr().As16(), 0
+	for depth := 0; ; depth++ {
+		bit := int(key[depth/8]>>(7-depth%8)) & 1
+		if depth+1 == p.Bits() {
+			(*h)[node][bit] = rec
+			return node
+		}
+		if (*h)[node][bit] < 0 {
+			*h = append(*h, [2]int{-1, -1})
+			(*h)[node][bit] = len(*h) - 1
+		}
+		node = (*h)[node][bit]
+	}
+}
+
+func (h handTree) database(values ...any) []byte {
+	count := uint32(len(h))
+	var tree, section []byte
+	var offsets []int
+	for _, v := range values {
+		offsets = append(offsets, len(section))
+		section, _ = value(section, v)
+	}
+	record := func(rec int) uint32 {
+		switch {
+		case rec == -1:
+			return count
+		case rec < 0:
+			return count + dataSeparator + uint32(offsets[-2-rec])
+		}
+		return uint32(rec)
+	}
+	for _, n := range h {
+		tree = appendNode(tree, 24, record(n[0]), record(n[1]))
+	}
+	tree = append(tree, make([]byte, dataSeparator)...)
+	tree = append(tree, section...)
+	tree = append(tree, metadataMarker...)
+	tree, _ = value(tree, (&Metadata{NodeCount: count, RecordSize: 24, IPVersion: 6}).toMap())
+	return tree
+}
+
+func TestReadSkipsAliases(t *testing.T) {
+	var h handTree
+	ipv4 := h.set(netip.MustParsePrefix("::/97"), -1)
+	h.set(netip.MustParsePrefix("::102:300/120"), -2)
+	h.set(netip.MustParsePrefix("::ffff:0:0/96"), ipv4)
+	h.set(netip.MustParsePrefix("2002::/16"), ipv4)
+	// subtree shared by two networks is not an alias
+	shared := h.set(netip.MustParsePrefix("2001:db8::/33"), -3)
+	h.set(netip.MustParsePrefix("2001:db9::/32"), shared)
+
+	T, _, err := Read(h.database("v4", "s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for p, v := range T.All() {
+		got = append(got, p.String()+"="+v.(string))
+	}
+	want := []string{"1.2.3.0/24=v4", "2001:db8::/33=s", "2001:db9::/33=s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,385 @@
+// Package mrt reads BGP routing tables from MRT dumps (RFC 6396) into
+// iptrie tries. Only TABLE_DUMP_V2 peer index table and RIB_IPV4_UNICAST
+// and RIB_IPV6_UNICAST records are used, other records are skipped.
+package mrt
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/asergeyev/iptrie"
+)
+
+// MRT types and TABLE_DUMP_V2 subtypes.
+const (
+	typeTableDumpV2 = 13
+
+	subtypePeerIndexTable = 1
+	subtypeRIBIPv4Unicast = 2
+	subtypeRIBIPv6Unicast = 4
+)
+
+// BGP path attributes.
+const (
+	attrOrigin  = 1
+	attrASPath  = 2
+	attrNextHop = 3
+	attrMPReach = 14
+
+	attrExtendedLength = 0x10
+	asSet              = 1
+)
+
+// maxRecord limits record size, so broken length can't make reader
+// allocate too much.
+const maxRecord = 1 << 24
+
+var (
+	// ErrFormat is returned for malformed MRT data.
+	ErrFormat = errors.New("mrt: malformed record")
+	// ErrNoPeerIndex is returned for RIB records that come before peer
+	// index table or refer to peers it does not have.
+	ErrNoPeerIndex = errors.New("mrt: unknown peer")
+)
+
+// Origin is BGP ORIGIN attribute.
+type Origin byte
+
+const (
+	IGP Origin = iota
+	EGP
+	Incomplete
+)
+
+func (o Origin) String() string {
+	switch o {
+	case IGP:
+		return "IGP"
+	case EGP:
+		return "EGP"
+	case Incomplete:
+		return "INCOMPLETE"
+	}
+	return fmt.Sprintf("Origin(%d)", byte(o))
+}
+
+// Peer is an entry of peer index table.
+type Peer struct {
+	BGPID netip.Addr
+	Addr  netip.Addr
+	AS    uint32
+}
+
+// Route is what one peer has for a prefix.
+type Route struct {
+	Peer       *Peer
+	Originated time.Time
+	Origin     Origin
+	// ASPath lists AS numbers from the peer to origin, members of AS_SET
+	// segments are listed in place of the set.
+	ASPath []uint32
+	// NextHop comes from NEXT_HOP or MP_REACH_NLRI, link-local address is
+	// used only when there is no global one.
+	NextHop netip.Addr
+}
+
+// RIB is a routing table, Trie of V4 and V6 gives access to them as
+// Trie32 and Trie128 keeping *[]Route values.
+type RIB struct {
+	V4    iptrie.TypedTrie32[[]Route]
+	V6    iptrie.TypedTrie128[[]Route]
+	Peers []Peer
+}
+
+// Lookup returns routes of most specific prefix containing addr.
+func (rib *RIB) Lookup(addr netip.Addr) (netip.Prefix, []Route, bool) {
+	var (
+		p      netip.Prefix
+		routes []Route
+		found  bool
+	)
+	if addr.Is4() {
+		p, routes, found, _ = rib.V4.Lookup(addr)
+	} else {
+		p, routes, found, _ = rib.V6.Lookup(addr)
+	}
+	return p, routes, found
+}
+
+// Load reads whole dump into new RIB.
+func Load(r io.Reader) (*RIB, error) {
+	rib := new(RIB)
+	return rib, rib.Add(r)
+}
+
+// Add reads routes of a dump into rib, input could be gzip or bzip2
+// compressed. Routes for prefixes rib has already are appended, so several
+// dumps could be combined. Peers of the dump replace rib.Peers.
+func (rib *RIB) Add(r io.Reader) error {
+	mr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		p, routes, err := mr.Next()
+		if err == io.EOF {
+			rib.Peers = mr.Peers()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, ln := p.Addr().AsSlice(), byte(p.Bits())
+		if p.Addr().Is4() {
+			if exact, _, _, v := rib.V4.Get(key, ln); exact {
+				routes = append(v, routes...)
+			}
+			err = rib.V4.Insert(p, routes)
+		} else {
+			if exact, _, _, v := rib.V6.Get(key, ln); exact {
+				routes = append(v, routes...)
+			}
+			err = rib.V6.Insert(p, routes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Reader streams RIB records of a dump.
+type Reader struct {
+	r      *bufio.Reader
+	peers  []Peer
+	buf    []byte
+	offset int64 // of next record, for errors
+}
+
+// NewReader returns reader of a dump, gzip and bzip2 compressed input is
+// recognized by its magic bytes.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(zr)
+	case bytes.Equal(magic, []byte("BZh")):
+		br = bufio.NewReader(bzip2.NewReader(br))
+	}
+	return &Reader{r: br}, nil
+}
+
+// Peers returns peer index table read so far.
+func (mr *Reader) Peers() []Peer {
+	return mr.peers
+}
+
+func recordError(err error, offset int64, what string) error {
+	return fmt.Errorf("%w (%s at offset %d)", err, what, offset)
+}
+
+// readError keeps short reads as io.ErrUnexpectedEOF, errors of underlying
+// reader are returned as they are.
+func readError(err error, offset int64, what string) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return recordError(io.ErrUnexpectedEOF, offset, what)
+	}
+	return fmt.Errorf("mrt: %w", err)
+}
+
+// Next returns next RIB record, it is io.EOF after the last one and
+// io.ErrUnexpectedEOF if input ends inside of a record. Routes refer to
+// peers of Peers.
+func (mr *Reader) Next() (netip.Prefix, []Route, error) {
+	for {
+		var head [12]byte
+		if _, err := io.ReadFull(mr.r, head[:]); err != nil {
+			if err != io.EOF {
+				err = readError(err, mr.offset, "truncated header")
+			}
+			return netip.Prefix{}, nil, err
+		}
+		typ, subtype := binary.BigEndian.Uint16(head[4:]), binary.BigEndian.Uint16(head[6:])
+		length := binary.BigEndian.Uint32(head[8:])
+		if length > maxRecord {
+			return netip.Prefix{}, nil, recordError(ErrFormat, mr.offset, "record too long")
+		}
+		if cap(mr.buf) < int(length) {
+			mr.buf = make([]byte, length)
+		}
+		body := mr.buf[:length]
+		if _, err := io.ReadFull(mr.r, body); err != nil {
+			return netip.Prefix{}, nil, readError(err, mr.offset, "truncated record")
+		}
+		offset := mr.offset
+		mr.offset += int64(len(head) + len(body))
+
+		if typ != typeTableDumpV2 {
+			continue
+		}
+		switch subtype {
+		case subtypePeerIndexTable:
+			peers, err := parsePeers(body)
+			if err != nil {
+				return netip.Prefix{}, nil, recordError(err, offset, "peer index table")
+			}
+			mr.peers = peers
+		case subtypeRIBIPv4Unicast, subtypeRIBIPv6Unicast:
+			p, routes, err := mr.parseRIB(body, subtype == subtypeRIBIPv6Unicast)
+			if err != nil {
+				return netip.Prefix{}, nil, recordError(err, offset, "RIB record")
+			}
+			return p, routes, nil
+		}
+	}
+}
+
+// data reads big endian fields of a record keeping first error.
+type data struct {
+	b   []byte
+	err error
+}
+
+func (d *data) bytes(n int) []byte {
+	if d.err != nil || n > len(d.b) {
+		d.err = ErrFormat
+		return make([]byte, n)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *data) uint8() uint8   { return d.bytes(1)[0] }
+func (d *data) uint16() uint16 { return binary.BigEndian.Uint16(d.bytes(2)) }
+func (d *data) uint32() uint32 { return binary.BigEndian.Uint32(d.bytes(4)) }
+
+func (d *data) addr(v6 bool) netip.Addr {
+	if v6 {
+		return netip.AddrFrom16([16]byte(d.bytes(16)))
+	}
+	return netip.AddrFrom4([4]byte(d.bytes(4)))
+}
+
+func parsePeers(b []byte) ([]Peer, error) {
+	d := data{b: b}
+	d.bytes(4) // collector BGP ID
+	d.bytes(int(d.uint16()))
+	peers := make([]Peer, d.uint16())
+	for i := range peers {
+		typ := d.uint8()
+		peers[i].BGPID = d.addr(false)
+		peers[i].Addr = d.addr(typ&1 != 0)
+		if typ&2 != 0 {
+			peers[i].AS = d.uint32()
+		} else {
+			peers[i].AS = uint32(d.uint16())
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+	return peers, d.err
+}
+
+func (mr *Reader) parseRIB(b []byte, v6 bool) (netip.Prefix, []Route, error) {
+	d := data{b: b}
+	d.uint32() // sequence number
+	ln := d.uint8()
+	var key [16]byte
+	copy(key[:], d.bytes((int(ln)+7)/8))
+	if d.err != nil {
+		return netip.Prefix{}, nil, d.err
+	}
+	var p netip.Prefix
+	if v6 {
+		p = netip.PrefixFrom(netip.AddrFrom16(key), int(ln))
+	} else {
+		p = netip.PrefixFrom(netip.AddrFrom4([4]byte(key[:4])), int(ln))
+	}
+	if !p.IsValid() {
+		return netip.Prefix{}, nil, ErrFormat
+	}
+	p = p.Masked()
+
+	routes := make([]Route, d.uint16())
+	for i := range routes {
+		idx := d.uint16()
+		routes[i].Originated = time.Unix(int64(d.uint32()), 0).UTC()
+		attrs := d.bytes(int(d.uint16()))
+		if d.err != nil {
+			return netip.Prefix{}, nil, d.err
+		}
+		if int(idx) >= len(mr.peers) {
+			return netip.Prefix{}, nil, ErrNoPeerIndex
+		}
+		routes[i].Peer = &mr.peers[idx]
+		if err := routes[i].parseAttrs(attrs); err != nil {
+			return netip.Prefix{}, nil, err
+		}
+	}
+	return p, routes, d.err
+}
+
+func (r *Route) parseAttrs(b []byte) error {
+	d := data{b: b}
+	for len(d.b) > 0 && d.err == nil {
+		flags, code := d.uint8(), d.uint8()
+		var n int
+		if flags&attrExtendedLength != 0 {
+			n = int(d.uint16())
+		} else {
+			n = int(d.uint8())
+		}
+		a := data{b: d.bytes(n)}
+		if d.err != nil {
+			break
+		}
+		switch code {
+		case attrOrigin:
+			r.Origin = Origin(a.uint8())
+		case attrASPath:
+			// TABLE_DUMP_V2 always has 4 byte AS numbers
+			for len(a.b) > 0 && a.err == nil {
+				a.uint8() // segment type, sets are listed as sequences
+				count := int(a.uint8())
+				for j := 0; j < count && a.err == nil; j++ {
+					r.ASPath = append(r.ASPath, a.uint32())
+				}
+			}
+		case attrNextHop:
+			if !r.NextHop.IsValid() {
+				r.NextHop = a.addr(false)
+			}
+		case attrMPReach:
+			if len(a.b) == 0 || int(a.b[0])+1 != len(a.b) {
+				// full attribute as some dumpers write it
+				a.bytes(3) // AFI and SAFI
+			}
+			nh := a.bytes(int(a.uint8()))
+			if len(nh) >= 16 && a.err == nil {
+				r.NextHop = netip.AddrFrom16([16]byte(nh[:16]))
+			} else if len(nh) == 4 && a.err == nil {
+				r.NextHop = netip.AddrFrom4([4]byte(nh))
+			}
+		}
+		if a.err != nil {
+			return a.err
+		}
+	}
+	return d.err
+}
@@ -0,0 +1,118 @@
+package mrt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Fixtures in testdata are synthetic, made by testdata/gen.go, see there
+// what they hold.
+
+func dumpRoutes(routes []Route) []string {
+	var res []string
+	for _, r := range routes {
+		res = append(res, fmt.Sprintf("AS%d %v %v %v", r.Peer.AS, r.ASPath, r.NextHop, r.Origin))
+	}
+	return res
+}
+
+func TestLoad(t *testing.T) {
+	for _, name := range []string{"rib.mrt", "rib.mrt.gz", "rib.mrt.bz2"} {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rib, err := Load(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(rib.Peers) != 3 || rib.Peers[2].Addr != netip.MustParseAddr("2001:db8::3") || rib.Peers[1].AS != 4200000000 {
+			t.Errorf("%s: unexpected peers %v", name, rib.Peers)
+		}
+		if n := rib.V4.Len() + rib.V6.Len(); n != 4 {
+			t.Errorf("%s: expected 4 prefixes, got %d", name, n)
+		}
+		var tests = []struct {
+			addr   string
+			prefix string
+			want   []string
+		}{
+			{"10.2.3.4", "10.0.0.0/8", []string{"AS64500 [64500 3356] 10.0.0.1 IGP", "AS4200000000 [4200000000 174 3356] 10.0.0.2 INCOMPLETE"}},
+			{"10.1.0.1", "10.1.0.0/16", []string{"AS4200000000 [4200000000 64496 64497 64498] 10.0.0.2 IGP"}},
+			{"2001:db8::1", "2001:db8::/32", []string{"AS65001 [65001 6939] 2001:db8::3 IGP"}},
+			{"2001:db8:100::1", "2001:db8:100::/40", []string{"AS65001 [65001] 2001:db8::3 EGP"}},
+			{"224.0.0.1", "invalid Prefix", nil},
+		}
+		for _, tst := range tests {
+			p, routes, _ := rib.Lookup(netip.MustParseAddr(tst.addr))
+			if got := dumpRoutes(routes); p.String() != tst.prefix || !slices.Equal(got, tst.want) {
+				t.Errorf("%s: %s expected %s %v, got %v %v", name, tst.addr, tst.prefix, tst.want, p, got)
+			}
+		}
+		_, routes, _ := rib.Lookup(netip.MustParseAddr("10.0.0.0"))
+		if want := time.Unix(1700000000-3600, 0).UTC(); routes[0].Originated != want {
+			t.Errorf("%s: expected route originated at %v, got %v", name, want, routes[0].Originated)
+		}
+	}
+}
+
+func TestAddCombines(t *testing.T) {
+	data, err := os.ReadFile("testdata/rib.mrt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rib, _ := Load(bytes.NewReader(data))
+	if err = rib.Add(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, routes, _ := rib.Lookup(netip.MustParseAddr("10.1.0.1")); len(routes) != 2 {
+		t.Errorf("Expected routes of both dumps, got %d", len(routes))
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/rib.mrt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"truncated", data[:len(data)-5], io.ErrUnexpectedEOF},
+		{"truncated header", data[:5], io.ErrUnexpectedEOF},
+		{"no peer index", data[bytes.Index(data, []byte{0, 13, 0, 2})-4:], ErrNoPeerIndex},
+	}
+	for _, tst := range tests {
+		mr, _ := NewReader(bytes.NewReader(tst.data))
+		for err = nil; err == nil; _, _, err = mr.Next() {
+		}
+		if !errors.Is(err, tst.err) {
+			t.Errorf("%s: expected %v, got %v", tst.name, tst.err, err)
+		}
+	}
+	mr, _ := NewReader(bytes.NewReader(nil))
+	if _, _, err := mr.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty input, got %v", err)
+	}
+
+	errBroken := errors.New("broken pipe")
+	mr, _ = NewReader(io.MultiReader(bytes.NewReader(data[:100]), iotest.ErrReader(errBroken)))
+	for err = nil; err == nil; _, _, err = mr.Next() {
+	}
+	if !errors.Is(err, errBroken) || errors.Is(err, ErrFormat) || !strings.HasPrefix(err.Error(), "mrt: ") {
+		t.Errorf("Expected wrapped read error, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+//go:build ignore
+
+// Command gen writes rib.mrt and rib.mrt.gz fixtures: a small TABLE_DUMP_V2
+// dump laid out the way collectors write them. rib.mrt.bz2 is made with
+// bzip2 -k rib.mrt as Go has no bzip2 writer.
+//
+// The fixtures are synthetic, they are made here byte by byte and are not
+// taken from any collector. A trimmed real capture is still to be added.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net/netip"
+	"os"
+)
+
+const ts = 1700000000
+
+func record(typ, subtype uint16, body []byte) []byte {
+	var b []byte
+	b = binary.BigEndian.AppendUint32(b, ts)
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+	return append(b, body...)
+}
+
+func peerIndex() []byte {
+	var b []byte
+	b = append(b, 192, 0, 2, 254) // collector
+	b = binary.BigEndian.AppendUint16(b, 4)
+	b = append(b, "rv-1"...)
+	b = binary.BigEndian.AppendUint16(b, 3)
+	// IPv4 peer with 2 byte AS
+	b = append(b, 0, 10, 0, 0, 1, 10, 0, 0, 1)
+	b = binary.BigEndian.AppendUint16(b, 64500)
+	// IPv4 peer with 4 byte AS
+	b = append(b, 2, 10, 0, 0, 2, 10, 0, 0, 2)
+	b = binary.BigEndian.AppendUint32(b, 4200000000)
+	// IPv6 peer with 4 byte AS
+	b = append(b, 3, 10, 0, 0, 3)
+	b = append(b, netip.MustParseAddr("2001:db8::3").AsSlice()...)
+	b = binary.BigEndian.AppendUint32(b, 65001)
+	return b
+}
+
+func attr(flags, code byte, value []byte) []byte {
+	if len(value) > 255 {
+		flags |= 0x10
+		return append(binary.BigEndian.AppendUint16([]byte{flags, code}, uint16(len(value))), value...)
+	}
+	return append([]byte{flags, code, byte(len(value))}, value...)
+}
+
+func asPath(segments ...[]uint32) []byte {
+	var b []byte
+	for i, seg := range segments {
+		typ := byte(2) // AS_SEQUENCE
+		if i > 0 && len(seg) > 1 {
+			typ = 1 // AS_SET
+		}
+		b = append(b, typ, byte(len(seg)))
+		for _, as := range seg {
+			b = binary.BigEndian.AppendUint32(b, as)
+		}
+	}
+	return b
+}
+
+type route struct {
+	peer    uint16
+	origin  byte
+	path    [][]uint32
+	nexthop string
+	mp      []byte // MP_REACH_NLRI body, if any
+}
+
+func rib(subtype uint16, seq uint32, prefix string, routes ...route) []byte {
+	p := netip.MustParsePrefix(prefix)
+	var b []byte
+	b = binary.BigEndian.AppendUint32(b, seq)
+	b = append(b, byte(p.Bits()))
+	b = append(b, p.Addr().AsSlice()[:(p.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(routes)))
+	for _, r := range routes {
+		var attrs []byte
+		attrs = append(attrs, attr(0x40, 1, []byte{r.origin})...)
+		attrs = append(attrs, attr(0x40, 2, asPath(r.path...))...)
+		if r.nexthop != "" {
+			attrs = append(attrs, attr(0x40, 3, netip.MustParseAddr(r.nexthop).AsSlice())...)
+		}
+		if r.mp != nil {
+			attrs = append(attrs, attr(0x80, 14, r.mp)...)
+		}
+		b = binary.BigEndian.AppendUint16(b, r.peer)
+		b = binary.BigEndian.AppendUint32(b, ts-3600)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return b
+}
+
+func main() {
+	var dump []byte
+	dump = append(dump, record(13, 1, peerIndex())...)
+	dump = append(dump, record(13, 2, rib(2, 0, "10.0.0.0/8",
+		route{0, 0, [][]uint32{{64500, 3356}}, "10.0.0.1", nil},
+		route{1, 2, [][]uint32{{4200000000, 174, 3356}}, "10.0.0.2", nil}))...)
+	dump = append(dump, record(13, 2, rib(2, 1, "10.1.0.0/16",
+		route{1, 0, [][]uint32{{4200000000, 64496}, {64497, 64498}}, "10.0.0.2", nil}))...)
+	// BGP4MP message and multicast RIB are skipped by readers of RIB
+	dump = append(dump, record(16, 4, make([]byte, 20))...)
+	dump = append(dump, record(13, 3, rib(3, 2, "224.0.0.0/4", route{0, 0, [][]uint32{{64500}}, "10.0.0.1", nil}))...)
+	ll := append(netip.MustParseAddr("2001:db8::3").AsSlice(), netip.MustParseAddr("fe80::3").AsSlice()...)
+	dump = append(dump, record(13, 4, rib(4, 3, "2001:db8::/32",
+		route{2, 0, [][]uint32{{65001, 6939}}, "", append([]byte{32}, ll...)}))...)
+	full := append([]byte{0, 2, 1, 16}, netip.MustParseAddr("2001:db8::3").AsSlice()...)
+	dump = append(dump, record(13, 4, rib(4, 4, "2001:db8:100::/40",
+		route{2, 1, [][]uint32{{65001}}, "", full}))...)
+
+	if err := os.WriteFile("rib.mrt", dump, 0o644); err != nil {
+		panic(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(dump)
+	zw.Close()
+	if err := os.WriteFile("rib.mrt.gz", gz.Bytes(), 0o644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,163 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "net/netip"
+
+// netip front-end exists only for widths that match an address family:
+// TypedTrie32 keeps IPv4 and TypedTrie128 keeps IPv6 prefixes. IPv4-mapped
+// IPv6 addresses are IPv6 addresses here, they are never unmapped silently.
+
+func key4(p netip.Prefix) ([]byte, byte, error) {
+	if !p.IsValid() {
+		return nil, 0, ErrInvalidPrefix
+	}
+	if !p.Addr().Is4() {
+		return nil, 0, ErrFamilyMismatch
+	}
+	a := p.Addr().As4()
+	return a[:], byte(p.Bits()), nil
+}
+
+func key6(p netip.Prefix) ([]byte, byte, error) {
+	if !p.IsValid() {
+		return nil, 0, ErrInvalidPrefix
+	}
+	if !p.Addr().Is6() {
+		return nil, 0, ErrFamilyMismatch
+	}
+	a := p.Addr().As16()
+	return a[:], byte(p.Bits()), nil
+}
+
+// hostPrefix turns address into a single host prefix, zone is not part of
+// a prefix and gets dropped.
+func hostPrefix(addr netip.Addr) netip.Prefix {
+	addr = addr.WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+// node.IP() returns only words covered by prefix, short ones are padded.
+func prefix4(ip []byte, ln byte) netip.Prefix {
+	var a [4]byte
+	copy(a[:], ip)
+	return netip.PrefixFrom(netip.AddrFrom4(a), int(ln))
+}
+
+func prefix6(ip []byte, ln byte) netip.Prefix {
+	var a [16]byte
+	copy(a[:], ip)
+	return netip.PrefixFrom(netip.AddrFrom16(a), int(ln))
+}
+
+// Insert sets value for prefix p replacing previous one.
+func (t *TypedTrie32[V]) Insert(p netip.Prefix, value V) error {
+	ip, ln, err := key4(p)
+	if err != nil {
+		return err
+	}
+	_, _, err = t.Set(ip, ln, value)
+	return err
+}
+
+// Delete removes prefix p, it returns false if p was not in the trie.
+func (t *TypedTrie32[V]) Delete(p netip.Prefix) (bool, error) {
+	ip, ln, err := key4(p)
+	if err != nil {
+		return false, err
+	}
+	removed, _ := t.Remove(ip, ln)
+	return removed, nil
+}
+
+// Lookup finds most specific prefix containing addr.
+func (t *TypedTrie32[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool, error) {
+	return t.LookupPrefix(hostPrefix(addr))
+}
+
+// LookupPrefix finds p itself or most specific prefix containing it.
+func (t *TypedTrie32[V]) LookupPrefix(p netip.Prefix) (match netip.Prefix, value V, found bool, err error) {
+	ip, ln, err := key4(p)
+	if err != nil {
+		return
+	}
+	if _, ip, ln, value = t.Get(ip, ln); ip != nil {
+		match, found = prefix4(ip, ln), true
+	}
+	return
+}
+
+// Insert sets value for prefix p replacing previous one.
+func (t *TypedTrie128[V]) Insert(p netip.Prefix, value V) error {
+	ip, ln, err := key6(p)
+	if err != nil {
+		return err
+	}
+	_, _, err = t.Set(ip, ln, value)
+	return err
+}
+
+// Delete removes prefix p, it returns false if p was not in the trie.
+func (t *TypedTrie128[V]) Delete(p netip.Prefix) (bool, error) {
+	ip, ln, err := key6(p)
+	if err != nil {
+		return false, err
+	}
+	removed, _ := t.Remove(ip, ln)
+	return removed, nil
+}
+
+// Lookup finds most specific prefix containing addr.
+func (t *TypedTrie128[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool, error) {
+	return t.LookupPrefix(hostPrefix(addr))
+}
+
+// LookupPrefix finds p itself or most specific prefix containing it.
+func (t *TypedTrie128[V]) LookupPrefix(p netip.Prefix) (match netip.Prefix, value V, found bool, err error) {
+	ip, ln, err := key6(p)
+	if err != nil {
+		return
+	}
+	if _, ip, ln, value = t.Get(ip, ln); ip != nil {
+		match, found = prefix6(ip, ln), true
+	}
+	return
+}
+
+// InsertRange sets value for every address from start to end, see
+// Trie32.InsertRange.
+func (t *TypedTrie32[V]) InsertRange(start, end netip.Addr, value V) (int, error) {
+	if !start.IsValid() || !end.IsValid() {
+		return 0, ErrInvalidPrefix
+	}
+	if !start.Is4() || !end.Is4() {
+		return 0, ErrFamilyMismatch
+	}
+	s, e := start.As4(), end.As4()
+	return t.trie.InsertRange(s[:], e[:], boxValue(value))
+}
+
+// InsertRange sets value for every address from start to end, see
+// Trie128.InsertRange.
+func (t *TypedTrie128[V]) InsertRange(start, end netip.Addr, value V) (int, error) {
+	if !start.IsValid() || !end.IsValid() {
+		return 0, ErrInvalidPrefix
+	}
+	if !start.Is6() || !end.Is6() {
+		return 0, ErrFamilyMismatch
+	}
+	s, e := start.As16(), end.As16()
+	return t.trie.InsertRange(s[:], e[:], boxValue(value))
+}
+
+// RangeOf returns first and last address of p, both are invalid when p is.
+func RangeOf(p netip.Prefix) (first, last netip.Addr) {
+	if !p.IsValid() {
+		return
+	}
+	first = p.Masked().Addr()
+	key := first.AsSlice()
+	setHostBits(key, key, first.BitLen()-p.Bits())
+	last, _ = netip.AddrFromSlice(key)
+	return first, last
+}
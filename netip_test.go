@@ -0,0 +1,78 @@
+package iptrie
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNetipTrie32(t *testing.T) {
+	var T = new(TypedTrie32[int])
+	for i, s := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"} {
+		if err := T.Insert(netip.MustParsePrefix(s), i); err != nil {
+			t.Error("Unable to insert", s, err)
+		}
+	}
+	var tests = []struct {
+		addr  string
+		match string
+		value int
+	}{
+		{"10.1.2.3", "10.1.2.0/24", 3},
+		{"10.1.3.3", "10.1.0.0/16", 2},
+		{"10.2.3.3", "10.0.0.0/8", 1},
+		{"11.2.3.3", "0.0.0.0/0", 0},
+	}
+	for _, tst := range tests {
+		match, value, found, err := T.Lookup(netip.MustParseAddr(tst.addr))
+		if err != nil || !found || match.String() != tst.match || value != tst.value {
+			t.Errorf("Expected %s=%d for %s but got %s=%d (%t, %v)", tst.match, tst.value, tst.addr, match, value, found, err)
+		}
+	}
+	match, _, found, _ := T.LookupPrefix(netip.MustParsePrefix("10.1.128.0/17"))
+	if !found || match.String() != "10.1.0.0/16" {
+		t.Errorf("Expected 10.1.0.0/16 for 10.1.128.0/17 but got %s", match)
+	}
+	if deleted, err := T.Delete(netip.MustParsePrefix("10.1.2.0/24")); !deleted || err != nil {
+		t.Error("Unable to delete 10.1.2.0/24", err)
+	}
+	if match, _, _, _ = T.Lookup(netip.MustParseAddr("10.1.2.3")); match.String() != "10.1.0.0/16" {
+		t.Errorf("Expected 10.1.0.0/16 for 10.1.2.3 after deletion but got %s", match)
+	}
+	if deleted, err := T.Delete(netip.MustParsePrefix("0.0.0.0/0")); !deleted || err != nil {
+		t.Error("Unable to delete 0.0.0.0/0", err)
+	}
+	if _, _, found, _ = T.Lookup(netip.MustParseAddr("11.2.3.3")); found {
+		t.Error("Nothing should be found for 11.2.3.3 after deleting default route")
+	}
+
+	for _, s := range []string{"::1", "::ffff:10.1.2.3"} {
+		if _, _, _, err := T.Lookup(netip.MustParseAddr(s)); err != ErrFamilyMismatch {
+			t.Errorf("Expected family mismatch for %s but got %v", s, err)
+		}
+	}
+	if err := T.Insert(netip.Prefix{}, 0); err != ErrInvalidPrefix {
+		t.Errorf("Expected invalid prefix error but got %v", err)
+	}
+}
+
+func TestNetipTrie128(t *testing.T) {
+	var T = new(TypedTrie128[string])
+	T.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	T.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "site")
+	T.Insert(netip.MustParsePrefix("::ffff:0:0/96"), "mapped")
+
+	match, value, found, err := T.Lookup(netip.MustParseAddr("2001:db8:1::1%eth0"))
+	if err != nil || !found || match.String() != "2001:db8:1::/48" || value != "site" {
+		t.Errorf("Expected 2001:db8:1::/48=site but got %s=%s (%t, %v)", match, value, found, err)
+	}
+	match, value, _, _ = T.Lookup(netip.MustParseAddr("2001:db8:2::1"))
+	if match.String() != "2001:db8::/32" || value != "doc" {
+		t.Errorf("Expected 2001:db8::/32=doc but got %s=%s", match, value)
+	}
+	if _, value, _, _ = T.Lookup(netip.MustParseAddr("::ffff:1.2.3.4")); value != "mapped" {
+		t.Errorf("Expected IPv4-mapped address to stay IPv6, got %q", value)
+	}
+	if _, _, _, err = T.Lookup(netip.MustParseAddr("1.2.3.4")); err != ErrFamilyMismatch {
+		t.Errorf("Expected family mismatch for IPv4 address but got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+// Candidate sets of ORTC shared by all widths. Class 0 means "no match",
+// it can't be expressed with an entry, so a set having it is always {0}.
+// That keeps entries off any prefix that has uncovered addresses inside.
+
+// mergeHops is second ORTC pass operation: intersection of sorted sets a
+// and b, or their union when they have nothing in common.
+func mergeHops(a, b []int) []int {
+	if a[0] == 0 || b[0] == 0 {
+		return []int{0}
+	}
+	var res []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	if len(res) > 0 {
+		return res
+	}
+	res = make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] < b[j] {
+			res = append(res, a[i])
+			i++
+		} else {
+			res = append(res, b[j])
+			j++
+		}
+	}
+	res = append(res, a[i:]...)
+	return append(res, b[j:]...)
+}
+
+func hasHop(hops []int, hop int) bool {
+	for _, h := range hops {
+		if h == hop {
+			return true
+		}
+	}
+	return false
+}
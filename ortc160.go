@@ -0,0 +1,134 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i ortc160.go -o ortc_auto.go
+
+// ortcNode160 is a node of complete binary tree built for ORTC, every
+// node has either two halves or none.
+type ortcNode160 struct {
+	p    Prefix160
+	half [2]*ortcNode160
+	hops []int // candidate value classes, sorted, 0 is "no match"
+}
+
+type ortc160 struct {
+	eq      func(a, b unsafe.Pointer) bool
+	values  []unsafe.Pointer // value of every class, values[0] is not used
+	classes map[unsafe.Pointer]int
+	res     *Trie160
+}
+
+// class returns value class of real node covering some region.
+func (o *ortc160) class(n *Node160) int {
+	if n == nil {
+		return 0
+	}
+	if c, ok := o.classes[n.data]; ok {
+		return c
+	}
+	c := 1
+	for ; c < len(o.values) && !o.eq(o.values[c], n.data); c++ {
+	}
+	if c == len(o.values) {
+		o.values = append(o.values, n.data)
+	}
+	o.classes[n.data] = c
+	return c
+}
+
+// build does first two ORTC passes: it splits address space where trie
+// has something deeper (pushing covering values down to leaves) and
+// computes candidate sets bottom up.
+func (o *ortc160) build(p Prefix160, s side160) *ortcNode160 {
+	s = s.at(p.prefixlen)
+	n := &ortcNode160{p: p}
+	if !s.deeper(p.prefixlen) || p.prefixlen == MAXBITS {
+		n.hops = []int{o.class(s.cover)}
+		return n
+	}
+	n.half[0] = o.build(p.half(0), s.half(p.prefixlen, 0))
+	n.half[1] = o.build(p.half(1), s.half(p.prefixlen, 1))
+	n.hops = mergeHops(n.half[0].hops, n.half[1].hops)
+	return n
+}
+
+// choose is last ORTC pass, it adds entries only where value inherited
+// from shorter prefix is not good enough.
+func (o *ortc160) choose(n *ortcNode160, inherited int) {
+	if !hasHop(n.hops, inherited) {
+		inherited = n.hops[0] // never 0, see mergeHops
+		o.res.addToNode(o.res.node, n.p.IP(), n.p.prefixlen, o.values[inherited], true)
+	}
+	if n.half[0] != nil {
+		o.choose(n.half[0], inherited)
+		o.choose(n.half[1], inherited)
+	}
+}
+
+// Compress returns smallest trie giving same Get results as t for every
+// address, built with Optimal Routing Table Constructor (ORTC) algorithm.
+// Values are compared with eq (same pointer when nil) and entries with
+// equal values could be replaced with one covering entry, value of the
+// first one found is used for it. Address space t does not cover stays
+// uncovered. Result is checked against t and ErrCompressMismatch is
+// returned if they differ.
+func (t *Trie160) Compress(eq func(a, b unsafe.Pointer) bool) (*Trie160, error) {
+	res := new(Trie160)
+	if err := t.compress(res, eq); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Trie160) compress(res *Trie160, eq func(a, b unsafe.Pointer) bool) error {
+	if eq == nil {
+		eq = samePointer
+	}
+	o := ortc160{
+		eq:      eq,
+		values:  []unsafe.Pointer{nil},
+		classes: make(map[unsafe.Pointer]int),
+		res:     res,
+	}
+	o.choose(o.build(Prefix160{}, side160{sub: t.node}), 0)
+	if !t.sameMatches(res, eq) {
+		return ErrCompressMismatch
+	}
+	return nil
+}
+
+// sameMatches calls Get on both tries for every region where either
+// trie has something and compares results.
+func (t *Trie160) sameMatches(o *Trie160, eq func(a, b unsafe.Pointer) bool) bool {
+	return t.sameRegion(o, Prefix160{}, side160{sub: t.node}, side160{sub: o.node}, eq)
+}
+
+func (t *Trie160) sameRegion(o *Trie160, p Prefix160, a, b side160, eq func(a, b unsafe.Pointer) bool) bool {
+	if (a.deeper(p.prefixlen) || b.deeper(p.prefixlen)) && p.prefixlen < MAXBITS {
+		return t.sameRegion(o, p.half(0), a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), eq) &&
+			t.sameRegion(o, p.half(1), a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), eq)
+	}
+	ip := p.IP()
+	_, ipa, _, va := t.Get(ip, p.prefixlen)
+	_, ipb, _, vb := o.Get(ip, p.prefixlen)
+	if ipa == nil || ipb == nil {
+		return ipa == nil && ipb == nil
+	}
+	return eq(va, vb)
+}
+
+// Compress is Trie160.Compress for typed tries. Every typed value is kept
+// in its own place so with nil eq nothing is considered equal.
+func (t *TypedTrie160[V]) Compress(eq func(a, b V) bool) (*TypedTrie160[V], error) {
+	res := new(TypedTrie160[V])
+	if err := t.trie.compress(&res.trie, typedEqual(eq)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,379 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// ortcNode32 is a node of complete binary tree built for ORTC, every
+// node has either two halves or none.
+type ortcNode32 struct {
+	p    Prefix32
+	half [2]*ortcNode32
+	hops []int // candidate value classes, sorted, 0 is "no match"
+}
+
+type ortc32 struct {
+	eq      func(a, b unsafe.Pointer) bool
+	values  []unsafe.Pointer // value of every class, values[0] is not used
+	classes map[unsafe.Pointer]int
+	res     *Trie32
+}
+
+// class returns value class of real node covering some region.
+func (o *ortc32) class(n *Node32) int {
+	if n == nil {
+		return 0
+	}
+	if c, ok := o.classes[n.data]; ok {
+		return c
+	}
+	c := 1
+	for ; c < len(o.values) && !o.eq(o.values[c], n.data); c++ {
+	}
+	if c == len(o.values) {
+		o.values = append(o.values, n.data)
+	}
+	o.classes[n.data] = c
+	return c
+}
+
+// build does first two ORTC passes: it splits address space where trie
+// has something deeper (pushing covering values down to leaves) and
+// computes candidate sets bottom up.
+func (o *ortc32) build(p Prefix32, s side32) *ortcNode32 {
+	s = s.at(p.prefixlen)
+	n := &ortcNode32{p: p}
+	if !s.deeper(p.prefixlen) || p.prefixlen == 32 {
+		n.hops = []int{o.class(s.cover)}
+		return n
+	}
+	n.half[0] = o.build(p.half(0), s.half(p.prefixlen, 0))
+	n.half[1] = o.build(p.half(1), s.half(p.prefixlen, 1))
+	n.hops = mergeHops(n.half[0].hops, n.half[1].hops)
+	return n
+}
+
+// choose is last ORTC pass, it adds entries only where value inherited
+// from shorter prefix is not good enough.
+func (o *ortc32) choose(n *ortcNode32, inherited int) {
+	if !hasHop(n.hops, inherited) {
+		inherited = n.hops[0] // never 0, see mergeHops
+		o.res.addToNode(o.res.node, n.p.IP(), n.p.prefixlen, o.values[inherited], true)
+	}
+	if n.half[0] != nil {
+		o.choose(n.half[0], inherited)
+		o.choose(n.half[1], inherited)
+	}
+}
+
+// Compress returns smallest trie giving same Get results as t for every
+// address, built with Optimal Routing Table Constructor (ORTC) algorithm.
+// Values are compared with eq (same pointer when nil) and entries with
+// equal values could be replaced with one covering entry, value of the
+// first one found is used for it. Address space t does not cover stays
+// uncovered. Result is checked against t and ErrCompressMismatch is
+// returned if they differ.
+func (t *Trie32) Compress(eq func(a, b unsafe.Pointer) bool) (*Trie32, error) {
+	res := new(Trie32)
+	if err := t.compress(res, eq); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Trie32) compress(res *Trie32, eq func(a, b unsafe.Pointer) bool) error {
+	if eq == nil {
+		eq = samePointer
+	}
+	o := ortc32{
+		eq:      eq,
+		values:  []unsafe.Pointer{nil},
+		classes: make(map[unsafe.Pointer]int),
+		res:     res,
+	}
+	o.choose(o.build(Prefix32{}, side32{sub: t.node}), 0)
+	if !t.sameMatches(res, eq) {
+		return ErrCompressMismatch
+	}
+	return nil
+}
+
+// sameMatches calls Get on both tries for every region where either
+// trie has something and compares results.
+func (t *Trie32) sameMatches(o *Trie32, eq func(a, b unsafe.Pointer) bool) bool {
+	return t.sameRegion(o, Prefix32{}, side32{sub: t.node}, side32{sub: o.node}, eq)
+}
+
+func (t *Trie32) sameRegion(o *Trie32, p Prefix32, a, b side32, eq func(a, b unsafe.Pointer) bool) bool {
+	if (a.deeper(p.prefixlen) || b.deeper(p.prefixlen)) && p.prefixlen < 32 {
+		return t.sameRegion(o, p.half(0), a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), eq) &&
+			t.sameRegion(o, p.half(1), a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), eq)
+	}
+	ip := p.IP()
+	_, ipa, _, va := t.Get(ip, p.prefixlen)
+	_, ipb, _, vb := o.Get(ip, p.prefixlen)
+	if ipa == nil || ipb == nil {
+		return ipa == nil && ipb == nil
+	}
+	return eq(va, vb)
+}
+
+// Compress is Trie32.Compress for typed tries. Every typed value is kept
+// in its own place so with nil eq nothing is considered equal.
+func (t *TypedTrie32[V]) Compress(eq func(a, b V) bool) (*TypedTrie32[V], error) {
+	res := new(TypedTrie32[V])
+	if err := t.trie.compress(&res.trie, typedEqual(eq)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ortcNode64 is a node of complete binary tree built for ORTC, every
+// node has either two halves or none.
+type ortcNode64 struct {
+	p    Prefix64
+	half [2]*ortcNode64
+	hops []int // candidate value classes, sorted, 0 is "no match"
+}
+
+type ortc64 struct {
+	eq      func(a, b unsafe.Pointer) bool
+	values  []unsafe.Pointer // value of every class, values[0] is not used
+	classes map[unsafe.Pointer]int
+	res     *Trie64
+}
+
+// class returns value class of real node covering some region.
+func (o *ortc64) class(n *Node64) int {
+	if n == nil {
+		return 0
+	}
+	if c, ok := o.classes[n.data]; ok {
+		return c
+	}
+	c := 1
+	for ; c < len(o.values) && !o.eq(o.values[c], n.data); c++ {
+	}
+	if c == len(o.values) {
+		o.values = append(o.values, n.data)
+	}
+	o.classes[n.data] = c
+	return c
+}
+
+// build does first two ORTC passes: it splits address space where trie
+// has something deeper (pushing covering values down to leaves) and
+// computes candidate sets bottom up.
+func (o *ortc64) build(p Prefix64, s side64) *ortcNode64 {
+	s = s.at(p.prefixlen)
+	n := &ortcNode64{p: p}
+	if !s.deeper(p.prefixlen) || p.prefixlen == 64 {
+		n.hops = []int{o.class(s.cover)}
+		return n
+	}
+	n.half[0] = o.build(p.half(0), s.half(p.prefixlen, 0))
+	n.half[1] = o.build(p.half(1), s.half(p.prefixlen, 1))
+	n.hops = mergeHops(n.half[0].hops, n.half[1].hops)
+	return n
+}
+
+// choose is last ORTC pass, it adds entries only where value inherited
+// from shorter prefix is not good enough.
+func (o *ortc64) choose(n *ortcNode64, inherited int) {
+	if !hasHop(n.hops, inherited) {
+		inherited = n.hops[0] // never 0, see mergeHops
+		o.res.addToNode(o.res.node, n.p.IP(), n.p.prefixlen, o.values[inherited], true)
+	}
+	if n.half[0] != nil {
+		o.choose(n.half[0], inherited)
+		o.choose(n.half[1], inherited)
+	}
+}
+
+// Compress returns smallest trie giving same Get results as t for every
+// address, built with Optimal Routing Table Constructor (ORTC) algorithm.
+// Values are compared with eq (same pointer when nil) and entries with
+// equal values could be replaced with one covering entry, value of the
+// first one found is used for it. Address space t does not cover stays
+// uncovered. Result is checked against t and ErrCompressMismatch is
+// returned if they differ.
+func (t *Trie64) Compress(eq func(a, b unsafe.Pointer) bool) (*Trie64, error) {
+	res := new(Trie64)
+	if err := t.compress(res, eq); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Trie64) compress(res *Trie64, eq func(a, b unsafe.Pointer) bool) error {
+	if eq == nil {
+		eq = samePointer
+	}
+	o := ortc64{
+		eq:      eq,
+		values:  []unsafe.Pointer{nil},
+		classes: make(map[unsafe.Pointer]int),
+		res:     res,
+	}
+	o.choose(o.build(Prefix64{}, side64{sub: t.node}), 0)
+	if !t.sameMatches(res, eq) {
+		return ErrCompressMismatch
+	}
+	return nil
+}
+
+// sameMatches calls Get on both tries for every region where either
+// trie has something and compares results.
+func (t *Trie64) sameMatches(o *Trie64, eq func(a, b unsafe.Pointer) bool) bool {
+	return t.sameRegion(o, Prefix64{}, side64{sub: t.node}, side64{sub: o.node}, eq)
+}
+
+func (t *Trie64) sameRegion(o *Trie64, p Prefix64, a, b side64, eq func(a, b unsafe.Pointer) bool) bool {
+	if (a.deeper(p.prefixlen) || b.deeper(p.prefixlen)) && p.prefixlen < 64 {
+		return t.sameRegion(o, p.half(0), a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), eq) &&
+			t.sameRegion(o, p.half(1), a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), eq)
+	}
+	ip := p.IP()
+	_, ipa, _, va := t.Get(ip, p.prefixlen)
+	_, ipb, _, vb := o.Get(ip, p.prefixlen)
+	if ipa == nil || ipb == nil {
+		return ipa == nil && ipb == nil
+	}
+	return eq(va, vb)
+}
+
+// Compress is Trie64.Compress for typed tries. Every typed value is kept
+// in its own place so with nil eq nothing is considered equal.
+func (t *TypedTrie64[V]) Compress(eq func(a, b V) bool) (*TypedTrie64[V], error) {
+	res := new(TypedTrie64[V])
+	if err := t.trie.compress(&res.trie, typedEqual(eq)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ortcNode128 is a node of complete binary tree built for ORTC, every
+// node has either two halves or none.
+type ortcNode128 struct {
+	p    Prefix128
+	half [2]*ortcNode128
+	hops []int // candidate value classes, sorted, 0 is "no match"
+}
+
+type ortc128 struct {
+	eq      func(a, b unsafe.Pointer) bool
+	values  []unsafe.Pointer // value of every class, values[0] is not used
+	classes map[unsafe.Pointer]int
+	res     *Trie128
+}
+
+// class returns value class of real node covering some region.
+func (o *ortc128) class(n *Node128) int {
+	if n == nil {
+		return 0
+	}
+	if c, ok := o.classes[n.data]; ok {
+		return c
+	}
+	c := 1
+	for ; c < len(o.values) && !o.eq(o.values[c], n.data); c++ {
+	}
+	if c == len(o.values) {
+		o.values = append(o.values, n.data)
+	}
+	o.classes[n.data] = c
+	return c
+}
+
+// build does first two ORTC passes: it splits address space where trie
+// has something deeper (pushing covering values down to leaves) and
+// computes candidate sets bottom up.
+func (o *ortc128) build(p Prefix128, s side128) *ortcNode128 {
+	s = s.at(p.prefixlen)
+	n := &ortcNode128{p: p}
+	if !s.deeper(p.prefixlen) || p.prefixlen == 128 {
+		n.hops = []int{o.class(s.cover)}
+		return n
+	}
+	n.half[0] = o.build(p.half(0), s.half(p.prefixlen, 0))
+	n.half[1] = o.build(p.half(1), s.half(p.prefixlen, 1))
+	n.hops = mergeHops(n.half[0].hops, n.half[1].hops)
+	return n
+}
+
+// choose is last ORTC pass, it adds entries only where value inherited
+// from shorter prefix is not good enough.
+func (o *ortc128) choose(n *ortcNode128, inherited int) {
+	if !hasHop(n.hops, inherited) {
+		inherited = n.hops[0] // never 0, see mergeHops
+		o.res.addToNode(o.res.node, n.p.IP(), n.p.prefixlen, o.values[inherited], true)
+	}
+	if n.half[0] != nil {
+		o.choose(n.half[0], inherited)
+		o.choose(n.half[1], inherited)
+	}
+}
+
+// Compress returns smallest trie giving same Get results as t for every
+// address, built with Optimal Routing Table Constructor (ORTC) algorithm.
+// Values are compared with eq (same pointer when nil) and entries with
+// equal values could be replaced with one covering entry, value of the
+// first one found is used for it. Address space t does not cover stays
+// uncovered. Result is checked against t and ErrCompressMismatch is
+// returned if they differ.
+func (t *Trie128) Compress(eq func(a, b unsafe.Pointer) bool) (*Trie128, error) {
+	res := new(Trie128)
+	if err := t.compress(res, eq); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (t *Trie128) compress(res *Trie128, eq func(a, b unsafe.Pointer) bool) error {
+	if eq == nil {
+		eq = samePointer
+	}
+	o := ortc128{
+		eq:      eq,
+		values:  []unsafe.Pointer{nil},
+		classes: make(map[unsafe.Pointer]int),
+		res:     res,
+	}
+	o.choose(o.build(Prefix128{}, side128{sub: t.node}), 0)
+	if !t.sameMatches(res, eq) {
+		return ErrCompressMismatch
+	}
+	return nil
+}
+
+// sameMatches calls Get on both tries for every region where either
+// trie has something and compares results.
+func (t *Trie128) sameMatches(o *Trie128, eq func(a, b unsafe.Pointer) bool) bool {
+	return t.sameRegion(o, Prefix128{}, side128{sub: t.node}, side128{sub: o.node}, eq)
+}
+
+func (t *Trie128) sameRegion(o *Trie128, p Prefix128, a, b side128, eq func(a, b unsafe.Pointer) bool) bool {
+	if (a.deeper(p.prefixlen) || b.deeper(p.prefixlen)) && p.prefixlen < 128 {
+		return t.sameRegion(o, p.half(0), a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), eq) &&
+			t.sameRegion(o, p.half(1), a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), eq)
+	}
+	ip := p.IP()
+	_, ipa, _, va := t.Get(ip, p.prefixlen)
+	_, ipb, _, vb := o.Get(ip, p.prefixlen)
+	if ipa == nil || ipb == nil {
+		return ipa == nil && ipb == nil
+	}
+	return eq(va, vb)
+}
+
+// Compress is Trie128.Compress for typed tries. Every typed value is kept
+// in its own place so with nil eq nothing is considered equal.
+func (t *TypedTrie128[V]) Compress(eq func(a, b V) bool) (*TypedTrie128[V], error) {
+	res := new(TypedTrie128[V])
+	if err := t.trie.compress(&res.trie, typedEqual(eq)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package iptrie
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestTrieCompress(t *testing.T) {
+	eq := func(a, b string) bool { return a == b }
+	var tests = []struct {
+		name string
+		in   *TypedTrie32[string]
+		want []string
+	}{
+		{"covered by default", mkTrie32("0.0.0.0/0", "a", "10.0.0.0/9", "b", "10.128.0.0/10", "b", "10.192.0.0/10", "a"),
+			[]string{"0.0.0.0/0=a", "10.0.0.0/8=b", "10.192.0.0/10=a"}},
+		{"redundant", mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "a", "10.1.2.0/24", "b"),
+			[]string{"10.0.0.0/8=a", "10.1.2.0/24=b"}},
+		{"holes stay", mkTrie32("10.0.0.0/9", "a", "10.128.0.0/10", "a"),
+			[]string{"10.0.0.0/9=a", "10.128.0.0/10=a"}},
+		{"majority wins", mkTrie32("10.0.0.0/10", "a", "10.64.0.0/10", "b", "10.128.0.0/10", "b", "10.192.0.0/10", "b"),
+			[]string{"10.0.0.0/8=b", "10.0.0.0/10=a"}},
+		{"empty", new(TypedTrie32[string]), nil},
+	}
+	for _, tst := range tests {
+		res, err := tst.in.Compress(eq)
+		if err != nil {
+			t.Errorf("%s: %v", tst.name, err)
+			continue
+		}
+		if got := dumpTrie32(res); !slices.Equal(got, tst.want) {
+			t.Errorf("%s: expected\n%v\ngot\n%v", tst.name, tst.want, got)
+		}
+	}
+}
+
+func TestTrieCompressRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(17))
+	T := new(TypedTrie32[string])
+	for i := 0; i < 2000; i++ {
+		ip := []byte{10, byte(rnd.Intn(4)), byte(rnd.Intn(256)), byte(rnd.Intn(256))}
+		T.Set(ip, byte(8+rnd.Intn(25)), string(rune('a'+rnd.Intn(3))))
+	}
+	res, err := T.Compress(func(a, b string) bool { return a == b })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Len() >= T.Len() {
+		t.Errorf("Expected fewer than %d entries, got %d", T.Len(), res.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		ip := []byte{10, byte(rnd.Intn(5)), byte(rnd.Intn(256)), byte(rnd.Intn(256))}
+		_, ip1, _, v1 := T.Get(ip, 32)
+		_, ip2, _, v2 := res.Get(ip, 32)
+		if (ip1 == nil) != (ip2 == nil) || v1 != v2 {
+			t.Fatalf("Lookup of %v differs: %v=%q and %v=%q", ip, ip1, v1, ip2, v2)
+		}
+	}
+}
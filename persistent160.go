@@ -0,0 +1,184 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i persistent160.go -o persistent_auto.go
+
+// PersistentTrie160 is a copy-on-write version of Trie160. Writers copy
+// the path from root to the changed node and publish new version with an
+// atomic swap of root pointer, so readers never block and never see a half
+// made change. Old versions are left to garbage collector.
+//
+// Writers are serialized with a mutex. Nodes of published versions are
+// shared between versions and must never be changed (no Assign or Strip).
+type PersistentTrie160 struct {
+	root atomic.Pointer[Node160]
+	mu   sync.Mutex
+}
+
+// Root returns current version of the trie. It stays unchanged and usable
+// for as long as caller holds it, no matter what writers do.
+func (t *PersistentTrie160) Root() *Node160 {
+	return t.root.Load()
+}
+
+func (t *PersistentTrie160) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	if checkKey(ip, mask, MAXBITS) != nil {
+		return false, nil, 0, nil
+	}
+	return t.root.Load().lookup(ip, mask)
+}
+
+// Set adds or replaces value publishing new version of the trie.
+func (t *PersistentTrie160) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, true)
+}
+
+// Append adds value unless prefix is already set.
+func (t *PersistentTrie160) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, false)
+}
+
+func (t *PersistentTrie160) insert(ip []byte, mask byte, value unsafe.Pointer, replace bool) (bool, error) {
+	if err := checkKey(ip, mask, MAXBITS); err != nil {
+		return false, err
+	}
+	leaf := new(Node160)
+	leaf.setKey(ip, mask)
+	leaf.data = value
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, set := t.root.Load().persistInsert(leaf, replace)
+	if set {
+		t.root.Store(root)
+	}
+	return set, nil
+}
+
+// Remove deletes prefix publishing new version of the trie, it returns
+// value prefix had.
+func (t *PersistentTrie160) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, MAXBITS) != nil {
+		return false, nil
+	}
+	key := new(Node160)
+	key.setKey(ip, mask)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, removed := t.root.Load().persistRemove(key)
+	if removed == nil {
+		return false, nil
+	}
+	t.root.Store(root)
+	return true, removed.data
+}
+
+// persistInsert returns copy of subtree with leaf added, node itself is not
+// changed. Leaf becomes part of the result as is.
+func (node *Node160) persistInsert(leaf *Node160, replace bool) (*Node160, bool) {
+	if node == nil {
+		return leaf, true
+	}
+	ln := leaf.prefixlen
+	matched := node.bitsMatched(leaf.bits[:], ln)
+
+	switch {
+	case matched == node.prefixlen && matched == ln:
+		if node.dummy == 0 && !replace {
+			return node, false
+		}
+		c := *node
+		c.data, c.dummy = leaf.data, 0
+		return &c, true
+	case matched == node.prefixlen:
+		// leaf goes under node
+		var set bool
+		c := *node
+		if hasBit(leaf.bits[:], node.prefixlen+1) {
+			c.a, set = node.a.persistInsert(leaf, replace)
+		} else {
+			c.b, set = node.b.persistInsert(leaf, replace)
+		}
+		if !set {
+			return node, false
+		}
+		return &c, true
+	case matched == ln:
+		// node goes under leaf
+		if hasBit(node.bits[:], ln+1) {
+			leaf.a = node
+		} else {
+			leaf.b = node
+		}
+		return leaf, true
+	}
+
+	// both go under new dummy
+	dummy := &Node160{dummy: 1}
+	dummy.prefixlen = matched
+	copy(dummy.bits[:], leaf.bits[:])
+	dummy.trimBits()
+	if hasBit(node.bits[:], matched+1) {
+		dummy.a, dummy.b = node, leaf
+	} else {
+		dummy.a, dummy.b = leaf, node
+	}
+	return dummy, true
+}
+
+// persistRemove returns copy of subtree without key and removed node, node
+// itself is not changed. Dummies left with one child are dropped.
+func (node *Node160) persistRemove(key *Node160) (*Node160, *Node160) {
+	if node == nil {
+		return nil, nil
+	}
+	ln := key.prefixlen
+	if node.bitsMatched(key.bits[:], ln) < node.prefixlen {
+		return node, nil
+	}
+	if node.prefixlen == ln {
+		if node.dummy != 0 {
+			return node, nil
+		}
+		if node.a == nil {
+			return node.b, node
+		}
+		if node.b == nil {
+			return node.a, node
+		}
+		c := *node
+		c.data, c.dummy = nil, 1
+		return &c, node
+	}
+
+	var removed *Node160
+	c := *node
+	if hasBit(key.bits[:], node.prefixlen+1) {
+		c.a, removed = node.a.persistRemove(key)
+	} else {
+		c.b, removed = node.b.persistRemove(key)
+	}
+	if removed == nil {
+		return node, nil
+	}
+	if c.dummy != 0 {
+		if c.a == nil {
+			return c.b, removed
+		}
+		if c.b == nil {
+			return c.a, removed
+		}
+	}
+	return &c, removed
+}
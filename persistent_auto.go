@@ -0,0 +1,519 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// PersistentTrie32 is a copy-on-write version of Trie32. Writers copy
+// the path from root to the changed node and publish new version with an
+// atomic swap of root pointer, so readers never block and never see a half
+// made change. Old versions are left to garbage collector.
+//
+// Writers are serialized with a mutex. Nodes of published versions are
+// shared between versions and must never be changed (no Assign or Strip).
+type PersistentTrie32 struct {
+	root atomic.Pointer[Node32]
+	mu   sync.Mutex
+}
+
+// Root returns current version of the trie. It stays unchanged and usable
+// for as long as caller holds it, no matter what writers do.
+func (t *PersistentTrie32) Root() *Node32 {
+	return t.root.Load()
+}
+
+func (t *PersistentTrie32) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	if checkKey(ip, mask, 32) != nil {
+		return false, nil, 0, nil
+	}
+	return t.root.Load().lookup(ip, mask)
+}
+
+// Set adds or replaces value publishing new version of the trie.
+func (t *PersistentTrie32) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, true)
+}
+
+// Append adds value unless prefix is already set.
+func (t *PersistentTrie32) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, false)
+}
+
+func (t *PersistentTrie32) insert(ip []byte, mask byte, value unsafe.Pointer, replace bool) (bool, error) {
+	if err := checkKey(ip, mask, 32); err != nil {
+		return false, err
+	}
+	leaf := new(Node32)
+	leaf.setKey(ip, mask)
+	leaf.data = value
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, set := t.root.Load().persistInsert(leaf, replace)
+	if set {
+		t.root.Store(root)
+	}
+	return set, nil
+}
+
+// Remove deletes prefix publishing new version of the trie, it returns
+// value prefix had.
+func (t *PersistentTrie32) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 32) != nil {
+		return false, nil
+	}
+	key := new(Node32)
+	key.setKey(ip, mask)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, removed := t.root.Load().persistRemove(key)
+	if removed == nil {
+		return false, nil
+	}
+	t.root.Store(root)
+	return true, removed.data
+}
+
+// persistInsert returns copy of subtree with leaf added, node itself is not
+// changed. Leaf becomes part of the result as is.
+func (node *Node32) persistInsert(leaf *Node32, replace bool) (*Node32, bool) {
+	if node == nil {
+		return leaf, true
+	}
+	ln := leaf.prefixlen
+	matched := node.bitsMatched(leaf.bits[:], ln)
+
+	switch {
+	case matched == node.prefixlen && matched == ln:
+		if node.dummy == 0 && !replace {
+			return node, false
+		}
+		c := *node
+		c.data, c.dummy = leaf.data, 0
+		return &c, true
+	case matched == node.prefixlen:
+		// leaf goes under node
+		var set bool
+		c := *node
+		if hasBit(leaf.bits[:], node.prefixlen+1) {
+			c.a, set = node.a.persistInsert(leaf, replace)
+		} else {
+			c.b, set = node.b.persistInsert(leaf, replace)
+		}
+		if !set {
+			return node, false
+		}
+		return &c, true
+	case matched == ln:
+		// node goes under leaf
+		if hasBit(node.bits[:], ln+1) {
+			leaf.a = node
+		} else {
+			leaf.b = node
+		}
+		return leaf, true
+	}
+
+	// both go under new dummy
+	dummy := &Node32{dummy: 1}
+	dummy.prefixlen = matched
+	copy(dummy.bits[:], leaf.bits[:])
+	dummy.trimBits()
+	if hasBit(node.bits[:], matched+1) {
+		dummy.a, dummy.b = node, leaf
+	} else {
+		dummy.a, dummy.b = leaf, node
+	}
+	return dummy, true
+}
+
+// persistRemove returns copy of subtree without key and removed node, node
+// itself is not changed. Dummies left with one child are dropped.
+func (node *Node32) persistRemove(key *Node32) (*Node32, *Node32) {
+	if node == nil {
+		return nil, nil
+	}
+	ln := key.prefixlen
+	if node.bitsMatched(key.bits[:], ln) < node.prefixlen {
+		return node, nil
+	}
+	if node.prefixlen == ln {
+		if node.dummy != 0 {
+			return node, nil
+		}
+		if node.a == nil {
+			return node.b, node
+		}
+		if node.b == nil {
+			return node.a, node
+		}
+		c := *node
+		c.data, c.dummy = nil, 1
+		return &c, node
+	}
+
+	var removed *Node32
+	c := *node
+	if hasBit(key.bits[:], node.prefixlen+1) {
+		c.a, removed = node.a.persistRemove(key)
+	} else {
+		c.b, removed = node.b.persistRemove(key)
+	}
+	if removed == nil {
+		return node, nil
+	}
+	if c.dummy != 0 {
+		if c.a == nil {
+			return c.b, removed
+		}
+		if c.b == nil {
+			return c.a, removed
+		}
+	}
+	return &c, removed
+}
+
+// PersistentTrie64 is a copy-on-write version of Trie64. Writers copy
+// the path from root to the changed node and publish new version with an
+// atomic swap of root pointer, so readers never block and never see a half
+// made change. Old versions are left to garbage collector.
+//
+// Writers are serialized with a mutex. Nodes of published versions are
+// shared between versions and must never be changed (no Assign or Strip).
+type PersistentTrie64 struct {
+	root atomic.Pointer[Node64]
+	mu   sync.Mutex
+}
+
+// Root returns current version of the trie. It stays unchanged and usable
+// for as long as caller holds it, no matter what writers do.
+func (t *PersistentTrie64) Root() *Node64 {
+	return t.root.Load()
+}
+
+func (t *PersistentTrie64) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	if checkKey(ip, mask, 64) != nil {
+		return false, nil, 0, nil
+	}
+	return t.root.Load().lookup(ip, mask)
+}
+
+// Set adds or replaces value publishing new version of the trie.
+func (t *PersistentTrie64) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, true)
+}
+
+// Append adds value unless prefix is already set.
+func (t *PersistentTrie64) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, false)
+}
+
+func (t *PersistentTrie64) insert(ip []byte, mask byte, value unsafe.Pointer, replace bool) (bool, error) {
+	if err := checkKey(ip, mask, 64); err != nil {
+		return false, err
+	}
+	leaf := new(Node64)
+	leaf.setKey(ip, mask)
+	leaf.data = value
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, set := t.root.Load().persistInsert(leaf, replace)
+	if set {
+		t.root.Store(root)
+	}
+	return set, nil
+}
+
+// Remove deletes prefix publishing new version of the trie, it returns
+// value prefix had.
+func (t *PersistentTrie64) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 64) != nil {
+		return false, nil
+	}
+	key := new(Node64)
+	key.setKey(ip, mask)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, removed := t.root.Load().persistRemove(key)
+	if removed == nil {
+		return false, nil
+	}
+	t.root.Store(root)
+	return true, removed.data
+}
+
+// persistInsert returns copy of subtree with leaf added, node itself is not
+// changed. Leaf becomes part of the result as is.
+func (node *Node64) persistInsert(leaf *Node64, replace bool) (*Node64, bool) {
+	if node == nil {
+		return leaf, true
+	}
+	ln := leaf.prefixlen
+	matched := node.bitsMatched(leaf.bits[:], ln)
+
+	switch {
+	case matched == node.prefixlen && matched == ln:
+		if node.dummy == 0 && !replace {
+			return node, false
+		}
+		c := *node
+		c.data, c.dummy = leaf.data, 0
+		return &c, true
+	case matched == node.prefixlen:
+		// leaf goes under node
+		var set bool
+		c := *node
+		if hasBit(leaf.bits[:], node.prefixlen+1) {
+			c.a, set = node.a.persistInsert(leaf, replace)
+		} else {
+			c.b, set = node.b.persistInsert(leaf, replace)
+		}
+		if !set {
+			return node, false
+		}
+		return &c, true
+	case matched == ln:
+		// node goes under leaf
+		if hasBit(node.bits[:], ln+1) {
+			leaf.a = node
+		} else {
+			leaf.b = node
+		}
+		return leaf, true
+	}
+
+	// both go under new dummy
+	dummy := &Node64{dummy: 1}
+	dummy.prefixlen = matched
+	copy(dummy.bits[:], leaf.bits[:])
+	dummy.trimBits()
+	if hasBit(node.bits[:], matched+1) {
+		dummy.a, dummy.b = node, leaf
+	} else {
+		dummy.a, dummy.b = leaf, node
+	}
+	return dummy, true
+}
+
+// persistRemove returns copy of subtree without key and removed node, node
+// itself is not changed. Dummies left with one child are dropped.
+func (node *Node64) persistRemove(key *Node64) (*Node64, *Node64) {
+	if node == nil {
+		return nil, nil
+	}
+	ln := key.prefixlen
+	if node.bitsMatched(key.bits[:], ln) < node.prefixlen {
+		return node, nil
+	}
+	if node.prefixlen == ln {
+		if node.dummy != 0 {
+			return node, nil
+		}
+		if node.a == nil {
+			return node.b, node
+		}
+		if node.b == nil {
+			return node.a, node
+		}
+		c := *node
+		c.data, c.dummy = nil, 1
+		return &c, node
+	}
+
+	var removed *Node64
+	c := *node
+	if hasBit(key.bits[:], node.prefixlen+1) {
+		c.a, removed = node.a.persistRemove(key)
+	} else {
+		c.b, removed = node.b.persistRemove(key)
+	}
+	if removed == nil {
+		return node, nil
+	}
+	if c.dummy != 0 {
+		if c.a == nil {
+			return c.b, removed
+		}
+		if c.b == nil {
+			return c.a, removed
+		}
+	}
+	return &c, removed
+}
+
+// PersistentTrie128 is a copy-on-write version of Trie128. Writers copy
+// the path from root to the changed node and publish new version with an
+// atomic swap of root pointer, so readers never block and never see a half
+// made change. Old versions are left to garbage collector.
+//
+// Writers are serialized with a mutex. Nodes of published versions are
+// shared between versions and must never be changed (no Assign or Strip).
+type PersistentTrie128 struct {
+	root atomic.Pointer[Node128]
+	mu   sync.Mutex
+}
+
+// Root returns current version of the trie. It stays unchanged and usable
+// for as long as caller holds it, no matter what writers do.
+func (t *PersistentTrie128) Root() *Node128 {
+	return t.root.Load()
+}
+
+func (t *PersistentTrie128) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	if checkKey(ip, mask, 128) != nil {
+		return false, nil, 0, nil
+	}
+	return t.root.Load().lookup(ip, mask)
+}
+
+// Set adds or replaces value publishing new version of the trie.
+func (t *PersistentTrie128) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, true)
+}
+
+// Append adds value unless prefix is already set.
+func (t *PersistentTrie128) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, error) {
+	return t.insert(ip, mask, value, false)
+}
+
+func (t *PersistentTrie128) insert(ip []byte, mask byte, value unsafe.Pointer, replace bool) (bool, error) {
+	if err := checkKey(ip, mask, 128); err != nil {
+		return false, err
+	}
+	leaf := new(Node128)
+	leaf.setKey(ip, mask)
+	leaf.data = value
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, set := t.root.Load().persistInsert(leaf, replace)
+	if set {
+		t.root.Store(root)
+	}
+	return set, nil
+}
+
+// Remove deletes prefix publishing new version of the trie, it returns
+// value prefix had.
+func (t *PersistentTrie128) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 128) != nil {
+		return false, nil
+	}
+	key := new(Node128)
+	key.setKey(ip, mask)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	root, removed := t.root.Load().persistRemove(key)
+	if removed == nil {
+		return false, nil
+	}
+	t.root.Store(root)
+	return true, removed.data
+}
+
+// persistInsert returns copy of subtree with leaf added, node itself is not
+// changed. Leaf becomes part of the result as is.
+func (node *Node128) persistInsert(leaf *Node128, replace bool) (*Node128, bool) {
+	if node == nil {
+		return leaf, true
+	}
+	ln := leaf.prefixlen
+	matched := node.bitsMatched(leaf.bits[:], ln)
+
+	switch {
+	case matched == node.prefixlen && matched == ln:
+		if node.dummy == 0 && !replace {
+			return node, false
+		}
+		c := *node
+		c.data, c.dummy = leaf.data, 0
+		return &c, true
+	case matched == node.prefixlen:
+		// leaf goes under node
+		var set bool
+		c := *node
+		if hasBit(leaf.bits[:], node.prefixlen+1) {
+			c.a, set = node.a.persistInsert(leaf, replace)
+		} else {
+			c.b, set = node.b.persistInsert(leaf, replace)
+		}
+		if !set {
+			return node, false
+		}
+		return &c, true
+	case matched == ln:
+		// node goes under leaf
+		if hasBit(node.bits[:], ln+1) {
+			leaf.a = node
+		} else {
+			leaf.b = node
+		}
+		return leaf, true
+	}
+
+	// both go under new dummy
+	dummy := &Node128{dummy: 1}
+	dummy.prefixlen = matched
+	copy(dummy.bits[:], leaf.bits[:])
+	dummy.trimBits()
+	if hasBit(node.bits[:], matched+1) {
+		dummy.a, dummy.b = node, leaf
+	} else {
+		dummy.a, dummy.b = leaf, node
+	}
+	return dummy, true
+}
+
+// persistRemove returns copy of subtree without key and removed node, node
+// itself is not changed. Dummies left with one child are dropped.
+func (node *Node128) persistRemove(key *Node128) (*Node128, *Node128) {
+	if node == nil {
+		return nil, nil
+	}
+	ln := key.prefixlen
+	if node.bitsMatched(key.bits[:], ln) < node.prefixlen {
+		return node, nil
+	}
+	if node.prefixlen == ln {
+		if node.dummy != 0 {
+			return node, nil
+		}
+		if node.a == nil {
+			return node.b, node
+		}
+		if node.b == nil {
+			return node.a, node
+		}
+		c := *node
+		c.data, c.dummy = nil, 1
+		return &c, node
+	}
+
+	var removed *Node128
+	c := *node
+	if hasBit(key.bits[:], node.prefixlen+1) {
+		c.a, removed = node.a.persistRemove(key)
+	} else {
+		c.b, removed = node.b.persistRemove(key)
+	}
+	if removed == nil {
+		return node, nil
+	}
+	if c.dummy != 0 {
+		if c.a == nil {
+			return c.b, removed
+		}
+		if c.b == nil {
+			return c.a, removed
+		}
+	}
+	return &c, removed
+}
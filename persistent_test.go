@@ -0,0 +1,99 @@
+package iptrie
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func TestPersistentTrie(t *testing.T) {
+	var T = new(PersistentTrie32)
+	var values [4]int
+	insert := []struct {
+		key []byte
+		ln  byte
+	}{
+		{[]byte{1, 2, 3, 0}, 24},
+		{[]byte{1, 2, 0, 0}, 16},
+		{[]byte{1, 2, 4, 0}, 24},
+		{[]byte{1, 3, 0, 0}, 16},
+	}
+	var versions []*Node32
+	for i, s := range insert {
+		if set, err := T.Set(s.key, s.ln, unsafe.Pointer(&values[i])); !set || err != nil {
+			t.Error("Unable to set", s.key, s.ln, err)
+		}
+		versions = append(versions, T.Root())
+	}
+	if set, _ := T.Append([]byte{1, 2, 0, 0}, 16, nil); set {
+		t.Error("Should not be possible to replace with append!")
+	}
+
+	exact, ip, ln, value := T.Get([]byte{1, 2, 3, 4}, 32)
+	if exact || ln != 24 || !bytes.Equal(ip, []byte{1, 2, 3, 0}) || value != unsafe.Pointer(&values[0]) {
+		t.Errorf("Expected to find 1.2.3/24 but got: %v/%d", ip, ln)
+	}
+	// first version knows nothing about later changes
+	if _, _, ln, _ = versions[0].lookup([]byte{1, 2, 5, 4}, 32); ln != 0 {
+		t.Error("Old version changed, got match /", ln)
+	}
+	if _, _, ln, _ = versions[1].lookup([]byte{1, 2, 5, 4}, 32); ln != 16 {
+		t.Error("Expected 1.2/16 in second version, got /", ln)
+	}
+
+	if ok, value := T.Remove([]byte{1, 2, 0, 0}, 16); !ok || value != unsafe.Pointer(&values[1]) {
+		t.Error("Unable to remove 1.2/16")
+	}
+	if ok, _ := T.Remove([]byte{1, 2, 0, 0}, 16); ok {
+		t.Error("Existing node should be removed exactly once")
+	}
+	if _, _, ln, _ = T.Get([]byte{1, 2, 5, 4}, 32); ln != 0 {
+		t.Error("Expected no match after removing 1.2/16, got /", ln)
+	}
+	if _, _, ln, _ = versions[3].lookup([]byte{1, 2, 5, 4}, 32); ln != 16 {
+		t.Error("Removal changed old version, got /", ln)
+	}
+	var count, dummies int
+	T.Root().Drill(func(n *Node32) {
+		count++
+		if n.IsDummy() {
+			dummies++
+		}
+	})
+	if count-dummies != 3 || dummies != 2 {
+		t.Errorf("Expected 3 entries and 2 dummies, got %d and %d", count-dummies, dummies)
+	}
+}
+
+func TestPersistentTrieConcurrent(t *testing.T) {
+	var T = new(PersistentTrie128)
+	var value int
+	T.Set([]byte{0x20, 1, 0xd, 0xb8}, 32, unsafe.Pointer(&value))
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := []byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+			for !done.Load() {
+				if _, _, ln, _ := T.Get(key, 128); ln < 32 {
+					t.Error("Reader saw partial version, got /", ln)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		key := []byte{0x20, 1, 0xd, 0xb8, byte(i >> 8), byte(i)}
+		T.Set(key, 48, unsafe.Pointer(&value))
+		if i%3 == 0 {
+			T.Remove(key, 48)
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+}
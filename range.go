@@ -0,0 +1,77 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bytes"
+	"math/bits"
+)
+
+// checkRange makes sure start..end is a range of addresses of width bits.
+func checkRange(start, end []byte, width byte) error {
+	for _, key := range [][]byte{start, end} {
+		switch {
+		case len(key) < int(width)/8:
+			return ErrKeyTooShort
+		case len(key) > int(width)/8:
+			return ErrKeyTooLong
+		}
+	}
+	if bytes.Compare(start, end) > 0 {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// rangePrefixes calls yield for the smallest set of prefixes covering
+// start..end (both included) in ascending order. Address passed to yield
+// is reused between calls.
+func rangePrefixes(start, end []byte, yield func(ip []byte, ln byte) bool) {
+	width := len(start) * 8
+	cur := append([]byte(nil), start...)
+	last := make([]byte, len(start))
+	for {
+		host := trailingZeros(cur)
+		for setHostBits(last, cur, host); bytes.Compare(last, end) > 0; host-- {
+			setHostBits(last, cur, host-1)
+		}
+		if !yield(cur, byte(width-host)) || bytes.Equal(last, end) {
+			return
+		}
+		copy(cur, last)
+		increment(cur)
+	}
+}
+
+// trailingZeros returns number of zero bits at the end of key.
+func trailingZeros(key []byte) int {
+	n := 0
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] != 0 {
+			return n + bits.TrailingZeros8(key[i])
+		}
+		n += 8
+	}
+	return n
+}
+
+// setHostBits copies key to dst setting its last host bits.
+func setHostBits(dst, key []byte, host int) {
+	copy(dst, key)
+	for i := len(dst) - 1; host > 0; i-- {
+		if host >= 8 {
+			dst[i] = 0xff
+		} else {
+			dst[i] |= 0xff >> (8 - host)
+		}
+		host -= 8
+	}
+}
+
+func increment(key []byte) {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i]++; key[i] != 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i range160.go -o range_auto.go
+
+// InsertRange sets value for every address from start to end (both
+// included, full width keys) using the smallest set of prefixes covering
+// exactly that range. It returns number of prefixes set, on error some of
+// them may be set already.
+func (t *Trie160) InsertRange(start, end []byte, value unsafe.Pointer) (int, error) {
+	if err := checkRange(start, end, MAXBITS); err != nil {
+		return 0, err
+	}
+	var err error
+	n := 0
+	rangePrefixes(start, end, func(ip []byte, ln byte) bool {
+		if _, _, err = t.Set(ip, ln, value); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// RangeOf160 returns first and last address of prefix stored in node.
+func RangeOf160(node *Node160) (first, last []byte) {
+	first = make([]byte, MAXBITS/8)
+	copy(first, node.IP())
+	last = make([]byte, MAXBITS/8)
+	setHostBits(last, first, MAXBITS-int(node.prefixlen))
+	return first, last
+}
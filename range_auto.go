@@ -0,0 +1,94 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// InsertRange sets value for every address from start to end (both
+// included, full width keys) using the smallest set of prefixes covering
+// exactly that range. It returns number of prefixes set, on error some of
+// them may be set already.
+func (t *Trie32) InsertRange(start, end []byte, value unsafe.Pointer) (int, error) {
+	if err := checkRange(start, end, 32); err != nil {
+		return 0, err
+	}
+	var err error
+	n := 0
+	rangePrefixes(start, end, func(ip []byte, ln byte) bool {
+		if _, _, err = t.Set(ip, ln, value); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// RangeOf32 returns first and last address of prefix stored in node.
+func RangeOf32(node *Node32) (first, last []byte) {
+	first = make([]byte, 32/8)
+	copy(first, node.IP())
+	last = make([]byte, 32/8)
+	setHostBits(last, first, 32-int(node.prefixlen))
+	return first, last
+}
+
+// InsertRange sets value for every address from start to end (both
+// included, full width keys) using the smallest set of prefixes covering
+// exactly that range. It returns number of prefixes set, on error some of
+// them may be set already.
+func (t *Trie64) InsertRange(start, end []byte, value unsafe.Pointer) (int, error) {
+	if err := checkRange(start, end, 64); err != nil {
+		return 0, err
+	}
+	var err error
+	n := 0
+	rangePrefixes(start, end, func(ip []byte, ln byte) bool {
+		if _, _, err = t.Set(ip, ln, value); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// RangeOf64 returns first and last address of prefix stored in node.
+func RangeOf64(node *Node64) (first, last []byte) {
+	first = make([]byte, 64/8)
+	copy(first, node.IP())
+	last = make([]byte, 64/8)
+	setHostBits(last, first, 64-int(node.prefixlen))
+	return first, last
+}
+
+// InsertRange sets value for every address from start to end (both
+// included, full width keys) using the smallest set of prefixes covering
+// exactly that range. It returns number of prefixes set, on error some of
+// them may be set already.
+func (t *Trie128) InsertRange(start, end []byte, value unsafe.Pointer) (int, error) {
+	if err := checkRange(start, end, 128); err != nil {
+		return 0, err
+	}
+	var err error
+	n := 0
+	rangePrefixes(start, end, func(ip []byte, ln byte) bool {
+		if _, _, err = t.Set(ip, ln, value); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// RangeOf128 returns first and last address of prefix stored in node.
+func RangeOf128(node *Node128) (first, last []byte) {
+	first = make([]byte, 128/8)
+	copy(first, node.IP())
+	last = make([]byte, 128/8)
+	setHostBits(last, first, 128-int(node.prefixlen))
+	return first, last
+}
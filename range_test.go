@@ -0,0 +1,61 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestTrieInsertRange(t *testing.T) {
+	var tests = []struct {
+		start, end string
+		want       []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24=x"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32=x", "10.0.0.2/31=x", "10.0.0.4/31=x", "10.0.0.6/32=x"}},
+		{"10.0.0.5", "10.0.0.5", []string{"10.0.0.5/32=x"}},
+		{"192.168.0.0", "192.168.3.127", []string{"192.168.0.0/23=x", "192.168.2.0/24=x", "192.168.3.0/25=x"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0=x"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31=x"}},
+	}
+	for _, tst := range tests {
+		T := new(TypedTrie32[string])
+		n, err := T.InsertRange(netip.MustParseAddr(tst.start), netip.MustParseAddr(tst.end), "x")
+		if err != nil || n != len(tst.want) {
+			t.Errorf("%s-%s: inserted %d prefixes, error %v", tst.start, tst.end, n, err)
+		}
+		if got := dumpTrie32(T); !slices.Equal(got, tst.want) {
+			t.Errorf("%s-%s: expected\n%v\ngot\n%v", tst.start, tst.end, tst.want, got)
+		}
+	}
+}
+
+func TestTrieInsertRangeErrors(t *testing.T) {
+	T := new(Trie32)
+	if _, err := T.InsertRange([]byte{10, 0, 0, 2}, []byte{10, 0, 0, 1}, nil); err != ErrInvalidRange {
+		t.Errorf("Expected ErrInvalidRange, got %v", err)
+	}
+	if _, err := T.InsertRange([]byte{10, 0, 0}, []byte{10, 0, 0, 1}, nil); err != ErrKeyTooShort {
+		t.Errorf("Expected ErrKeyTooShort, got %v", err)
+	}
+	T6 := new(TypedTrie128[int])
+	if _, err := T6.InsertRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1"), 1); err != ErrFamilyMismatch {
+		t.Errorf("Expected ErrFamilyMismatch, got %v", err)
+	}
+	if n, err := T6.InsertRange(netip.MustParseAddr("2001:db8::"), netip.MustParseAddr("2001:db8::ffff:ffff"), 1); n != 1 || err != nil {
+		t.Errorf("Expected single /96, got %d prefixes and %v", n, err)
+	}
+}
+
+func TestRangeOf(t *testing.T) {
+	T := new(Trie32)
+	_, node := T.MustSet([]byte{10, 1, 2, 3}, 22, nil)
+	first, last := RangeOf32(node)
+	if !slices.Equal(first, []byte{10, 1, 0, 0}) || !slices.Equal(last, []byte{10, 1, 3, 255}) {
+		t.Errorf("Expected 10.1.0.0-10.1.3.255, got %v-%v", first, last)
+	}
+	a, b := RangeOf(netip.MustParsePrefix("2001:db8::1/64"))
+	if a.String() != "2001:db8::" || b.String() != "2001:db8::ffff:ffff:ffff:ffff" {
+		t.Errorf("Expected 2001:db8::/64 bounds, got %v-%v", a, b)
+	}
+}
@@ -0,0 +1,33 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Helpers for set operations shared by all widths.
+
+func samePointer(a, b unsafe.Pointer) bool {
+	return a == b
+}
+
+func keepFirst(a, b unsafe.Pointer) unsafe.Pointer {
+	return a
+}
+
+func typedMerge[V any](merge func(a, b V) V) func(a, b unsafe.Pointer) unsafe.Pointer {
+	if merge == nil {
+		return nil
+	}
+	return func(a, b unsafe.Pointer) unsafe.Pointer {
+		return boxValue(merge(unboxValue[V](a), unboxValue[V](b)))
+	}
+}
+
+func typedEqual[V any](eq func(a, b V) bool) func(a, b unsafe.Pointer) bool {
+	if eq == nil {
+		return nil
+	}
+	return func(a, b unsafe.Pointer) bool {
+		return eq(unboxValue[V](a), unboxValue[V](b))
+	}
+}
@@ -0,0 +1,281 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i setops160.go -o setops_auto.go
+
+// half returns lower (h=0) or upper (h=1) half of prefix.
+func (p Prefix160) half(h byte) Prefix160 {
+	if h != 0 {
+		p.bits[p.prefixlen/32] |= 0x80000000 >> (p.prefixlen % 32)
+	}
+	p.prefixlen++
+	return p
+}
+
+// side160 is what a trie has for some prefix p: real node covering p (if
+// any) and topmost node inside p (if any).
+type side160 struct {
+	cover, sub *Node160
+}
+
+// at moves cover to sub if sub is a real node exactly at prefixlen.
+func (s side160) at(prefixlen byte) side160 {
+	if s.sub != nil && s.sub.prefixlen == prefixlen && s.sub.dummy == 0 {
+		s.cover = s.sub
+	}
+	return s
+}
+
+// deeper tells if there is anything under prefix of prefixlen length.
+func (s side160) deeper(prefixlen byte) bool {
+	return s.sub != nil && (s.sub.prefixlen > prefixlen || s.sub.a != nil || s.sub.b != nil)
+}
+
+func (s side160) half(prefixlen, h byte) side160 {
+	sub := s.sub
+	switch {
+	case sub == nil:
+	case sub.prefixlen == prefixlen:
+		if h != 0 {
+			sub = sub.a
+		} else {
+			sub = sub.b
+		}
+	case hasBit(sub.bits[:], prefixlen+1) != (h != 0):
+		sub = nil // it's in other half
+	}
+	return side160{s.cover, sub}
+}
+
+// overlay160 walks address space of two tries at once splitting it only
+// where either trie has something deeper, and fills res with op results.
+type overlay160 struct {
+	// op tells if region covered by a and b (nil for no cover) belongs
+	// to result and with what value.
+	op func(a, b *Node160) (bool, unsafe.Pointer)
+	// eq tells if two values are same, adjacent regions with same values
+	// are merged.
+	eq  func(a, b unsafe.Pointer) bool
+	res *Trie160
+}
+
+// region160 is op result for a pair of covering nodes.
+type region160 struct {
+	a, b    *Node160
+	present bool
+	value   unsafe.Pointer
+}
+
+func (o *overlay160) run(a, b *Trie160) {
+	var p Prefix160
+	if full, value := o.walk(p, side160{sub: a.node}, side160{sub: b.node}, region160{}); full {
+		o.emit(p, value)
+	}
+}
+
+func (o *overlay160) emit(p Prefix160, value unsafe.Pointer) {
+	o.res.addToNode(o.res.node, p.IP(), p.prefixlen, value, true)
+}
+
+// walk returns true if whole p belongs to result and could be emitted with
+// returned value, parts of p that need other values are emitted already
+// (as nested entries where p keeps its own value). Emission of p itself is
+// left to caller who might find that p is covered by entry emitted for a
+// shorter prefix or merge it with its sibling. Prefixes are split only
+// where some part of them is missing from result.
+func (o *overlay160) walk(p Prefix160, a, b side160, up region160) (bool, unsafe.Pointer) {
+	a, b = a.at(p.prefixlen), b.at(p.prefixlen)
+	r := up
+	if a.cover != up.a || b.cover != up.b || (up.a == nil && up.b == nil) {
+		r.a, r.b = a.cover, b.cover
+		r.present, r.value = o.op(a.cover, b.cover)
+	}
+	if !a.deeper(p.prefixlen) && !b.deeper(p.prefixlen) || p.prefixlen == MAXBITS {
+		return r.present, r.value
+	}
+
+	p0, p1 := p.half(0), p.half(1)
+	full0, v0 := o.walk(p0, a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), r)
+	full1, v1 := o.walk(p1, a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), r)
+	if r.present && full0 && full1 {
+		// p keeps its value, only halves that differ need entries
+		if !o.eq(v0, r.value) {
+			o.emit(p0, v0)
+		}
+		if !o.eq(v1, r.value) {
+			o.emit(p1, v1)
+		}
+		return true, r.value
+	}
+	if full0 && full1 && o.eq(v0, v1) {
+		return true, v0
+	}
+	if full0 {
+		o.emit(p0, v0)
+	}
+	if full1 {
+		o.emit(p1, v1)
+	}
+	return false, nil
+}
+
+// setop fills empty res with op applied to t and o.
+func (t *Trie160) setop(o *Trie160, res *Trie160, op func(a, b *Node160) (bool, unsafe.Pointer)) {
+	if o == nil {
+		o = new(Trie160)
+	}
+	walker := overlay160{op: op, eq: samePointer, res: res}
+	walker.run(t, o)
+}
+
+// Union returns trie covering address space covered by t or o. Where both
+// cover same space merge decides value, nil merge keeps value from t.
+func (t *Trie160) Union(o *Trie160, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie160 {
+	res := new(Trie160)
+	t.union(o, res, merge)
+	return res
+}
+
+func (t *Trie160) union(o *Trie160, res *Trie160, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node160) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b != nil:
+			return true, merge(a.data, b.data)
+		case a != nil:
+			return true, a.data
+		case b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Intersect returns trie covering address space covered by both t and o
+// with values decided by merge, nil merge keeps value from t.
+func (t *Trie160) Intersect(o *Trie160, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie160 {
+	res := new(Trie160)
+	t.intersect(o, res, merge)
+	return res
+}
+
+func (t *Trie160) intersect(o *Trie160, res *Trie160, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node160) (bool, unsafe.Pointer) {
+		if a != nil && b != nil {
+			return true, merge(a.data, b.data)
+		}
+		return false, nil
+	})
+}
+
+// Difference returns trie covering address space covered by t but not by
+// o, prefixes of t are split only around holes o makes in them. Values
+// come from t.
+func (t *Trie160) Difference(o *Trie160) *Trie160 {
+	res := new(Trie160)
+	t.difference(o, res)
+	return res
+}
+
+func (t *Trie160) difference(o *Trie160, res *Trie160) {
+	t.setop(o, res, func(a, b *Node160) (bool, unsafe.Pointer) {
+		if a != nil && b == nil {
+			return true, a.data
+		}
+		return false, nil
+	})
+}
+
+// SymmetricDifference returns trie covering address space covered by
+// exactly one of t and o, values come from the one covering it.
+func (t *Trie160) SymmetricDifference(o *Trie160) *Trie160 {
+	res := new(Trie160)
+	t.symmetricDifference(o, res)
+	return res
+}
+
+func (t *Trie160) symmetricDifference(o *Trie160, res *Trie160) {
+	t.setop(o, res, func(a, b *Node160) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b == nil:
+			return true, a.data
+		case a == nil && b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Union is Trie160.Union for typed tries.
+func (t *TypedTrie160[V]) Union(o *TypedTrie160[V], merge func(a, b V) V) *TypedTrie160[V] {
+	res := new(TypedTrie160[V])
+	t.trie.union(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Intersect is Trie160.Intersect for typed tries.
+func (t *TypedTrie160[V]) Intersect(o *TypedTrie160[V], merge func(a, b V) V) *TypedTrie160[V] {
+	res := new(TypedTrie160[V])
+	t.trie.intersect(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Difference is Trie160.Difference for typed tries.
+func (t *TypedTrie160[V]) Difference(o *TypedTrie160[V]) *TypedTrie160[V] {
+	res := new(TypedTrie160[V])
+	t.trie.difference(&o.trie, &res.trie)
+	return res
+}
+
+// SymmetricDifference is Trie160.SymmetricDifference for typed tries.
+func (t *TypedTrie160[V]) SymmetricDifference(o *TypedTrie160[V]) *TypedTrie160[V] {
+	res := new(TypedTrie160[V])
+	t.trie.symmetricDifference(&o.trie, &res.trie)
+	return res
+}
+
+// Aggregate returns smallest set of prefixes covering same address space:
+// adjacent halves are merged (10.0.0.0/25 and 10.0.0.128/25 become
+// 10.0.0.0/24) and prefixes covered by others are dropped. With nil eq
+// values are ignored and merged prefix keeps value of its lowest part,
+// otherwise only prefixes with values equal by eq are merged or dropped.
+func (t *Trie160) Aggregate(eq func(a, b unsafe.Pointer) bool) *Trie160 {
+	res := new(Trie160)
+	t.aggregate(res, eq)
+	return res
+}
+
+func (t *Trie160) aggregate(res *Trie160, eq func(a, b unsafe.Pointer) bool) {
+	if eq == nil {
+		eq = func(a, b unsafe.Pointer) bool { return true }
+	}
+	walker := overlay160{
+		op: func(a, _ *Node160) (bool, unsafe.Pointer) {
+			if a != nil {
+				return true, a.data
+			}
+			return false, nil
+		},
+		eq:  eq,
+		res: res,
+	}
+	walker.run(t, new(Trie160))
+}
+
+// Aggregate is Trie160.Aggregate for typed tries.
+func (t *TypedTrie160[V]) Aggregate(eq func(a, b V) bool) *TypedTrie160[V] {
+	res := new(TypedTrie160[V])
+	t.trie.aggregate(&res.trie, typedEqual(eq))
+	return res
+}
@@ -0,0 +1,820 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// half returns lower (h=0) or upper (h=1) half of prefix.
+func (p Prefix32) half(h byte) Prefix32 {
+	if h != 0 {
+		p.bits[p.prefixlen/32] |= 0x80000000 >> (p.prefixlen % 32)
+	}
+	p.prefixlen++
+	return p
+}
+
+// side32 is what a trie has for some prefix p: real node covering p (if
+// any) and topmost node inside p (if any).
+type side32 struct {
+	cover, sub *Node32
+}
+
+// at moves cover to sub if sub is a real node exactly at prefixlen.
+func (s side32) at(prefixlen byte) side32 {
+	if s.sub != nil && s.sub.prefixlen == prefixlen && s.sub.dummy == 0 {
+		s.cover = s.sub
+	}
+	return s
+}
+
+// deeper tells if there is anything under prefix of prefixlen length.
+func (s side32) deeper(prefixlen byte) bool {
+	return s.sub != nil && (s.sub.prefixlen > prefixlen || s.sub.a != nil || s.sub.b != nil)
+}
+
+func (s side32) half(prefixlen, h byte) side32 {
+	sub := s.sub
+	switch {
+	case sub == nil:
+	case sub.prefixlen == prefixlen:
+		if h != 0 {
+			sub = sub.a
+		} else {
+			sub = sub.b
+		}
+	case hasBit(sub.bits[:], prefixlen+1) != (h != 0):
+		sub = nil // it's in other half
+	}
+	return side32{s.cover, sub}
+}
+
+// overlay32 walks address space of two tries at once splitting it only
+// where either trie has something deeper, and fills res with op results.
+type overlay32 struct {
+	// op tells if region covered by a and b (nil for no cover) belongs
+	// to result and with what value.
+	op func(a, b *Node32) (bool, unsafe.Pointer)
+	// eq tells if two values are same, adjacent regions with same values
+	// are merged.
+	eq  func(a, b unsafe.Pointer) bool
+	res *Trie32
+}
+
+// region32 is op result for a pair of covering nodes.
+type region32 struct {
+	a, b    *Node32
+	present bool
+	value   unsafe.Pointer
+}
+
+func (o *overlay32) run(a, b *Trie32) {
+	var p Prefix32
+	if full, value := o.walk(p, side32{sub: a.node}, side32{sub: b.node}, region32{}); full {
+		o.emit(p, value)
+	}
+}
+
+func (o *overlay32) emit(p Prefix32, value unsafe.Pointer) {
+	o.res.addToNode(o.res.node, p.IP(), p.prefixlen, value, true)
+}
+
+// walk returns true if whole p belongs to result and could be emitted with
+// returned value, parts of p that need other values are emitted already
+// (as nested entries where p keeps its own value). Emission of p itself is
+// left to caller who might find that p is covered by entry emitted for a
+// shorter prefix or merge it with its sibling. Prefixes are split only
+// where some part of them is missing from result.
+func (o *overlay32) walk(p Prefix32, a, b side32, up region32) (bool, unsafe.Pointer) {
+	a, b = a.at(p.prefixlen), b.at(p.prefixlen)
+	r := up
+	if a.cover != up.a || b.cover != up.b || (up.a == nil && up.b == nil) {
+		r.a, r.b = a.cover, b.cover
+		r.present, r.value = o.op(a.cover, b.cover)
+	}
+	if !a.deeper(p.prefixlen) && !b.deeper(p.prefixlen) || p.prefixlen == 32 {
+		return r.present, r.value
+	}
+
+	p0, p1 := p.half(0), p.half(1)
+	full0, v0 := o.walk(p0, a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), r)
+	full1, v1 := o.walk(p1, a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), r)
+	if r.present && full0 && full1 {
+		// p keeps its value, only halves that differ need entries
+		if !o.eq(v0, r.value) {
+			o.emit(p0, v0)
+		}
+		if !o.eq(v1, r.value) {
+			o.emit(p1, v1)
+		}
+		return true, r.value
+	}
+	if full0 && full1 && o.eq(v0, v1) {
+		return true, v0
+	}
+	if full0 {
+		o.emit(p0, v0)
+	}
+	if full1 {
+		o.emit(p1, v1)
+	}
+	return false, nil
+}
+
+// setop fills empty res with op applied to t and o.
+func (t *Trie32) setop(o *Trie32, res *Trie32, op func(a, b *Node32) (bool, unsafe.Pointer)) {
+	if o == nil {
+		o = new(Trie32)
+	}
+	walker := overlay32{op: op, eq: samePointer, res: res}
+	walker.run(t, o)
+}
+
+// Union returns trie covering address space covered by t or o. Where both
+// cover same space merge decides value, nil merge keeps value from t.
+func (t *Trie32) Union(o *Trie32, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie32 {
+	res := new(Trie32)
+	t.union(o, res, merge)
+	return res
+}
+
+func (t *Trie32) union(o *Trie32, res *Trie32, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node32) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b != nil:
+			return true, merge(a.data, b.data)
+		case a != nil:
+			return true, a.data
+		case b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Intersect returns trie covering address space covered by both t and o
+// with values decided by merge, nil merge keeps value from t.
+func (t *Trie32) Intersect(o *Trie32, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie32 {
+	res := new(Trie32)
+	t.intersect(o, res, merge)
+	return res
+}
+
+func (t *Trie32) intersect(o *Trie32, res *Trie32, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node32) (bool, unsafe.Pointer) {
+		if a != nil && b != nil {
+			return true, merge(a.data, b.data)
+		}
+		return false, nil
+	})
+}
+
+// Difference returns trie covering address space covered by t but not by
+// o, prefixes of t are split only around holes o makes in them. Values
+// come from t.
+func (t *Trie32) Difference(o *Trie32) *Trie32 {
+	res := new(Trie32)
+	t.difference(o, res)
+	return res
+}
+
+func (t *Trie32) difference(o *Trie32, res *Trie32) {
+	t.setop(o, res, func(a, b *Node32) (bool, unsafe.Pointer) {
+		if a != nil && b == nil {
+			return true, a.data
+		}
+		return false, nil
+	})
+}
+
+// SymmetricDifference returns trie covering address space covered by
+// exactly one of t and o, values come from the one covering it.
+func (t *Trie32) SymmetricDifference(o *Trie32) *Trie32 {
+	res := new(Trie32)
+	t.symmetricDifference(o, res)
+	return res
+}
+
+func (t *Trie32) symmetricDifference(o *Trie32, res *Trie32) {
+	t.setop(o, res, func(a, b *Node32) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b == nil:
+			return true, a.data
+		case a == nil && b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Union is Trie32.Union for typed tries.
+func (t *TypedTrie32[V]) Union(o *TypedTrie32[V], merge func(a, b V) V) *TypedTrie32[V] {
+	res := new(TypedTrie32[V])
+	t.trie.union(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Intersect is Trie32.Intersect for typed tries.
+func (t *TypedTrie32[V]) Intersect(o *TypedTrie32[V], merge func(a, b V) V) *TypedTrie32[V] {
+	res := new(TypedTrie32[V])
+	t.trie.intersect(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Difference is Trie32.Difference for typed tries.
+func (t *TypedTrie32[V]) Difference(o *TypedTrie32[V]) *TypedTrie32[V] {
+	res := new(TypedTrie32[V])
+	t.trie.difference(&o.trie, &res.trie)
+	return res
+}
+
+// SymmetricDifference is Trie32.SymmetricDifference for typed tries.
+func (t *TypedTrie32[V]) SymmetricDifference(o *TypedTrie32[V]) *TypedTrie32[V] {
+	res := new(TypedTrie32[V])
+	t.trie.symmetricDifference(&o.trie, &res.trie)
+	return res
+}
+
+// Aggregate returns smallest set of prefixes covering same address space:
+// adjacent halves are merged (10.0.0.0/25 and 10.0.0.128/25 become
+// 10.0.0.0/24) and prefixes covered by others are dropped. With nil eq
+// values are ignored and merged prefix keeps value of its lowest part,
+// otherwise only prefixes with values equal by eq are merged or dropped.
+func (t *Trie32) Aggregate(eq func(a, b unsafe.Pointer) bool) *Trie32 {
+	res := new(Trie32)
+	t.aggregate(res, eq)
+	return res
+}
+
+func (t *Trie32) aggregate(res *Trie32, eq func(a, b unsafe.Pointer) bool) {
+	if eq == nil {
+		eq = func(a, b unsafe.Pointer) bool { return true }
+	}
+	walker := overlay32{
+		op: func(a, _ *Node32) (bool, unsafe.Pointer) {
+			if a != nil {
+				return true, a.data
+			}
+			return false, nil
+		},
+		eq:  eq,
+		res: res,
+	}
+	walker.run(t, new(Trie32))
+}
+
+// Aggregate is Trie32.Aggregate for typed tries.
+func (t *TypedTrie32[V]) Aggregate(eq func(a, b V) bool) *TypedTrie32[V] {
+	res := new(TypedTrie32[V])
+	t.trie.aggregate(&res.trie, typedEqual(eq))
+	return res
+}
+
+// half returns lower (h=0) or upper (h=1) half of prefix.
+func (p Prefix64) half(h byte) Prefix64 {
+	if h != 0 {
+		p.bits[p.prefixlen/32] |= 0x80000000 >> (p.prefixlen % 32)
+	}
+	p.prefixlen++
+	return p
+}
+
+// side64 is what a trie has for some prefix p: real node covering p (if
+// any) and topmost node inside p (if any).
+type side64 struct {
+	cover, sub *Node64
+}
+
+// at moves cover to sub if sub is a real node exactly at prefixlen.
+func (s side64) at(prefixlen byte) side64 {
+	if s.sub != nil && s.sub.prefixlen == prefixlen && s.sub.dummy == 0 {
+		s.cover = s.sub
+	}
+	return s
+}
+
+// deeper tells if there is anything under prefix of prefixlen length.
+func (s side64) deeper(prefixlen byte) bool {
+	return s.sub != nil && (s.sub.prefixlen > prefixlen || s.sub.a != nil || s.sub.b != nil)
+}
+
+func (s side64) half(prefixlen, h byte) side64 {
+	sub := s.sub
+	switch {
+	case sub == nil:
+	case sub.prefixlen == prefixlen:
+		if h != 0 {
+			sub = sub.a
+		} else {
+			sub = sub.b
+		}
+	case hasBit(sub.bits[:], prefixlen+1) != (h != 0):
+		sub = nil // it's in other half
+	}
+	return side64{s.cover, sub}
+}
+
+// overlay64 walks address space of two tries at once splitting it only
+// where either trie has something deeper, and fills res with op results.
+type overlay64 struct {
+	// op tells if region covered by a and b (nil for no cover) belongs
+	// to result and with what value.
+	op func(a, b *Node64) (bool, unsafe.Pointer)
+	// eq tells if two values are same, adjacent regions with same values
+	// are merged.
+	eq  func(a, b unsafe.Pointer) bool
+	res *Trie64
+}
+
+// region64 is op result for a pair of covering nodes.
+type region64 struct {
+	a, b    *Node64
+	present bool
+	value   unsafe.Pointer
+}
+
+func (o *overlay64) run(a, b *Trie64) {
+	var p Prefix64
+	if full, value := o.walk(p, side64{sub: a.node}, side64{sub: b.node}, region64{}); full {
+		o.emit(p, value)
+	}
+}
+
+func (o *overlay64) emit(p Prefix64, value unsafe.Pointer) {
+	o.res.addToNode(o.res.node, p.IP(), p.prefixlen, value, true)
+}
+
+// walk returns true if whole p belongs to result and could be emitted with
+// returned value, parts of p that need other values are emitted already
+// (as nested entries where p keeps its own value). Emission of p itself is
+// left to caller who might find that p is covered by entry emitted for a
+// shorter prefix or merge it with its sibling. Prefixes are split only
+// where some part of them is missing from result.
+func (o *overlay64) walk(p Prefix64, a, b side64, up region64) (bool, unsafe.Pointer) {
+	a, b = a.at(p.prefixlen), b.at(p.prefixlen)
+	r := up
+	if a.cover != up.a || b.cover != up.b || (up.a == nil && up.b == nil) {
+		r.a, r.b = a.cover, b.cover
+		r.present, r.value = o.op(a.cover, b.cover)
+	}
+	if !a.deeper(p.prefixlen) && !b.deeper(p.prefixlen) || p.prefixlen == 64 {
+		return r.present, r.value
+	}
+
+	p0, p1 := p.half(0), p.half(1)
+	full0, v0 := o.walk(p0, a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), r)
+	full1, v1 := o.walk(p1, a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), r)
+	if r.present && full0 && full1 {
+		// p keeps its value, only halves that differ need entries
+		if !o.eq(v0, r.value) {
+			o.emit(p0, v0)
+		}
+		if !o.eq(v1, r.value) {
+			o.emit(p1, v1)
+		}
+		return true, r.value
+	}
+	if full0 && full1 && o.eq(v0, v1) {
+		return true, v0
+	}
+	if full0 {
+		o.emit(p0, v0)
+	}
+	if full1 {
+		o.emit(p1, v1)
+	}
+	return false, nil
+}
+
+// setop fills empty res with op applied to t and o.
+func (t *Trie64) setop(o *Trie64, res *Trie64, op func(a, b *Node64) (bool, unsafe.Pointer)) {
+	if o == nil {
+		o = new(Trie64)
+	}
+	walker := overlay64{op: op, eq: samePointer, res: res}
+	walker.run(t, o)
+}
+
+// Union returns trie covering address space covered by t or o. Where both
+// cover same space merge decides value, nil merge keeps value from t.
+func (t *Trie64) Union(o *Trie64, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie64 {
+	res := new(Trie64)
+	t.union(o, res, merge)
+	return res
+}
+
+func (t *Trie64) union(o *Trie64, res *Trie64, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node64) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b != nil:
+			return true, merge(a.data, b.data)
+		case a != nil:
+			return true, a.data
+		case b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Intersect returns trie covering address space covered by both t and o
+// with values decided by merge, nil merge keeps value from t.
+func (t *Trie64) Intersect(o *Trie64, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie64 {
+	res := new(Trie64)
+	t.intersect(o, res, merge)
+	return res
+}
+
+func (t *Trie64) intersect(o *Trie64, res *Trie64, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node64) (bool, unsafe.Pointer) {
+		if a != nil && b != nil {
+			return true, merge(a.data, b.data)
+		}
+		return false, nil
+	})
+}
+
+// Difference returns trie covering address space covered by t but not by
+// o, prefixes of t are split only around holes o makes in them. Values
+// come from t.
+func (t *Trie64) Difference(o *Trie64) *Trie64 {
+	res := new(Trie64)
+	t.difference(o, res)
+	return res
+}
+
+func (t *Trie64) difference(o *Trie64, res *Trie64) {
+	t.setop(o, res, func(a, b *Node64) (bool, unsafe.Pointer) {
+		if a != nil && b == nil {
+			return true, a.data
+		}
+		return false, nil
+	})
+}
+
+// SymmetricDifference returns trie covering address space covered by
+// exactly one of t and o, values come from the one covering it.
+func (t *Trie64) SymmetricDifference(o *Trie64) *Trie64 {
+	res := new(Trie64)
+	t.symmetricDifference(o, res)
+	return res
+}
+
+func (t *Trie64) symmetricDifference(o *Trie64, res *Trie64) {
+	t.setop(o, res, func(a, b *Node64) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b == nil:
+			return true, a.data
+		case a == nil && b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Union is Trie64.Union for typed tries.
+func (t *TypedTrie64[V]) Union(o *TypedTrie64[V], merge func(a, b V) V) *TypedTrie64[V] {
+	res := new(TypedTrie64[V])
+	t.trie.union(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Intersect is Trie64.Intersect for typed tries.
+func (t *TypedTrie64[V]) Intersect(o *TypedTrie64[V], merge func(a, b V) V) *TypedTrie64[V] {
+	res := new(TypedTrie64[V])
+	t.trie.intersect(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Difference is Trie64.Difference for typed tries.
+func (t *TypedTrie64[V]) Difference(o *TypedTrie64[V]) *TypedTrie64[V] {
+	res := new(TypedTrie64[V])
+	t.trie.difference(&o.trie, &res.trie)
+	return res
+}
+
+// SymmetricDifference is Trie64.SymmetricDifference for typed tries.
+func (t *TypedTrie64[V]) SymmetricDifference(o *TypedTrie64[V]) *TypedTrie64[V] {
+	res := new(TypedTrie64[V])
+	t.trie.symmetricDifference(&o.trie, &res.trie)
+	return res
+}
+
+// Aggregate returns smallest set of prefixes covering same address space:
+// adjacent halves are merged (10.0.0.0/25 and 10.0.0.128/25 become
+// 10.0.0.0/24) and prefixes covered by others are dropped. With nil eq
+// values are ignored and merged prefix keeps value of its lowest part,
+// otherwise only prefixes with values equal by eq are merged or dropped.
+func (t *Trie64) Aggregate(eq func(a, b unsafe.Pointer) bool) *Trie64 {
+	res := new(Trie64)
+	t.aggregate(res, eq)
+	return res
+}
+
+func (t *Trie64) aggregate(res *Trie64, eq func(a, b unsafe.Pointer) bool) {
+	if eq == nil {
+		eq = func(a, b unsafe.Pointer) bool { return true }
+	}
+	walker := overlay64{
+		op: func(a, _ *Node64) (bool, unsafe.Pointer) {
+			if a != nil {
+				return true, a.data
+			}
+			return false, nil
+		},
+		eq:  eq,
+		res: res,
+	}
+	walker.run(t, new(Trie64))
+}
+
+// Aggregate is Trie64.Aggregate for typed tries.
+func (t *TypedTrie64[V]) Aggregate(eq func(a, b V) bool) *TypedTrie64[V] {
+	res := new(TypedTrie64[V])
+	t.trie.aggregate(&res.trie, typedEqual(eq))
+	return res
+}
+
+// half returns lower (h=0) or upper (h=1) half of prefix.
+func (p Prefix128) half(h byte) Prefix128 {
+	if h != 0 {
+		p.bits[p.prefixlen/32] |= 0x80000000 >> (p.prefixlen % 32)
+	}
+	p.prefixlen++
+	return p
+}
+
+// side128 is what a trie has for some prefix p: real node covering p (if
+// any) and topmost node inside p (if any).
+type side128 struct {
+	cover, sub *Node128
+}
+
+// at moves cover to sub if sub is a real node exactly at prefixlen.
+func (s side128) at(prefixlen byte) side128 {
+	if s.sub != nil && s.sub.prefixlen == prefixlen && s.sub.dummy == 0 {
+		s.cover = s.sub
+	}
+	return s
+}
+
+// deeper tells if there is anything under prefix of prefixlen length.
+func (s side128) deeper(prefixlen byte) bool {
+	return s.sub != nil && (s.sub.prefixlen > prefixlen || s.sub.a != nil || s.sub.b != nil)
+}
+
+func (s side128) half(prefixlen, h byte) side128 {
+	sub := s.sub
+	switch {
+	case sub == nil:
+	case sub.prefixlen == prefixlen:
+		if h != 0 {
+			sub = sub.a
+		} else {
+			sub = sub.b
+		}
+	case hasBit(sub.bits[:], prefixlen+1) != (h != 0):
+		sub = nil // it's in other half
+	}
+	return side128{s.cover, sub}
+}
+
+// overlay128 walks address space of two tries at once splitting it only
+// where either trie has something deeper, and fills res with op results.
+type overlay128 struct {
+	// op tells if region covered by a and b (nil for no cover) belongs
+	// to result and with what value.
+	op func(a, b *Node128) (bool, unsafe.Pointer)
+	// eq tells if two values are same, adjacent regions with same values
+	// are merged.
+	eq  func(a, b unsafe.Pointer) bool
+	res *Trie128
+}
+
+// region128 is op result for a pair of covering nodes.
+type region128 struct {
+	a, b    *Node128
+	present bool
+	value   unsafe.Pointer
+}
+
+func (o *overlay128) run(a, b *Trie128) {
+	var p Prefix128
+	if full, value := o.walk(p, side128{sub: a.node}, side128{sub: b.node}, region128{}); full {
+		o.emit(p, value)
+	}
+}
+
+func (o *overlay128) emit(p Prefix128, value unsafe.Pointer) {
+	o.res.addToNode(o.res.node, p.IP(), p.prefixlen, value, true)
+}
+
+// walk returns true if whole p belongs to result and could be emitted with
+// returned value, parts of p that need other values are emitted already
+// (as nested entries where p keeps its own value). Emission of p itself is
+// left to caller who might find that p is covered by entry emitted for a
+// shorter prefix or merge it with its sibling. Prefixes are split only
+// where some part of them is missing from result.
+func (o *overlay128) walk(p Prefix128, a, b side128, up region128) (bool, unsafe.Pointer) {
+	a, b = a.at(p.prefixlen), b.at(p.prefixlen)
+	r := up
+	if a.cover != up.a || b.cover != up.b || (up.a == nil && up.b == nil) {
+		r.a, r.b = a.cover, b.cover
+		r.present, r.value = o.op(a.cover, b.cover)
+	}
+	if !a.deeper(p.prefixlen) && !b.deeper(p.prefixlen) || p.prefixlen == 128 {
+		return r.present, r.value
+	}
+
+	p0, p1 := p.half(0), p.half(1)
+	full0, v0 := o.walk(p0, a.half(p.prefixlen, 0), b.half(p.prefixlen, 0), r)
+	full1, v1 := o.walk(p1, a.half(p.prefixlen, 1), b.half(p.prefixlen, 1), r)
+	if r.present && full0 && full1 {
+		// p keeps its value, only halves that differ need entries
+		if !o.eq(v0, r.value) {
+			o.emit(p0, v0)
+		}
+		if !o.eq(v1, r.value) {
+			o.emit(p1, v1)
+		}
+		return true, r.value
+	}
+	if full0 && full1 && o.eq(v0, v1) {
+		return true, v0
+	}
+	if full0 {
+		o.emit(p0, v0)
+	}
+	if full1 {
+		o.emit(p1, v1)
+	}
+	return false, nil
+}
+
+// setop fills empty res with op applied to t and o.
+func (t *Trie128) setop(o *Trie128, res *Trie128, op func(a, b *Node128) (bool, unsafe.Pointer)) {
+	if o == nil {
+		o = new(Trie128)
+	}
+	walker := overlay128{op: op, eq: samePointer, res: res}
+	walker.run(t, o)
+}
+
+// Union returns trie covering address space covered by t or o. Where both
+// cover same space merge decides value, nil merge keeps value from t.
+func (t *Trie128) Union(o *Trie128, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie128 {
+	res := new(Trie128)
+	t.union(o, res, merge)
+	return res
+}
+
+func (t *Trie128) union(o *Trie128, res *Trie128, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node128) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b != nil:
+			return true, merge(a.data, b.data)
+		case a != nil:
+			return true, a.data
+		case b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Intersect returns trie covering address space covered by both t and o
+// with values decided by merge, nil merge keeps value from t.
+func (t *Trie128) Intersect(o *Trie128, merge func(a, b unsafe.Pointer) unsafe.Pointer) *Trie128 {
+	res := new(Trie128)
+	t.intersect(o, res, merge)
+	return res
+}
+
+func (t *Trie128) intersect(o *Trie128, res *Trie128, merge func(a, b unsafe.Pointer) unsafe.Pointer) {
+	if merge == nil {
+		merge = keepFirst
+	}
+	t.setop(o, res, func(a, b *Node128) (bool, unsafe.Pointer) {
+		if a != nil && b != nil {
+			return true, merge(a.data, b.data)
+		}
+		return false, nil
+	})
+}
+
+// Difference returns trie covering address space covered by t but not by
+// o, prefixes of t are split only around holes o makes in them. Values
+// come from t.
+func (t *Trie128) Difference(o *Trie128) *Trie128 {
+	res := new(Trie128)
+	t.difference(o, res)
+	return res
+}
+
+func (t *Trie128) difference(o *Trie128, res *Trie128) {
+	t.setop(o, res, func(a, b *Node128) (bool, unsafe.Pointer) {
+		if a != nil && b == nil {
+			return true, a.data
+		}
+		return false, nil
+	})
+}
+
+// SymmetricDifference returns trie covering address space covered by
+// exactly one of t and o, values come from the one covering it.
+func (t *Trie128) SymmetricDifference(o *Trie128) *Trie128 {
+	res := new(Trie128)
+	t.symmetricDifference(o, res)
+	return res
+}
+
+func (t *Trie128) symmetricDifference(o *Trie128, res *Trie128) {
+	t.setop(o, res, func(a, b *Node128) (bool, unsafe.Pointer) {
+		switch {
+		case a != nil && b == nil:
+			return true, a.data
+		case a == nil && b != nil:
+			return true, b.data
+		}
+		return false, nil
+	})
+}
+
+// Union is Trie128.Union for typed tries.
+func (t *TypedTrie128[V]) Union(o *TypedTrie128[V], merge func(a, b V) V) *TypedTrie128[V] {
+	res := new(TypedTrie128[V])
+	t.trie.union(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Intersect is Trie128.Intersect for typed tries.
+func (t *TypedTrie128[V]) Intersect(o *TypedTrie128[V], merge func(a, b V) V) *TypedTrie128[V] {
+	res := new(TypedTrie128[V])
+	t.trie.intersect(&o.trie, &res.trie, typedMerge(merge))
+	return res
+}
+
+// Difference is Trie128.Difference for typed tries.
+func (t *TypedTrie128[V]) Difference(o *TypedTrie128[V]) *TypedTrie128[V] {
+	res := new(TypedTrie128[V])
+	t.trie.difference(&o.trie, &res.trie)
+	return res
+}
+
+// SymmetricDifference is Trie128.SymmetricDifference for typed tries.
+func (t *TypedTrie128[V]) SymmetricDifference(o *TypedTrie128[V]) *TypedTrie128[V] {
+	res := new(TypedTrie128[V])
+	t.trie.symmetricDifference(&o.trie, &res.trie)
+	return res
+}
+
+// Aggregate returns smallest set of prefixes covering same address space:
+// adjacent halves are merged (10.0.0.0/25 and 10.0.0.128/25 become
+// 10.0.0.0/24) and prefixes covered by others are dropped. With nil eq
+// values are ignored and merged prefix keeps value of its lowest part,
+// otherwise only prefixes with values equal by eq are merged or dropped.
+func (t *Trie128) Aggregate(eq func(a, b unsafe.Pointer) bool) *Trie128 {
+	res := new(Trie128)
+	t.aggregate(res, eq)
+	return res
+}
+
+func (t *Trie128) aggregate(res *Trie128, eq func(a, b unsafe.Pointer) bool) {
+	if eq == nil {
+		eq = func(a, b unsafe.Pointer) bool { return true }
+	}
+	walker := overlay128{
+		op: func(a, _ *Node128) (bool, unsafe.Pointer) {
+			if a != nil {
+				return true, a.data
+			}
+			return false, nil
+		},
+		eq:  eq,
+		res: res,
+	}
+	walker.run(t, new(Trie128))
+}
+
+// Aggregate is Trie128.Aggregate for typed tries.
+func (t *TypedTrie128[V]) Aggregate(eq func(a, b V) bool) *TypedTrie128[V] {
+	res := new(TypedTrie128[V])
+	t.trie.aggregate(&res.trie, typedEqual(eq))
+	return res
+}
@@ -0,0 +1,118 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func mkTrie32(entries ...string) *TypedTrie32[string] {
+	T := new(TypedTrie32[string])
+	for i := 0; i < len(entries); i += 2 {
+		T.Insert(netip.MustParsePrefix(entries[i]), entries[i+1])
+	}
+	return T
+}
+
+func dumpTrie32(T *TypedTrie32[string]) []string {
+	var res []string
+	for p, v := range T.All() {
+		res = append(res, p.String()+"="+v)
+	}
+	return res
+}
+
+func TestTrieSetOps(t *testing.T) {
+	concat := func(a, b string) string { return a + "+" + b }
+	var tests = []struct {
+		name string
+		res  *TypedTrie32[string]
+		want []string
+	}{
+		{"difference", mkTrie32("10.0.0.0/8", "a").Difference(mkTrie32("10.1.0.0/16", "b")),
+			[]string{"10.0.0.0/16=a", "10.2.0.0/15=a", "10.4.0.0/14=a", "10.8.0.0/13=a", "10.16.0.0/12=a", "10.32.0.0/11=a", "10.64.0.0/10=a", "10.128.0.0/9=a"}},
+		{"difference nested", mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "c").Difference(mkTrie32("10.0.0.0/9", "b", "10.1.2.0/24", "d")),
+			[]string{"10.128.0.0/9=a"}},
+		{"union", mkTrie32("10.0.0.0/8", "a", "12.0.0.0/8", "c").Union(mkTrie32("10.1.2.0/24", "b", "11.0.0.0/8", "d"), concat),
+			[]string{"10.0.0.0/8=a", "10.1.2.0/24=a+b", "11.0.0.0/8=d", "12.0.0.0/8=c"}},
+		{"union keeps first", mkTrie32("10.0.0.0/8", "a").Union(mkTrie32("10.0.0.0/8", "b"), nil),
+			[]string{"10.0.0.0/8=a"}},
+		{"intersect", mkTrie32("10.0.0.0/8", "a", "11.0.0.0/8", "c", "10.1.0.0/16", "e").Intersect(mkTrie32("10.1.0.0/17", "b", "12.0.0.0/8", "d"), concat),
+			[]string{"10.1.0.0/17=e+b"}},
+		{"intersect nested", mkTrie32("0.0.0.0/0", "a").Intersect(mkTrie32("10.0.0.0/8", "b", "10.1.0.0/16", "c"), concat),
+			[]string{"10.0.0.0/8=a+b", "10.1.0.0/16=a+c"}},
+		{"symmetric difference", mkTrie32("10.0.0.0/8", "a", "11.0.0.0/8", "c").SymmetricDifference(mkTrie32("10.0.0.0/9", "b", "12.0.0.0/8", "d")),
+			[]string{"10.128.0.0/9=a", "11.0.0.0/8=c", "12.0.0.0/8=d"}},
+		{"empty", new(TypedTrie32[string]).Union(new(TypedTrie32[string]), nil), nil},
+		{"difference with empty", mkTrie32("10.0.0.0/8", "a", "10.2.0.0/16", "b", "192.168.0.0/16", "c").Difference(new(TypedTrie32[string])),
+			[]string{"10.0.0.0/8=a", "10.2.0.0/16=b", "192.168.0.0/16=c"}},
+		{"symmetric difference with empty", mkTrie32("10.0.0.0/8", "a", "10.2.0.0/16", "b", "192.168.0.0/16", "c").SymmetricDifference(new(TypedTrie32[string])),
+			[]string{"10.0.0.0/8=a", "10.2.0.0/16=b", "192.168.0.0/16=c"}},
+		{"empty symmetric difference", new(TypedTrie32[string]).SymmetricDifference(mkTrie32("10.0.0.0/8", "a", "10.2.0.0/16", "b")),
+			[]string{"10.0.0.0/8=a", "10.2.0.0/16=b"}},
+		{"difference keeps uncut parent", mkTrie32("10.0.0.0/8", "a", "10.2.0.0/16", "b", "11.0.0.0/8", "c").Difference(mkTrie32("11.0.0.0/9", "d")),
+			[]string{"10.0.0.0/8=a", "10.2.0.0/16=b", "11.128.0.0/9=c"}},
+	}
+	for _, tst := range tests {
+		if got := dumpTrie32(tst.res); !slices.Equal(got, tst.want) {
+			t.Errorf("%s: expected\n%v\ngot\n%v", tst.name, tst.want, got)
+		}
+	}
+}
+
+func TestTrieSetOpsMergeSiblings(t *testing.T) {
+	var x, y int
+	A, B := new(Trie32), new(Trie32)
+	A.MustSet([]byte{10, 0, 0, 0}, 9, unsafe.Pointer(&x))
+	B.MustSet([]byte{10, 128, 0, 0}, 9, unsafe.Pointer(&x))
+	B.MustSet([]byte{10, 128, 0, 0}, 10, unsafe.Pointer(&y))
+
+	var got []string
+	for p := range A.Union(B, nil).All() {
+		got = append(got, p.String())
+	}
+	if want := []string{"10.0.0.0/8", "10.128.0.0/10"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// ::/0 minus one host splits all the way down
+	W, H := new(Trie128), new(Trie128)
+	W.MustSet(nil, 0, unsafe.Pointer(&x))
+	H.MustSet([]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, 128, nil)
+	D := W.Difference(H)
+	if D.Len() != 128 {
+		t.Error("Expected 128 prefixes around single host, got", D.Len())
+	}
+	if _, _, ln, _ := D.Get([]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, 128); ln != 0 {
+		t.Error("Host should not be covered, got /", ln)
+	}
+	if _, _, ln, _ := D.Get([]byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 128); ln != 128 {
+		t.Error("Neighbour should be covered by /128, got /", ln)
+	}
+}
+
+func TestTrieAggregate(t *testing.T) {
+	var tests = []struct {
+		in   *TypedTrie32[string]
+		eq   func(a, b string) bool
+		want []string
+	}{
+		{mkTrie32("10.0.0.0/25", "a", "10.0.0.128/25", "b"), nil,
+			[]string{"10.0.0.0/24=a"}},
+		{mkTrie32("10.0.0.0/25", "a", "10.0.0.128/25", "b"), func(a, b string) bool { return a == b },
+			[]string{"10.0.0.0/25=a", "10.0.0.128/25=b"}},
+		{mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "b", "10.2.0.0/16", "a", "11.0.0.0/8", "a"), func(a, b string) bool { return a == b },
+			[]string{"10.0.0.0/7=a", "10.1.0.0/16=b"}},
+		{mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "b", "11.0.0.0/9", "c", "11.128.0.0/10", "d"), nil,
+			[]string{"10.0.0.0/8=a", "11.0.0.0/9=c", "11.128.0.0/10=d"}},
+		{mkTrie32("192.168.0.0/26", "a", "192.168.0.64/26", "a", "192.168.0.128/25", "a", "192.168.1.0/24", "a", "192.168.3.0/24", "a"), nil,
+			[]string{"192.168.0.0/23=a", "192.168.3.0/24=a"}},
+		{mkTrie32(), nil, nil},
+	}
+	for i, tst := range tests {
+		if got := dumpTrie32(tst.in.Aggregate(tst.eq)); !slices.Equal(got, tst.want) {
+			t.Errorf("Case %d: expected\n%v\ngot\n%v", i, tst.want, got)
+		}
+	}
+}
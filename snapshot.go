@@ -0,0 +1,237 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"unsafe"
+)
+
+// Snapshot format written by WriteTo of every trie width, all integers are
+// big endian:
+//
+//	magic    [4]byte "IPTS"
+//	version  byte    1
+//	width    byte    32, 64, 128 or 160
+//	entries  uint64  real nodes
+//	dummies  uint64  dummy nodes
+//	nodes    entries+dummies nodes in preorder: node, lower (b) half, upper (a) half
+//	crc      uint32  CRC-32 (IEEE) of everything above
+//
+// Every node is:
+//
+//	flags     byte  1 dummy, 2 has lower child, 4 has upper child, 8 has value
+//	prefixlen byte
+//	key       (prefixlen+7)/8 bytes
+//	value     uvarint length and that many bytes, only with flag 8
+//
+// Dummy nodes never have a value. Trie structure is stored as is, so
+// ReadFrom rebuilds it without inserts.
+
+const (
+	snapshotMagic   = "IPTS"
+	snapshotVersion = 1
+)
+
+// node flags
+const (
+	snapDummy = 1 << iota
+	snapLower
+	snapUpper
+	snapValue
+)
+
+var (
+	// ErrSnapshotFormat is returned for data that is not a valid snapshot.
+	ErrSnapshotFormat = errors.New("iptrie: malformed snapshot")
+	// ErrSnapshotVersion is returned for snapshots of unknown format version.
+	ErrSnapshotVersion = errors.New("iptrie: unsupported snapshot version")
+	// ErrSnapshotWidth is returned for snapshot of a trie of other width.
+	ErrSnapshotWidth = errors.New("iptrie: snapshot width does not match trie")
+	// ErrSnapshotChecksum is returned when snapshot checksum does not match.
+	ErrSnapshotChecksum = errors.New("iptrie: snapshot checksum mismatch")
+)
+
+// ValueCodec turns values kept in a trie into bytes and back for snapshots.
+// Without codec values are not written and read back as nil.
+type ValueCodec interface {
+	EncodeValue(unsafe.Pointer) ([]byte, error)
+	DecodeValue([]byte) (unsafe.Pointer, error)
+}
+
+type typedCodec[V any] struct {
+	encode func(V) ([]byte, error)
+	decode func([]byte) (V, error)
+}
+
+// TypedCodec makes ValueCodec for typed tries out of functions working on V.
+func TypedCodec[V any](encode func(V) ([]byte, error), decode func([]byte) (V, error)) ValueCodec {
+	return typedCodec[V]{encode, decode}
+}
+
+func (c typedCodec[V]) EncodeValue(p unsafe.Pointer) ([]byte, error) {
+	return c.encode(unboxValue[V](p))
+}
+
+func (c typedCodec[V]) DecodeValue(b []byte) (unsafe.Pointer, error) {
+	value, err := c.decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return boxValue(value), nil
+}
+
+// snapshotWriter keeps count and checksum of written bytes, first error
+// stops all writes.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newSnapshotWriter(w io.Writer, width byte, entries, dummies int) *snapshotWriter {
+	s := &snapshotWriter{w: bufio.NewWriter(w), crc: crc32.NewIEEE()}
+	s.write([]byte(snapshotMagic))
+	s.write([]byte{snapshotVersion, width})
+	s.write(binary.BigEndian.AppendUint64(s.buf[:0], uint64(entries)))
+	s.write(binary.BigEndian.AppendUint64(s.buf[:0], uint64(dummies)))
+	return s
+}
+
+func (s *snapshotWriter) write(b []byte) {
+	if s.err != nil {
+		return
+	}
+	n, err := s.w.Write(b)
+	s.crc.Write(b[:n])
+	s.n += int64(n)
+	s.err = err
+}
+
+// value writes b with its length.
+func (s *snapshotWriter) value(b []byte) {
+	s.write(binary.AppendUvarint(s.buf[:0], uint64(len(b))))
+	s.write(b)
+}
+
+// finish writes checksum and flushes what is buffered.
+func (s *snapshotWriter) finish() (int64, error) {
+	s.write(binary.BigEndian.AppendUint32(s.buf[:0], s.crc.Sum32()))
+	if s.err == nil {
+		s.err = s.w.Flush()
+	}
+	return s.n, s.err
+}
+
+// snapshotReader is snapshotWriter counterpart.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	n   int64
+	err error
+	buf [8]byte
+}
+
+// newSnapshotReader reads header of snapshot of a trie of given width and
+// returns number of real and dummy nodes in it. Reader is buffered unless
+// r is *bufio.Reader already, so pass one to read anything after snapshot.
+func newSnapshotReader(r io.Reader, width byte) (s *snapshotReader, entries, dummies uint64) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	s = &snapshotReader{r: br, crc: crc32.NewIEEE()}
+	head := make([]byte, len(snapshotMagic)+2)
+	s.read(head)
+	switch {
+	case s.err != nil:
+	case string(head[:len(snapshotMagic)]) != snapshotMagic:
+		s.err = ErrSnapshotFormat
+	case head[len(snapshotMagic)] != snapshotVersion:
+		s.err = ErrSnapshotVersion
+	case head[len(snapshotMagic)+1] != width:
+		s.err = ErrSnapshotWidth
+	}
+	return s, s.uint64(), s.uint64()
+}
+
+func (s *snapshotReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	s.crc.Write(b[:n])
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *snapshotReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.crc.Write([]byte{b})
+		s.n++
+	}
+	return b, err
+}
+
+func (s *snapshotReader) read(b []byte) {
+	if s.err != nil {
+		return
+	}
+	if _, err := io.ReadFull(s, b); err != nil {
+		s.err = unexpectedEOF(err)
+	}
+}
+
+func (s *snapshotReader) byte() byte {
+	s.read(s.buf[:1])
+	return s.buf[0]
+}
+
+func (s *snapshotReader) uint64() uint64 {
+	s.read(s.buf[:8])
+	if s.err != nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(s.buf[:8])
+}
+
+// value reads bytes written by snapshotWriter.value. Memory is taken as
+// data comes, so broken length can't make it allocate too much.
+func (s *snapshotReader) value() []byte {
+	if s.err != nil {
+		return nil
+	}
+	n, err := binary.ReadUvarint(s)
+	if err != nil {
+		s.err = unexpectedEOF(err)
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(s, int64(n)))
+	if err == nil && uint64(len(b)) != n {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+	return b
+}
+
+// finish reads and checks checksum.
+func (s *snapshotReader) finish() (int64, error) {
+	sum := s.crc.Sum32()
+	s.read(s.buf[:4])
+	if s.err == nil && binary.BigEndian.Uint32(s.buf[:4]) != sum {
+		s.err = ErrSnapshotChecksum
+	}
+	return s.n, s.err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
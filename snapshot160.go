@@ -0,0 +1,174 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "io"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i snapshot160.go -o snapshot_auto.go
+
+// SetCodec sets codec WriteTo and ReadFrom use for values.
+func (t *Trie160) SetCodec(codec ValueCodec) {
+	t.codec = codec
+}
+
+// WriteTo writes snapshot of the trie to w, format is described in
+// snapshot.go. It returns number of bytes written.
+func (t *Trie160) WriteTo(w io.Writer) (int64, error) {
+	// nodes are counted because Node160.Assign and Strip bypass counters
+	var entries, dummies int
+	if t.node != nil {
+		t.node.Drill(func(n *Node160) {
+			if n.dummy != 0 {
+				dummies++
+			} else {
+				entries++
+			}
+		})
+	}
+	s := newSnapshotWriter(w, MAXBITS, entries, dummies)
+	t.node.writeTo(s, t.codec, make([]byte, MAXBITS/8))
+	return s.finish()
+}
+
+func (node *Node160) writeTo(s *snapshotWriter, codec ValueCodec, key []byte) {
+	if node == nil || s.err != nil {
+		return
+	}
+	var (
+		flags byte
+		value []byte
+	)
+	if node.dummy != 0 {
+		flags |= snapDummy
+	} else if node.data != nil && codec != nil {
+		if value, s.err = codec.EncodeValue(node.data); s.err != nil {
+			return
+		}
+		flags |= snapValue
+	}
+	if node.b != nil {
+		flags |= snapLower
+	}
+	if node.a != nil {
+		flags |= snapUpper
+	}
+	for i := range key {
+		key[i] = byte(node.bits[i/4] >> (24 - 8*(i%4)))
+	}
+	s.write([]byte{flags, node.prefixlen})
+	s.write(key[:(node.prefixlen+7)/8])
+	if flags&snapValue != 0 {
+		s.value(value)
+	}
+	node.b.writeTo(s, codec, key)
+	node.a.writeTo(s, codec, key)
+}
+
+// ReadFrom replaces content of the trie with snapshot read from r. Trie is
+// left unchanged on error. Reading is buffered, bytes after the snapshot
+// are consumed too unless r is *bufio.Reader.
+func (t *Trie160) ReadFrom(r io.Reader) (int64, error) {
+	s, entries, dummies := newSnapshotReader(r, MAXBITS)
+	l := snapshotLoader160{s: s, codec: t.codec, left: entries + dummies}
+	var root *Node160
+	if l.left != 0 {
+		root = l.node(nil, 0)
+	}
+	if s.err == nil && (l.left != 0 || l.entries != entries) {
+		s.err = ErrSnapshotFormat
+	}
+	if n, err := s.finish(); err != nil {
+		return n, err
+	}
+	*t = Trie160{node: root, nodes: l.arena, entries: int(entries), dummies: int(dummies), codec: t.codec}
+	return s.n, nil
+}
+
+// snapshotLoader160 builds nodes of a trie from snapshot.
+type snapshotLoader160 struct {
+	s       *snapshotReader
+	codec   ValueCodec
+	left    uint64 // nodes not read yet
+	entries uint64
+	arena   []Node160
+	key     [MAXBITS / 8]byte
+}
+
+// node reads subtree that goes to half h of parent.
+func (l *snapshotLoader160) node(parent *Node160, h byte) *Node160 {
+	s := l.s
+	flags, ln := s.byte(), s.byte()
+	if s.err == nil && (l.left == 0 || ln > MAXBITS || flags&(snapDummy|snapValue) == snapDummy|snapValue) {
+		s.err = ErrSnapshotFormat
+	}
+	s.read(l.key[:(ln+7)/8])
+	if s.err != nil {
+		return nil
+	}
+	if len(l.arena) == 0 {
+		l.arena = make([]Node160, min(l.left, 1<<16))
+	}
+	node := &l.arena[0]
+	l.arena, l.left = l.arena[1:], l.left-1
+	node.setKey(l.key[:], ln)
+	if parent != nil && (ln <= parent.prefixlen || parent.bitsMatched(node.bits[:], ln) != parent.prefixlen || hasBit(node.bits[:], parent.prefixlen+1) != (h != 0)) {
+		s.err = ErrSnapshotFormat
+		return nil
+	}
+	if flags&snapDummy != 0 {
+		node.dummy = 1
+	} else {
+		l.entries++
+	}
+	if flags&snapValue != 0 {
+		value := s.value()
+		if s.err == nil && l.codec != nil {
+			node.data, s.err = l.codec.DecodeValue(value)
+		}
+	}
+	if flags&snapLower != 0 && s.err == nil {
+		node.b = l.node(node, 0)
+	}
+	if flags&snapUpper != 0 && s.err == nil {
+		node.a = l.node(node, 1)
+	}
+	return node
+}
+
+// SetCodec sets codec for values, build it with TypedCodec.
+func (t *TypedTrie160[V]) SetCodec(codec ValueCodec) {
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot of the trie, see Trie160.WriteTo.
+func (t *TypedTrie160[V]) WriteTo(w io.Writer) (int64, error) {
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie with snapshot, see Trie160.ReadFrom.
+func (t *TypedTrie160[V]) ReadFrom(r io.Reader) (int64, error) {
+	return t.trie.ReadFrom(r)
+}
+
+func (t *SyncTrie160) SetCodec(codec ValueCodec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot holding read lock.
+func (t *SyncTrie160) WriteTo(w io.Writer) (int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie holding write lock.
+func (t *SyncTrie160) ReadFrom(r io.Reader) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.ReadFrom(r)
+}
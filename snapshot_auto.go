@@ -0,0 +1,499 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"io"
+)
+
+// SetCodec sets codec WriteTo and ReadFrom use for values.
+func (t *Trie32) SetCodec(codec ValueCodec) {
+	t.codec = codec
+}
+
+// WriteTo writes snapshot of the trie to w, format is described in
+// snapshot.go. It returns number of bytes written.
+func (t *Trie32) WriteTo(w io.Writer) (int64, error) {
+	// nodes are counted because Node32.Assign and Strip bypass counters
+	var entries, dummies int
+	if t.node != nil {
+		t.node.Drill(func(n *Node32) {
+			if n.dummy != 0 {
+				dummies++
+			} else {
+				entries++
+			}
+		})
+	}
+	s := newSnapshotWriter(w, 32, entries, dummies)
+	t.node.writeTo(s, t.codec, make([]byte, 32/8))
+	return s.finish()
+}
+
+func (node *Node32) writeTo(s *snapshotWriter, codec ValueCodec, key []byte) {
+	if node == nil || s.err != nil {
+		return
+	}
+	var (
+		flags byte
+		value []byte
+	)
+	if node.dummy != 0 {
+		flags |= snapDummy
+	} else if node.data != nil && codec != nil {
+		if value, s.err = codec.EncodeValue(node.data); s.err != nil {
+			return
+		}
+		flags |= snapValue
+	}
+	if node.b != nil {
+		flags |= snapLower
+	}
+	if node.a != nil {
+		flags |= snapUpper
+	}
+	for i := range key {
+		key[i] = byte(node.bits[i/4] >> (24 - 8*(i%4)))
+	}
+	s.write([]byte{flags, node.prefixlen})
+	s.write(key[:(node.prefixlen+7)/8])
+	if flags&snapValue != 0 {
+		s.value(value)
+	}
+	node.b.writeTo(s, codec, key)
+	node.a.writeTo(s, codec, key)
+}
+
+// ReadFrom replaces content of the trie with snapshot read from r. Trie is
+// left unchanged on error. Reading is buffered, bytes after the snapshot
+// are consumed too unless r is *bufio.Reader.
+func (t *Trie32) ReadFrom(r io.Reader) (int64, error) {
+	s, entries, dummies := newSnapshotReader(r, 32)
+	l := snapshotLoader32{s: s, codec: t.codec, left: entries + dummies}
+	var root *Node32
+	if l.left != 0 {
+		root = l.node(nil, 0)
+	}
+	if s.err == nil && (l.left != 0 || l.entries != entries) {
+		s.err = ErrSnapshotFormat
+	}
+	if n, err := s.finish(); err != nil {
+		return n, err
+	}
+	*t = Trie32{node: root, nodes: l.arena, entries: int(entries), dummies: int(dummies), codec: t.codec}
+	return s.n, nil
+}
+
+// snapshotLoader32 builds nodes of a trie from snapshot.
+type snapshotLoader32 struct {
+	s       *snapshotReader
+	codec   ValueCodec
+	left    uint64 // nodes not read yet
+	entries uint64
+	arena   []Node32
+	key     [32 / 8]byte
+}
+
+// node reads subtree that goes to half h of parent.
+func (l *snapshotLoader32) node(parent *Node32, h byte) *Node32 {
+	s := l.s
+	flags, ln := s.byte(), s.byte()
+	if s.err == nil && (l.left == 0 || ln > 32 || flags&(snapDummy|snapValue) == snapDummy|snapValue) {
+		s.err = ErrSnapshotFormat
+	}
+	s.read(l.key[:(ln+7)/8])
+	if s.err != nil {
+		return nil
+	}
+	if len(l.arena) == 0 {
+		l.arena = make([]Node32, min(l.left, 1<<16))
+	}
+	node := &l.arena[0]
+	l.arena, l.left = l.arena[1:], l.left-1
+	node.setKey(l.key[:], ln)
+	if parent != nil && (ln <= parent.prefixlen || parent.bitsMatched(node.bits[:], ln) != parent.prefixlen || hasBit(node.bits[:], parent.prefixlen+1) != (h != 0)) {
+		s.err = ErrSnapshotFormat
+		return nil
+	}
+	if flags&snapDummy != 0 {
+		node.dummy = 1
+	} else {
+		l.entries++
+	}
+	if flags&snapValue != 0 {
+		value := s.value()
+		if s.err == nil && l.codec != nil {
+			node.data, s.err = l.codec.DecodeValue(value)
+		}
+	}
+	if flags&snapLower != 0 && s.err == nil {
+		node.b = l.node(node, 0)
+	}
+	if flags&snapUpper != 0 && s.err == nil {
+		node.a = l.node(node, 1)
+	}
+	return node
+}
+
+// SetCodec sets codec for values, build it with TypedCodec.
+func (t *TypedTrie32[V]) SetCodec(codec ValueCodec) {
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot of the trie, see Trie32.WriteTo.
+func (t *TypedTrie32[V]) WriteTo(w io.Writer) (int64, error) {
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie with snapshot, see Trie32.ReadFrom.
+func (t *TypedTrie32[V]) ReadFrom(r io.Reader) (int64, error) {
+	return t.trie.ReadFrom(r)
+}
+
+func (t *SyncTrie32) SetCodec(codec ValueCodec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot holding read lock.
+func (t *SyncTrie32) WriteTo(w io.Writer) (int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie holding write lock.
+func (t *SyncTrie32) ReadFrom(r io.Reader) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.ReadFrom(r)
+}
+
+// SetCodec sets codec WriteTo and ReadFrom use for values.
+func (t *Trie64) SetCodec(codec ValueCodec) {
+	t.codec = codec
+}
+
+// WriteTo writes snapshot of the trie to w, format is described in
+// snapshot.go. It returns number of bytes written.
+func (t *Trie64) WriteTo(w io.Writer) (int64, error) {
+	// nodes are counted because Node64.Assign and Strip bypass counters
+	var entries, dummies int
+	if t.node != nil {
+		t.node.Drill(func(n *Node64) {
+			if n.dummy != 0 {
+				dummies++
+			} else {
+				entries++
+			}
+		})
+	}
+	s := newSnapshotWriter(w, 64, entries, dummies)
+	t.node.writeTo(s, t.codec, make([]byte, 64/8))
+	return s.finish()
+}
+
+func (node *Node64) writeTo(s *snapshotWriter, codec ValueCodec, key []byte) {
+	if node == nil || s.err != nil {
+		return
+	}
+	var (
+		flags byte
+		value []byte
+	)
+	if node.dummy != 0 {
+		flags |= snapDummy
+	} else if node.data != nil && codec != nil {
+		if value, s.err = codec.EncodeValue(node.data); s.err != nil {
+			return
+		}
+		flags |= snapValue
+	}
+	if node.b != nil {
+		flags |= snapLower
+	}
+	if node.a != nil {
+		flags |= snapUpper
+	}
+	for i := range key {
+		key[i] = byte(node.bits[i/4] >> (24 - 8*(i%4)))
+	}
+	s.write([]byte{flags, node.prefixlen})
+	s.write(key[:(node.prefixlen+7)/8])
+	if flags&snapValue != 0 {
+		s.value(value)
+	}
+	node.b.writeTo(s, codec, key)
+	node.a.writeTo(s, codec, key)
+}
+
+// ReadFrom replaces content of the trie with snapshot read from r. Trie is
+// left unchanged on error. Reading is buffered, bytes after the snapshot
+// are consumed too unless r is *bufio.Reader.
+func (t *Trie64) ReadFrom(r io.Reader) (int64, error) {
+	s, entries, dummies := newSnapshotReader(r, 64)
+	l := snapshotLoader64{s: s, codec: t.codec, left: entries + dummies}
+	var root *Node64
+	if l.left != 0 {
+		root = l.node(nil, 0)
+	}
+	if s.err == nil && (l.left != 0 || l.entries != entries) {
+		s.err = ErrSnapshotFormat
+	}
+	if n, err := s.finish(); err != nil {
+		return n, err
+	}
+	*t = Trie64{node: root, nodes: l.arena, entries: int(entries), dummies: int(dummies), codec: t.codec}
+	return s.n, nil
+}
+
+// snapshotLoader64 builds nodes of a trie from snapshot.
+type snapshotLoader64 struct {
+	s       *snapshotReader
+	codec   ValueCodec
+	left    uint64 // nodes not read yet
+	entries uint64
+	arena   []Node64
+	key     [64 / 8]byte
+}
+
+// node reads subtree that goes to half h of parent.
+func (l *snapshotLoader64) node(parent *Node64, h byte) *Node64 {
+	s := l.s
+	flags, ln := s.byte(), s.byte()
+	if s.err == nil && (l.left == 0 || ln > 64 || flags&(snapDummy|snapValue) == snapDummy|snapValue) {
+		s.err = ErrSnapshotFormat
+	}
+	s.read(l.key[:(ln+7)/8])
+	if s.err != nil {
+		return nil
+	}
+	if len(l.arena) == 0 {
+		l.arena = make([]Node64, min(l.left, 1<<16))
+	}
+	node := &l.arena[0]
+	l.arena, l.left = l.arena[1:], l.left-1
+	node.setKey(l.key[:], ln)
+	if parent != nil && (ln <= parent.prefixlen || parent.bitsMatched(node.bits[:], ln) != parent.prefixlen || hasBit(node.bits[:], parent.prefixlen+1) != (h != 0)) {
+		s.err = ErrSnapshotFormat
+		return nil
+	}
+	if flags&snapDummy != 0 {
+		node.dummy = 1
+	} else {
+		l.entries++
+	}
+	if flags&snapValue != 0 {
+		value := s.value()
+		if s.err == nil && l.codec != nil {
+			node.data, s.err = l.codec.DecodeValue(value)
+		}
+	}
+	if flags&snapLower != 0 && s.err == nil {
+		node.b = l.node(node, 0)
+	}
+	if flags&snapUpper != 0 && s.err == nil {
+		node.a = l.node(node, 1)
+	}
+	return node
+}
+
+// SetCodec sets codec for values, build it with TypedCodec.
+func (t *TypedTrie64[V]) SetCodec(codec ValueCodec) {
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot of the trie, see Trie64.WriteTo.
+func (t *TypedTrie64[V]) WriteTo(w io.Writer) (int64, error) {
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie with snapshot, see Trie64.ReadFrom.
+func (t *TypedTrie64[V]) ReadFrom(r io.Reader) (int64, error) {
+	return t.trie.ReadFrom(r)
+}
+
+func (t *SyncTrie64) SetCodec(codec ValueCodec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot holding read lock.
+func (t *SyncTrie64) WriteTo(w io.Writer) (int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie holding write lock.
+func (t *SyncTrie64) ReadFrom(r io.Reader) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.ReadFrom(r)
+}
+
+// SetCodec sets codec WriteTo and ReadFrom use for values.
+func (t *Trie128) SetCodec(codec ValueCodec) {
+	t.codec = codec
+}
+
+// WriteTo writes snapshot of the trie to w, format is described in
+// snapshot.go. It returns number of bytes written.
+func (t *Trie128) WriteTo(w io.Writer) (int64, error) {
+	// nodes are counted because Node128.Assign and Strip bypass counters
+	var entries, dummies int
+	if t.node != nil {
+		t.node.Drill(func(n *Node128) {
+			if n.dummy != 0 {
+				dummies++
+			} else {
+				entries++
+			}
+		})
+	}
+	s := newSnapshotWriter(w, 128, entries, dummies)
+	t.node.writeTo(s, t.codec, make([]byte, 128/8))
+	return s.finish()
+}
+
+func (node *Node128) writeTo(s *snapshotWriter, codec ValueCodec, key []byte) {
+	if node == nil || s.err != nil {
+		return
+	}
+	var (
+		flags byte
+		value []byte
+	)
+	if node.dummy != 0 {
+		flags |= snapDummy
+	} else if node.data != nil && codec != nil {
+		if value, s.err = codec.EncodeValue(node.data); s.err != nil {
+			return
+		}
+		flags |= snapValue
+	}
+	if node.b != nil {
+		flags |= snapLower
+	}
+	if node.a != nil {
+		flags |= snapUpper
+	}
+	for i := range key {
+		key[i] = byte(node.bits[i/4] >> (24 - 8*(i%4)))
+	}
+	s.write([]byte{flags, node.prefixlen})
+	s.write(key[:(node.prefixlen+7)/8])
+	if flags&snapValue != 0 {
+		s.value(value)
+	}
+	node.b.writeTo(s, codec, key)
+	node.a.writeTo(s, codec, key)
+}
+
+// ReadFrom replaces content of the trie with snapshot read from r. Trie is
+// left unchanged on error. Reading is buffered, bytes after the snapshot
+// are consumed too unless r is *bufio.Reader.
+func (t *Trie128) ReadFrom(r io.Reader) (int64, error) {
+	s, entries, dummies := newSnapshotReader(r, 128)
+	l := snapshotLoader128{s: s, codec: t.codec, left: entries + dummies}
+	var root *Node128
+	if l.left != 0 {
+		root = l.node(nil, 0)
+	}
+	if s.err == nil && (l.left != 0 || l.entries != entries) {
+		s.err = ErrSnapshotFormat
+	}
+	if n, err := s.finish(); err != nil {
+		return n, err
+	}
+	*t = Trie128{node: root, nodes: l.arena, entries: int(entries), dummies: int(dummies), codec: t.codec}
+	return s.n, nil
+}
+
+// snapshotLoader128 builds nodes of a trie from snapshot.
+type snapshotLoader128 struct {
+	s       *snapshotReader
+	codec   ValueCodec
+	left    uint64 // nodes not read yet
+	entries uint64
+	arena   []Node128
+	key     [128 / 8]byte
+}
+
+// node reads subtree that goes to half h of parent.
+func (l *snapshotLoader128) node(parent *Node128, h byte) *Node128 {
+	s := l.s
+	flags, ln := s.byte(), s.byte()
+	if s.err == nil && (l.left == 0 || ln > 128 || flags&(snapDummy|snapValue) == snapDummy|snapValue) {
+		s.err = ErrSnapshotFormat
+	}
+	s.read(l.key[:(ln+7)/8])
+	if s.err != nil {
+		return nil
+	}
+	if len(l.arena) == 0 {
+		l.arena = make([]Node128, min(l.left, 1<<16))
+	}
+	node := &l.arena[0]
+	l.arena, l.left = l.arena[1:], l.left-1
+	node.setKey(l.key[:], ln)
+	if parent != nil && (ln <= parent.prefixlen || parent.bitsMatched(node.bits[:], ln) != parent.prefixlen || hasBit(node.bits[:], parent.prefixlen+1) != (h != 0)) {
+		s.err = ErrSnapshotFormat
+		return nil
+	}
+	if flags&snapDummy != 0 {
+		node.dummy = 1
+	} else {
+		l.entries++
+	}
+	if flags&snapValue != 0 {
+		value := s.value()
+		if s.err == nil && l.codec != nil {
+			node.data, s.err = l.codec.DecodeValue(value)
+		}
+	}
+	if flags&snapLower != 0 && s.err == nil {
+		node.b = l.node(node, 0)
+	}
+	if flags&snapUpper != 0 && s.err == nil {
+		node.a = l.node(node, 1)
+	}
+	return node
+}
+
+// SetCodec sets codec for values, build it with TypedCodec.
+func (t *TypedTrie128[V]) SetCodec(codec ValueCodec) {
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot of the trie, see Trie128.WriteTo.
+func (t *TypedTrie128[V]) WriteTo(w io.Writer) (int64, error) {
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie with snapshot, see Trie128.ReadFrom.
+func (t *TypedTrie128[V]) ReadFrom(r io.Reader) (int64, error) {
+	return t.trie.ReadFrom(r)
+}
+
+func (t *SyncTrie128) SetCodec(codec ValueCodec) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.SetCodec(codec)
+}
+
+// WriteTo writes snapshot holding read lock.
+func (t *SyncTrie128) WriteTo(w io.Writer) (int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.WriteTo(w)
+}
+
+// ReadFrom replaces content of the trie holding write lock.
+func (t *SyncTrie128) ReadFrom(r io.Reader) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.ReadFrom(r)
+}
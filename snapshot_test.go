@@ -0,0 +1,143 @@
+package iptrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+var stringCodec = TypedCodec(
+	func(s string) ([]byte, error) { return []byte(s), nil },
+	func(b []byte) (string, error) { return string(b), nil },
+)
+
+func TestTrieSnapshot(t *testing.T) {
+	T := mkTrie32("0.0.0.0/0", "default", "10.0.0.0/8", "a", "10.1.0.0/16", "", "10.1.2.0/24", "c", "192.168.0.0/24", "d")
+	T.MustGetNode([]byte{10, 2, 0, 0}, 16) // real node without value
+	T.SetCodec(stringCodec)
+	var buf bytes.Buffer
+	n, err := T.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d, %v for %d bytes", n, err, buf.Len())
+	}
+
+	R := new(TypedTrie32[string])
+	R.SetCodec(stringCodec)
+	if n, err = R.ReadFrom(bytes.NewReader(buf.Bytes())); err != nil || n != int64(buf.Len()) {
+		t.Fatalf("ReadFrom returned %d, %v for %d bytes", n, err, buf.Len())
+	}
+	if got, want := dumpTrie32(R), dumpTrie32(T); !slices.Equal(got, want) {
+		t.Errorf("Expected\n%v\ngot\n%v", want, got)
+	}
+	if s, sr := T.Stats(), R.Stats(); s.Entries != sr.Entries || s.Dummies != sr.Dummies || s.MaxDepth != sr.MaxDepth {
+		t.Errorf("Structure differs: %+v and %+v", s, sr)
+	}
+	R.MustSet([]byte{10, 1, 2, 128}, 25, "e")
+	if _, _, _, value := R.Get([]byte{10, 1, 2, 200}, 32); value != "e" {
+		t.Errorf("Expected loaded trie to accept inserts, got %q", value)
+	}
+}
+
+func TestTrieSnapshotRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(21))
+	T := new(Trie128)
+	values := make([]int, 5000)
+	for i := range values {
+		values[i] = i
+		ip := make([]byte, 16)
+		rnd.Read(ip[:8])
+		T.MustSet(ip, byte(16+rnd.Intn(49)), unsafe.Pointer(&values[i]))
+	}
+	codec := TypedCodec(
+		func(v int) ([]byte, error) { return binary.AppendUvarint(nil, uint64(v)), nil },
+		func(b []byte) (int, error) { v, _ := binary.Uvarint(b); return int(v), nil },
+	)
+	T.SetCodec(codec)
+	var buf bytes.Buffer
+	if _, err := T.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	R := new(Trie128)
+	R.SetCodec(codec)
+	if _, err := R.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dump := func(T *Trie128) []string {
+		var res []string
+		for p, v := range T.All() {
+			res = append(res, fmt.Sprintf("%v=%d", p, *(*int)(v)))
+		}
+		return res
+	}
+	if got, want := dump(R), dump(T); !slices.Equal(got, want) {
+		t.Errorf("Loaded trie differs, %d entries instead of %d", len(got), len(want))
+	}
+}
+
+func TestTrieSnapshotAfterStrip(t *testing.T) {
+	T := mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "b", "11.0.0.0/8", "c")
+	// node methods leave dummies with one child or none behind
+	for _, key := range [][]byte{{10, 0, 0, 0}, {11, 0, 0, 0}} {
+		_, node := T.MustGetNode(key, 8)
+		node.Strip()
+	}
+	T.SetCodec(stringCodec)
+	var buf bytes.Buffer
+	if _, err := T.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	R := new(TypedTrie32[string])
+	R.SetCodec(stringCodec)
+	if _, err := R.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := dumpTrie32(R); !slices.Equal(got, []string{"10.1.0.0/16=b"}) {
+		t.Errorf("Unexpected entries after round trip: %v", got)
+	}
+	if entries, dummies := walkStats(R.Trie()); R.Len() != entries || R.Stats().Dummies != dummies {
+		t.Errorf("Counters say %d entries and %d dummies, walk found %d and %d", R.Len(), R.Stats().Dummies, entries, dummies)
+	}
+}
+
+func TestTrieSnapshotErrors(t *testing.T) {
+	T := mkTrie32("10.0.0.0/8", "a", "10.1.0.0/16", "b", "11.0.0.0/8", "c")
+	T.SetCodec(stringCodec)
+	var buf bytes.Buffer
+	T.WriteTo(&buf)
+	good := buf.Bytes()
+
+	corrupt := func(pos int, b byte) []byte {
+		c := slices.Clone(good)
+		c[pos] = b
+		return c
+	}
+	var tests = []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"magic", corrupt(0, 'X'), ErrSnapshotFormat},
+		{"version", corrupt(4, 2), ErrSnapshotVersion},
+		{"width", corrupt(5, 128), ErrSnapshotWidth},
+		{"checksum", corrupt(len(good)-1, good[len(good)-1]^1), ErrSnapshotChecksum},
+		{"value", corrupt(len(good)-5, 'x'), ErrSnapshotChecksum},
+		{"entries", corrupt(13, 4), ErrSnapshotFormat},
+		{"truncated", good[:len(good)-3], io.ErrUnexpectedEOF},
+		{"empty", nil, io.ErrUnexpectedEOF},
+	}
+	for _, tst := range tests {
+		R := mkTrie32("1.0.0.0/8", "keep")
+		if _, err := R.ReadFrom(bytes.NewReader(tst.data)); !errors.Is(err, tst.err) {
+			t.Errorf("%s: expected %v, got %v", tst.name, tst.err, err)
+		}
+		if got := dumpTrie32(R); !slices.Equal(got, []string{"1.0.0.0/8=keep"}) {
+			t.Errorf("%s: trie changed on error: %v", tst.name, got)
+		}
+	}
+}
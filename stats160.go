@@ -0,0 +1,60 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i stats160.go -o stats_auto.go
+
+// Len returns number of entries, dummy nodes are not counted.
+func (t *Trie160) Len() int {
+	return t.entries
+}
+
+// Stats returns counters kept by the trie along with prefix length
+// histogram and depth, these two need a walk over whole trie.
+func (t *Trie160) Stats() Stats {
+	s := Stats{
+		Entries: t.entries,
+		Dummies: t.dummies,
+		Pooled:  len(t.nodes) + t.freed,
+		Lengths: make([]int, MAXBITS+1),
+	}
+	t.node.depth(1, &s)
+	return s
+}
+
+func (node *Node160) depth(level int, s *Stats) {
+	if node == nil {
+		return
+	}
+	if level > s.MaxDepth {
+		s.MaxDepth = level
+	}
+	if node.dummy == 0 {
+		s.Lengths[node.prefixlen]++
+	}
+	node.b.depth(level+1, s)
+	node.a.depth(level+1, s)
+}
+
+func (t *TypedTrie160[V]) Len() int {
+	return t.trie.Len()
+}
+
+func (t *TypedTrie160[V]) Stats() Stats {
+	return t.trie.Stats()
+}
+
+func (t *SyncTrie160) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Len()
+}
+
+func (t *SyncTrie160) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Stats()
+}
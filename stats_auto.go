@@ -0,0 +1,159 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+// Len returns number of entries, dummy nodes are not counted.
+func (t *Trie32) Len() int {
+	return t.entries
+}
+
+// Stats returns counters kept by the trie along with prefix length
+// histogram and depth, these two need a walk over whole trie.
+func (t *Trie32) Stats() Stats {
+	s := Stats{
+		Entries: t.entries,
+		Dummies: t.dummies,
+		Pooled:  len(t.nodes) + t.freed,
+		Lengths: make([]int, 32+1),
+	}
+	t.node.depth(1, &s)
+	return s
+}
+
+func (node *Node32) depth(level int, s *Stats) {
+	if node == nil {
+		return
+	}
+	if level > s.MaxDepth {
+		s.MaxDepth = level
+	}
+	if node.dummy == 0 {
+		s.Lengths[node.prefixlen]++
+	}
+	node.b.depth(level+1, s)
+	node.a.depth(level+1, s)
+}
+
+func (t *TypedTrie32[V]) Len() int {
+	return t.trie.Len()
+}
+
+func (t *TypedTrie32[V]) Stats() Stats {
+	return t.trie.Stats()
+}
+
+func (t *SyncTrie32) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Len()
+}
+
+func (t *SyncTrie32) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Stats()
+}
+
+// Len returns number of entries, dummy nodes are not counted.
+func (t *Trie64) Len() int {
+	return t.entries
+}
+
+// Stats returns counters kept by the trie along with prefix length
+// histogram and depth, these two need a walk over whole trie.
+func (t *Trie64) Stats() Stats {
+	s := Stats{
+		Entries: t.entries,
+		Dummies: t.dummies,
+		Pooled:  len(t.nodes) + t.freed,
+		Lengths: make([]int, 64+1),
+	}
+	t.node.depth(1, &s)
+	return s
+}
+
+func (node *Node64) depth(level int, s *Stats) {
+	if node == nil {
+		return
+	}
+	if level > s.MaxDepth {
+		s.MaxDepth = level
+	}
+	if node.dummy == 0 {
+		s.Lengths[node.prefixlen]++
+	}
+	node.b.depth(level+1, s)
+	node.a.depth(level+1, s)
+}
+
+func (t *TypedTrie64[V]) Len() int {
+	return t.trie.Len()
+}
+
+func (t *TypedTrie64[V]) Stats() Stats {
+	return t.trie.Stats()
+}
+
+func (t *SyncTrie64) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Len()
+}
+
+func (t *SyncTrie64) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Stats()
+}
+
+// Len returns number of entries, dummy nodes are not counted.
+func (t *Trie128) Len() int {
+	return t.entries
+}
+
+// Stats returns counters kept by the trie along with prefix length
+// histogram and depth, these two need a walk over whole trie.
+func (t *Trie128) Stats() Stats {
+	s := Stats{
+		Entries: t.entries,
+		Dummies: t.dummies,
+		Pooled:  len(t.nodes) + t.freed,
+		Lengths: make([]int, 128+1),
+	}
+	t.node.depth(1, &s)
+	return s
+}
+
+func (node *Node128) depth(level int, s *Stats) {
+	if node == nil {
+		return
+	}
+	if level > s.MaxDepth {
+		s.MaxDepth = level
+	}
+	if node.dummy == 0 {
+		s.Lengths[node.prefixlen]++
+	}
+	node.b.depth(level+1, s)
+	node.a.depth(level+1, s)
+}
+
+func (t *TypedTrie128[V]) Len() int {
+	return t.trie.Len()
+}
+
+func (t *TypedTrie128[V]) Stats() Stats {
+	return t.trie.Stats()
+}
+
+func (t *SyncTrie128) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Len()
+}
+
+func (t *SyncTrie128) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Stats()
+}
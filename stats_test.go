@@ -0,0 +1,123 @@
+package iptrie
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// walkStats counts nodes the slow way to check counters kept by trie.
+func walkStats(T *Trie32) (entries, dummies int) {
+	if T.Root() == nil {
+		return
+	}
+	T.Root().Drill(func(n *Node32) {
+		if n.IsDummy() {
+			dummies++
+		} else {
+			entries++
+		}
+	})
+	return
+}
+
+func TestTrieStats(t *testing.T) {
+	var T = new(Trie32)
+	if s := T.Stats(); T.Len() != 0 || s.MaxDepth != 0 || len(s.Lengths) != 33 {
+		t.Error("Unexpected stats for empty trie", s)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	var keys [][5]byte
+	for i := 0; i < 2000; i++ {
+		u := rnd.Uint32()
+		k := [5]byte{byte(u >> 24), byte(u >> 16), byte(u >> 8), byte(u), byte(rnd.Intn(25) + 8)}
+		keys = append(keys, k)
+		switch i % 5 {
+		case 0:
+			if _, node, _ := T.GetNode(k[:4], k[4]); i%2 == 0 {
+				T.Assign(node, nil)
+			}
+		case 1:
+			old := keys[rnd.Intn(len(keys))]
+			T.Remove(old[:4], old[4])
+		case 2:
+			old := keys[rnd.Intn(len(keys))]
+			if exact, node, _ := T.Root().findBestMatch(old[:4], old[4]); exact {
+				T.Strip(node)
+			}
+		default:
+			T.MustSet(k[:4], k[4], nil)
+		}
+
+		entries, dummies := walkStats(T)
+		if T.Len() != entries || T.Stats().Dummies != dummies {
+			t.Fatalf("Step %d: counters say %d entries and %d dummies, walk found %d and %d", i, T.Len(), T.Stats().Dummies, entries, dummies)
+		}
+	}
+
+	s := T.Stats()
+	var total int
+	for _, n := range s.Lengths[:8] {
+		total += n
+	}
+	if total != 0 {
+		t.Error("No prefixes shorter than /8 were added, got", total)
+	}
+	for _, n := range s.Lengths {
+		total += n
+	}
+	if total != s.Entries || s.MaxDepth < 2 || s.Pooled >= 20 {
+		t.Errorf("Inconsistent stats: %d in histogram, %+v", total, s)
+	}
+}
+
+func TestTrieStatsAfterMove(t *testing.T) {
+	var T = new(Trie32)
+	for i := 0; i < 3; i++ {
+		T.MustSet([]byte{10, byte(i), 0, 0}, 16, nil)
+	}
+	// trie moved by value keeps working with its own counters
+	U := *T
+	U.Remove([]byte{10, 1, 0, 0}, 16)
+	_, node := U.MustGetNode([]byte{10, 0, 0, 0}, 8)
+	U.Strip(node)
+	if entries, dummies := walkStats(&U); U.Len() != entries || U.Stats().Dummies != dummies || entries != 2 {
+		t.Errorf("Counters say %d entries and %d dummies, walk found %d and %d", U.Len(), U.Stats().Dummies, entries, dummies)
+	}
+}
+
+func TestTrieStripKeepsLen(t *testing.T) {
+	var T = new(Trie32)
+	T.MustSet([]byte{10, 0, 0, 0}, 8, nil)
+	T.MustSet([]byte{10, 1, 0, 0}, 16, nil)
+	_, node := T.MustGetNode([]byte{10, 0, 0, 0}, 8)
+	T.Strip(node)
+	if entries, _ := walkStats(T); T.Len() != 1 || entries != 1 {
+		t.Errorf("Expected 1 entry after Strip, counters say %d and walk found %d", T.Len(), entries)
+	}
+}
+
+func TestTrieStripCollapses(t *testing.T) {
+	var T = new(Trie32)
+	T.MustSet([]byte{10, 0, 0, 0}, 8, nil)
+	T.MustSet([]byte{10, 1, 0, 0}, 16, nil)
+	T.MustSet([]byte{10, 2, 0, 0}, 16, nil)
+	T.MustSet([]byte{11, 0, 0, 0}, 8, nil)
+
+	// leaf, node left with single child, leaf that leaves its parent dummy alone
+	for _, k := range [][]byte{{10, 1, 0, 0, 16}, {10, 0, 0, 0, 8}, {11, 0, 0, 0, 8}} {
+		_, node := T.MustGetNode(k[:4], k[4])
+		T.Strip(node)
+		entries, dummies := walkStats(T)
+		if s := T.Stats(); T.Len() != entries || s.Dummies != dummies {
+			t.Fatalf("Counters say %d entries and %d dummies, walk found %d and %d", T.Len(), s.Dummies, entries, dummies)
+		}
+	}
+	if s := T.Stats(); T.Len() != 1 || s.Dummies != 0 {
+		t.Errorf("Expected single entry and no dummies after Strip, got %+v", s)
+	}
+	T.Remove([]byte{10, 2, 0, 0}, 16)
+	if s := T.Stats(); T.Root() != nil || s.Dummies != 0 || s.Pooled != 20 {
+		t.Errorf("Expected empty trie with all nodes pooled, got %+v", s)
+	}
+}
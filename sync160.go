@@ -0,0 +1,115 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i sync160.go -o sync_auto.go
+
+// SyncTrie160 guards Trie160 with a RWMutex so lookups proceed concurrently
+// while changes are serialized.
+//
+// Methods taking read lock: Get, Data, Clone, Validate, View.
+// Methods taking write lock: Set, Append, Remove, GetNode, Assign, Strip,
+// Compact, Update.
+//
+// GetNode is a writer because it adds a node when prefix is missing. Nodes
+// returned by Set, Append and GetNode are changed with Assign and Strip of
+// SyncTrie160, deprecated Node160.Assign and Strip skip both the lock and
+// counters of the trie.
+type SyncTrie160 struct {
+	mu   sync.RWMutex
+	trie Trie160
+}
+
+func (t *SyncTrie160) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Get(ip, mask)
+}
+
+func (t *SyncTrie160) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return t.trie.Validate(ip, mask, mode) // does not look at the tree
+}
+
+func (t *SyncTrie160) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Set(ip, mask, value)
+}
+
+func (t *SyncTrie160) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Append(ip, mask, value)
+}
+
+func (t *SyncTrie160) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Remove(ip, mask)
+}
+
+// GetNode takes write lock, see Trie160.GetNode.
+func (t *SyncTrie160) GetNode(ip []byte, mask byte) (bool, *Node160, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.GetNode(ip, mask)
+}
+
+// Compact takes write lock, see Trie160.Compact.
+func (t *SyncTrie160) Compact() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Compact()
+}
+
+// Clone takes read lock and returns deep copy, see Trie160.Clone.
+func (t *SyncTrie160) Clone(value func(unsafe.Pointer) unsafe.Pointer) *SyncTrie160 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := new(SyncTrie160)
+	t.trie.cloneTo(&c.trie, value)
+	return c
+}
+
+// Data reads value of a node that belongs to this trie.
+func (t *SyncTrie160) Data(node *Node160) unsafe.Pointer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return node.Data()
+}
+
+// Assign sets value of a node that belongs to this trie.
+func (t *SyncTrie160) Assign(node *Node160, value unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Assign(node, value)
+}
+
+// Strip drops value of a node that belongs to this trie, see Trie160.Strip.
+func (t *SyncTrie160) Strip(node *Node160) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Strip(node)
+}
+
+// View calls f under read lock. f must not change the trie or its nodes.
+func (t *SyncTrie160) View(f func(*Trie160)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f(&t.trie)
+}
+
+// Update calls f under write lock, e.g. to apply a batch of changes at once.
+func (t *SyncTrie160) Update(f func(*Trie160)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.trie)
+}
@@ -0,0 +1,314 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// SyncTrie32 guards Trie32 with a RWMutex so lookups proceed concurrently
+// while changes are serialized.
+//
+// Methods taking read lock: Get, Data, Clone, Validate, View.
+// Methods taking write lock: Set, Append, Remove, GetNode, Assign, Strip,
+// Compact, Update.
+//
+// GetNode is a writer because it adds a node when prefix is missing. Nodes
+// returned by Set, Append and GetNode are changed with Assign and Strip of
+// SyncTrie32, deprecated Node32.Assign and Strip skip both the lock and
+// counters of the trie.
+type SyncTrie32 struct {
+	mu   sync.RWMutex
+	trie Trie32
+}
+
+func (t *SyncTrie32) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Get(ip, mask)
+}
+
+func (t *SyncTrie32) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return t.trie.Validate(ip, mask, mode) // does not look at the tree
+}
+
+func (t *SyncTrie32) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Set(ip, mask, value)
+}
+
+func (t *SyncTrie32) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Append(ip, mask, value)
+}
+
+func (t *SyncTrie32) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Remove(ip, mask)
+}
+
+// GetNode takes write lock, see Trie32.GetNode.
+func (t *SyncTrie32) GetNode(ip []byte, mask byte) (bool, *Node32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.GetNode(ip, mask)
+}
+
+// Compact takes write lock, see Trie32.Compact.
+func (t *SyncTrie32) Compact() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Compact()
+}
+
+// Clone takes read lock and returns deep copy, see Trie32.Clone.
+func (t *SyncTrie32) Clone(value func(unsafe.Pointer) unsafe.Pointer) *SyncTrie32 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := new(SyncTrie32)
+	t.trie.cloneTo(&c.trie, value)
+	return c
+}
+
+// Data reads value of a node that belongs to this trie.
+func (t *SyncTrie32) Data(node *Node32) unsafe.Pointer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return node.Data()
+}
+
+// Assign sets value of a node that belongs to this trie.
+func (t *SyncTrie32) Assign(node *Node32, value unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Assign(node, value)
+}
+
+// Strip drops value of a node that belongs to this trie, see Trie32.Strip.
+func (t *SyncTrie32) Strip(node *Node32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Strip(node)
+}
+
+// View calls f under read lock. f must not change the trie or its nodes.
+func (t *SyncTrie32) View(f func(*Trie32)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f(&t.trie)
+}
+
+// Update calls f under write lock, e.g. to apply a batch of changes at once.
+func (t *SyncTrie32) Update(f func(*Trie32)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.trie)
+}
+
+// SyncTrie64 guards Trie64 with a RWMutex so lookups proceed concurrently
+// while changes are serialized.
+//
+// Methods taking read lock: Get, Data, Clone, Validate, View.
+// Methods taking write lock: Set, Append, Remove, GetNode, Assign, Strip,
+// Compact, Update.
+//
+// GetNode is a writer because it adds a node when prefix is missing. Nodes
+// returned by Set, Append and GetNode are changed with Assign and Strip of
+// SyncTrie64, deprecated Node64.Assign and Strip skip both the lock and
+// counters of the trie.
+type SyncTrie64 struct {
+	mu   sync.RWMutex
+	trie Trie64
+}
+
+func (t *SyncTrie64) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Get(ip, mask)
+}
+
+func (t *SyncTrie64) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return t.trie.Validate(ip, mask, mode) // does not look at the tree
+}
+
+func (t *SyncTrie64) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Set(ip, mask, value)
+}
+
+func (t *SyncTrie64) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Append(ip, mask, value)
+}
+
+func (t *SyncTrie64) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Remove(ip, mask)
+}
+
+// GetNode takes write lock, see Trie64.GetNode.
+func (t *SyncTrie64) GetNode(ip []byte, mask byte) (bool, *Node64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.GetNode(ip, mask)
+}
+
+// Compact takes write lock, see Trie64.Compact.
+func (t *SyncTrie64) Compact() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Compact()
+}
+
+// Clone takes read lock and returns deep copy, see Trie64.Clone.
+func (t *SyncTrie64) Clone(value func(unsafe.Pointer) unsafe.Pointer) *SyncTrie64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := new(SyncTrie64)
+	t.trie.cloneTo(&c.trie, value)
+	return c
+}
+
+// Data reads value of a node that belongs to this trie.
+func (t *SyncTrie64) Data(node *Node64) unsafe.Pointer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return node.Data()
+}
+
+// Assign sets value of a node that belongs to this trie.
+func (t *SyncTrie64) Assign(node *Node64, value unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Assign(node, value)
+}
+
+// Strip drops value of a node that belongs to this trie, see Trie64.Strip.
+func (t *SyncTrie64) Strip(node *Node64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Strip(node)
+}
+
+// View calls f under read lock. f must not change the trie or its nodes.
+func (t *SyncTrie64) View(f func(*Trie64)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f(&t.trie)
+}
+
+// Update calls f under write lock, e.g. to apply a batch of changes at once.
+func (t *SyncTrie64) Update(f func(*Trie64)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.trie)
+}
+
+// SyncTrie128 guards Trie128 with a RWMutex so lookups proceed concurrently
+// while changes are serialized.
+//
+// Methods taking read lock: Get, Data, Clone, Validate, View.
+// Methods taking write lock: Set, Append, Remove, GetNode, Assign, Strip,
+// Compact, Update.
+//
+// GetNode is a writer because it adds a node when prefix is missing. Nodes
+// returned by Set, Append and GetNode are changed with Assign and Strip of
+// SyncTrie128, deprecated Node128.Assign and Strip skip both the lock and
+// counters of the trie.
+type SyncTrie128 struct {
+	mu   sync.RWMutex
+	trie Trie128
+}
+
+func (t *SyncTrie128) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Get(ip, mask)
+}
+
+func (t *SyncTrie128) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return t.trie.Validate(ip, mask, mode) // does not look at the tree
+}
+
+func (t *SyncTrie128) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Set(ip, mask, value)
+}
+
+func (t *SyncTrie128) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Append(ip, mask, value)
+}
+
+func (t *SyncTrie128) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Remove(ip, mask)
+}
+
+// GetNode takes write lock, see Trie128.GetNode.
+func (t *SyncTrie128) GetNode(ip []byte, mask byte) (bool, *Node128, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.GetNode(ip, mask)
+}
+
+// Compact takes write lock, see Trie128.Compact.
+func (t *SyncTrie128) Compact() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Compact()
+}
+
+// Clone takes read lock and returns deep copy, see Trie128.Clone.
+func (t *SyncTrie128) Clone(value func(unsafe.Pointer) unsafe.Pointer) *SyncTrie128 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := new(SyncTrie128)
+	t.trie.cloneTo(&c.trie, value)
+	return c
+}
+
+// Data reads value of a node that belongs to this trie.
+func (t *SyncTrie128) Data(node *Node128) unsafe.Pointer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return node.Data()
+}
+
+// Assign sets value of a node that belongs to this trie.
+func (t *SyncTrie128) Assign(node *Node128, value unsafe.Pointer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Assign(node, value)
+}
+
+// Strip drops value of a node that belongs to this trie, see Trie128.Strip.
+func (t *SyncTrie128) Strip(node *Node128) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.Strip(node)
+}
+
+// View calls f under read lock. f must not change the trie or its nodes.
+func (t *SyncTrie128) View(f func(*Trie128)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	f(&t.trie)
+}
+
+// Update calls f under write lock, e.g. to apply a batch of changes at once.
+func (t *SyncTrie128) Update(f func(*Trie128)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.trie)
+}
@@ -0,0 +1,70 @@
+package iptrie
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestSyncTrie(t *testing.T) {
+	var T = new(SyncTrie32)
+	var values [256]int
+	for i := range values {
+		values[i] = i
+	}
+	T.Set([]byte{10, 0, 0, 0}, 8, unsafe.Pointer(&values[0]))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(values); i += 4 {
+				key := []byte{10, byte(i), 0, 0}
+				if i%2 == 0 {
+					if _, _, err := T.Set(key, 16, unsafe.Pointer(&values[i])); err != nil {
+						t.Error("Unable to set node", err)
+						return
+					}
+					continue
+				}
+				// GetNode followed by Assign must happen under one lock,
+				// otherwise readers could observe the node before its value
+				var err error
+				T.Update(func(trie *Trie32) {
+					var node *Node32
+					if _, node, err = trie.GetNode(key, 16); err == nil {
+						trie.Assign(node, unsafe.Pointer(&values[i]))
+					}
+				})
+				if err != nil {
+					t.Error("Unable to add node", err)
+					return
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := range values {
+				_, _, ln, value := T.Get([]byte{10, byte(i), 1, 1}, 32)
+				if value == nil || (ln == 16 && *(*int)(value) != i) {
+					t.Error("Unexpected value for", i, ln)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	T.View(func(trie *Trie32) {
+		trie.Root().Drill(func(n *Node32) {
+			if !n.IsDummy() {
+				count++
+			}
+		})
+	})
+	if count != len(values)+1 {
+		t.Errorf("Expected %d entries, got %d", len(values)+1, count)
+	}
+}
@@ -33,8 +33,13 @@ func keyStr(b []byte, ln byte) string {
 //go:generate go run ./tree_generate.go -o tree_auto.go
 
 type Trie160 struct {
-	node  *Node160
-	nodes []Node160
+	node    *Node160
+	nodes   []Node160
+	free    *Node160 // removed nodes linked thru a, reused first
+	freed   int
+	entries int // real nodes
+	dummies int
+	codec   ValueCodec // for snapshots, see SetCodec
 }
 
 type Node160 struct {
@@ -90,6 +95,11 @@ func (t *Trie160) Root() *Node160 {
 	return t.node
 }
 
+// Width returns number of bits in keys of the trie.
+func (t *Trie160) Width() byte {
+	return MAXBITS
+}
+
 func (node *Node160) Bits() byte {
 	return node.prefixlen
 }
@@ -169,22 +179,57 @@ func (node *Node160) bitsMatched(key []uint32, ln byte) byte {
 }
 
 func (t *Trie160) newnode(bits []byte, prefixlen, dummy byte) *Node160 {
-	if len(t.nodes) == 0 {
-		t.nodes = make([]Node160, 20) // 20 nodes at once to prepare
+	var node *Node160
+	if t.free != nil {
+		node, t.free = t.free, t.free.a
+		t.freed--
+		*node = Node160{}
+	} else {
+		if len(t.nodes) == 0 {
+			t.nodes = make([]Node160, 20) // 20 nodes at once to prepare
+		}
+
+		idx := len(t.nodes) - 1
+		node = &(t.nodes[idx])
+		t.nodes = t.nodes[:idx]
 	}
 
-	idx := len(t.nodes) - 1
-	node := &(t.nodes[idx])
-	t.nodes = t.nodes[:idx]
+	node.dummy = dummy
+	node.setKey(bits, prefixlen)
+	t.count(node, 1)
+	return node
+}
+
+// count adds n to entries or dummies depending on node kind.
+func (t *Trie160) count(node *Node160, n int) {
+	if node.dummy != 0 {
+		t.dummies += n
+	} else {
+		t.entries += n
+	}
+}
 
-	node.prefixlen, node.dummy = prefixlen, dummy
+// setKey fills node bits from key masked to prefixlen.
+func (node *Node160) setKey(bits []byte, prefixlen byte) {
+	node.prefixlen = prefixlen
 
 	end := (prefixlen + 31) / 32
 	for pos := byte(0); pos < end; pos++ {
 		node.bits[pos] = mkuint32(bits[pos*4:], prefixlen)
 		prefixlen -= 32
 	}
-	return node
+}
+
+// trimBits clears bits past prefixlen.
+func (node *Node160) trimBits() {
+	for pos := range node.bits {
+		switch plen := int(node.prefixlen) - pos*32; {
+		case plen <= 0:
+			node.bits[pos] = 0
+		case plen < 32:
+			node.bits[pos] &= ^(uint32(0xffffffff) >> plen)
+		}
+	}
 }
 
 func (node *Node160) findBestMatch(key []byte, ln byte) (bool, *Node160, *Node160) {
@@ -218,46 +263,117 @@ func (node *Node160) findBestMatch(key []byte, ln byte) (bool, *Node160, *Node16
 	return exact, parent, cparent
 }
 
-func (node *Node160) delChildNode(key []byte, ln byte) bool {
-	var parent *Node160
-	for node != nil && node.match(key, ln) {
-		parent = node
-		if hasBit8(key, parent.prefixlen+1) {
-			node = node.a
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.a = nil
-				} else if node.b == nil {
-					// right branch has right side child, trim
-					parent.a = node.a
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+// delNode removes key/ln and returns its value. Dummies left with less
+// than two children on the way to removed node are dropped.
+func (t *Trie160) delNode(key []byte, ln byte) (bool, unsafe.Pointer) {
+	// path keeps links (&t.node, &parent.a, ...) that lead to removed node
+	var path [MAXBITS + 2]**Node160
+	depth := 0
+	link := &t.node
+	for node := *link; node != nil && node.prefixlen < ln && node.match(key, ln); node = *link {
+		path[depth] = link
+		depth++
+		if hasBit8(key, node.prefixlen+1) {
+			link = &node.a
 		} else {
-			node = node.b
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.b = nil
-				} else if node.a == nil {
-					// left branch has left side child, trim
-					parent.b = node.b
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+			link = &node.b
+		}
+	}
+	node := *link
+	if node == nil || node.prefixlen != ln || node.dummy != 0 || !node.match(key, ln) {
+		return false, nil
+	}
+
+	value := node.data
+	if node.a != nil && node.b != nil {
+		// still needed to hold both branches
+		t.count(node, -1)
+		node.data, node.dummy = nil, 1
+		t.count(node, 1)
+		return true, value
+	}
+	t.unlink(link)
+
+	// parents might be dummies that are not needed anymore
+	for depth > 0 {
+		depth--
+		link = path[depth]
+		if parent := *link; parent.dummy == 0 || (parent.a != nil && parent.b != nil) {
+			break
 		}
+		t.unlink(link)
 	}
-	return false
+	return true, value
 }
 
-func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node160) {
-	if ln > MAXBITS {
-		panic("Unable to add prefix longer than MAXBITS")
+// unlink replaces node having at most one child with that child.
+func (t *Trie160) unlink(link **Node160) {
+	node := *link
+	if node.a != nil {
+		*link = node.a
+	} else {
+		*link = node.b
+	}
+	t.release(node)
+}
+
+// release puts node that is no longer in the tree to free list.
+func (t *Trie160) release(node *Node160) {
+	t.count(node, -1)
+	node.a, node.b, node.data = t.free, nil, nil
+	t.free = node
+	t.freed++
+}
+
+// Compact moves all nodes into one freshly allocated array and drops free
+// list and preallocated nodes, so memory held by removed nodes could be
+// returned. Nodes obtained before Compact are no longer part of the trie.
+func (t *Trie160) Compact() {
+	var n int
+	if t.node != nil {
+		t.node.Drill(func(*Node160) { n++ })
+	}
+	arena := make([]Node160, n)
+	t.node, _ = t.node.copyInto(arena, nil)
+	t.nodes, t.free, t.freed = nil, nil, 0
+}
+
+// Clone returns deep copy of the trie packed into new array of nodes.
+// Dummy and real nodes stay as they are. Values are shared when value is
+// nil, otherwise value is called for every real node to get its copy.
+func (t *Trie160) Clone(value func(unsafe.Pointer) unsafe.Pointer) *Trie160 {
+	c := new(Trie160)
+	t.cloneTo(c, value)
+	return c
+}
+
+// cloneTo makes empty c a copy of t.
+func (t *Trie160) cloneTo(c *Trie160, value func(unsafe.Pointer) unsafe.Pointer) {
+	c.entries, c.dummies, c.codec = t.entries, t.dummies, t.codec
+	arena := make([]Node160, t.entries+t.dummies)
+	c.node, _ = t.node.copyInto(arena, value)
+}
+
+// copyInto copies subtree into arena, it returns copy of node and part of
+// arena left unused. Values are passed thru value function unless it is nil.
+func (node *Node160) copyInto(arena []Node160, value func(unsafe.Pointer) unsafe.Pointer) (*Node160, []Node160) {
+	if node == nil {
+		return nil, arena
+	}
+	c := &arena[0]
+	*c = *node
+	if value != nil && c.dummy == 0 {
+		c.data = value(c.data)
+	}
+	arena = arena[1:]
+	c.a, arena = node.a.copyInto(arena, value)
+	c.b, arena = node.b.copyInto(arena, value)
+	return c, arena
+}
+
+func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node160, err error) {
+	if err = checkKey(key, ln, MAXBITS); err != nil {
+		return
 	}
 
 	set = true
@@ -277,7 +393,7 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 	)
 	if exact, node, _ = node.findBestMatch(key, ln); exact {
 		if node.dummy != 0 {
-			node.Assign(value)
+			t.Assign(node, value)
 			if DEBUG != nil {
 				fmt.Fprintf(DEBUG, "setting empty child's %v/%d value\n", key, ln)
 			}
@@ -291,28 +407,30 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 				fmt.Fprintf(DEBUG, "hit previously set %v/%d node\n", key, ln)
 			}
 		}
-		return set, node
+		return set, node, nil
 	}
-	newnode = t.newnode(key, ln, 0)
-	newnode.data = value
 	if node != nil {
 		if hasBit8(key, node.prefixlen+1) {
 			if node.a == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.a = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "a-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.a
 			down = node.a
 		} else {
 			if node.b == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.b = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "b-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.b
 			down = node.b
@@ -324,10 +442,23 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 
 	parent := node
 	if parent != nil && parent.prefixlen >= ln {
-		panic("parent's prefix could not be larger than key len")
+		return false, nil, fmt.Errorf("%w: parent's prefix could not be larger than key len", ErrCorruptTrie)
 	}
 
-	matched := down.bitsMatched(newnode.bits[:], ln)
+	// check the branch before allocating anything, so nothing leaks on error
+	var probe Node160
+	probe.setKey(key, ln)
+	matched := down.bitsMatched(probe.bits[:], ln)
+	if matched == ln {
+		if parent != nil && hasBit(probe.bits[:], parent.prefixlen+1) != hasBit(down.bits[:], parent.prefixlen+1) {
+			return false, nil, fmt.Errorf("%w: something is wrong with branch that we intend to append to", ErrCorruptTrie)
+		}
+	} else if hasBit(down.bits[:], matched+1) == hasBit(probe.bits[:], matched+1) {
+		return false, nil, fmt.Errorf("%w: tangled branches while creating new intermediate parent", ErrCorruptTrie)
+	}
+
+	newnode = t.newnode(key, ln, 0)
+	newnode.data = value
 
 	// Well. We fit somewhere between parent and down
 	// parent.bits match up to parent.prefixlen          1111111111100000000000
@@ -342,11 +473,7 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 			newnode.b = down
 		}
 		if parent != nil {
-			use_a := hasBit(newnode.bits[:], parent.prefixlen+1)
-			if use_a != hasBit(down.bits[:], parent.prefixlen+1) {
-				panic("something is wrong with branch that we intend to append to")
-			}
-			if use_a {
+			if hasBit(newnode.bits[:], parent.prefixlen+1) {
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "insert a-child %s to %s before %s\n", keyStr(key, ln), keyStr(parent.IP(), parent.prefixlen), keyStr(down.IP(), down.prefixlen))
 				}
@@ -371,9 +498,6 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 		// down and newnode should have new dummy parent under parent
 		node = t.newnode(key[:(ln+7)/8], matched, 1)
 		use_a := hasBit(down.bits[:], matched+1)
-		if use_a == hasBit(newnode.bits[:], matched+1) {
-			panic("tangled branches while creating new intermediate parent")
-		}
 		if use_a {
 			node.a = down
 			node.b = newnode
@@ -417,7 +541,16 @@ func (t *Trie160) addToNode(node *Node160, key []byte, ln byte, value unsafe.Poi
 }
 
 func (rt *Trie160) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
-	exact, node, ct := rt.node.findBestMatch(ip, mask)
+	if checkKey(ip, mask, MAXBITS) != nil {
+		// nothing could be stored under invalid key
+		return false, nil, 0, nil
+	}
+	return rt.node.lookup(ip, mask)
+}
+
+// lookup is Get for a subtree starting at node.
+func (node *Node160) lookup(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	exact, node, ct := node.findBestMatch(ip, mask)
 
 	if node != nil && node.dummy == 0 {
 		// dummy=1 means "no match", we will instead look at valid container
@@ -432,38 +565,88 @@ func (rt *Trie160) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer
 
 }
 
-func (rt *Trie160) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, false)
-	return set, olval
+// Append adds value unless prefix is already set. It returns ErrPrefixTooLong,
+// ErrKeyTooShort or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie160) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160, error) {
+	return rt.addToNode(rt.node, ip, mask, value, false)
+}
+
+// MustAppend is like Append but panics on error.
+func (rt *Trie160) MustAppend(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160) {
+	set, node, err := rt.Append(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
 }
 
-func (rt *Trie160) Remove(ip []byte, mask byte) bool {
-	return rt.node.delChildNode(ip, mask)
+// Remove deletes prefix and returns value it had. Root could be removed
+// too and dummy nodes left without purpose are dropped along the way.
+// Dropped nodes are reused by later inserts, so pointers to them must not
+// be kept.
+func (rt *Trie160) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, MAXBITS) != nil {
+		return false, nil
+	}
+	return rt.delNode(ip, mask)
 }
 
-func (rt *Trie160) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, true)
-	return set, olval
+// Set adds or replaces value. It returns ErrPrefixTooLong, ErrKeyTooShort
+// or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie160) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160, error) {
+	return rt.addToNode(rt.node, ip, mask, value, true)
 }
 
-func (rt *Trie160) GetNode(ip []byte, mask byte) (bool, *Node160) {
+// MustSet is like Set but panics on error.
+func (rt *Trie160) MustSet(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node160) {
+	set, node, err := rt.Set(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
+}
+
+// GetNode finds node for prefix adding it (without value) when missing.
+// Note that it changes the tree and needs a write lock if trie is shared.
+func (rt *Trie160) GetNode(ip []byte, mask byte) (bool, *Node160, error) {
+	if err := checkKey(ip, mask, MAXBITS); err != nil {
+		return false, nil, err
+	}
 	exact, node, ct := rt.node.findBestMatch(ip, mask)
 	if exact {
-		return node.IsDummy(), node // if node is a dummy it needs to look like "just added"
+		return node.IsDummy(), node, nil // if node is a dummy it needs to look like "just added"
 	}
+	var err error
 	if node != nil {
-		_, node = rt.addToNode(node, ip, mask, nil, false)
+		_, node, err = rt.addToNode(node, ip, mask, nil, false)
 	} else {
 		if ct != nil {
-			_, node = rt.addToNode(ct, ip, mask, nil, false)
+			_, node, err = rt.addToNode(ct, ip, mask, nil, false)
 		} else {
-			_, node = rt.addToNode(rt.node, ip, mask, nil, false)
+			_, node, err = rt.addToNode(rt.node, ip, mask, nil, false)
 		}
 	}
-	return true, node
+	if err != nil {
+		return false, nil, err
+	}
+	return true, node, nil
 
 }
 
+// MustGetNode is like GetNode but panics on error.
+func (rt *Trie160) MustGetNode(ip []byte, mask byte) (bool, *Node160) {
+	added, node, err := rt.GetNode(ip, mask)
+	if err != nil {
+		panic(err)
+	}
+	return added, node
+}
+
+// Validate checks ip/mask against trie width, see ValidatePrefix.
+func (rt *Trie160) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return ValidatePrefix(ip, mask, MAXBITS, mode)
+}
+
 func (n *Node160) Data() unsafe.Pointer {
 	return n.data
 }
@@ -472,12 +655,36 @@ func (n *Node160) IsDummy() bool {
 	return n.dummy != 0
 }
 
+// Assign changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: node does not know its trie, so Len, Stats and snapshots of
+// the trie go out of step. Use Trie160.Assign.
 func (n *Node160) Assign(value unsafe.Pointer) {
 	n.data = value
 	n.dummy = 0
 }
 
+// Strip changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: like Assign it leaves counters of the trie alone, and also
+// dummies it makes are never removed. Use Trie160.Strip.
 func (n *Node160) Strip() {
 	n.data = nil
 	n.dummy = 1
 }
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *Trie160) Assign(node *Node160, value unsafe.Pointer) {
+	t.count(node, -1)
+	node.data, node.dummy = value, 0
+	t.count(node, 1)
+}
+
+// Strip drops value of a node that belongs to t. Node holding two branches
+// becomes a dummy, otherwise it is removed along with dummies that are no
+// longer needed, just like Remove does, and must not be used after that.
+func (t *Trie160) Strip(node *Node160) {
+	if node.dummy == 0 {
+		t.delNode(node.IP(), node.prefixlen)
+	}
+}
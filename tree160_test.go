@@ -62,7 +62,7 @@ func TestTreeAppend(t *testing.T) {
 			got := strings.Replace(buf.String(), "\n", "\\n", -1)
 			if got != s.result {
 				t.Error(got, "!=", s.result)
-				fmt.Fprintln(os.Stderr, buf.String(), "\n")
+				fmt.Fprintln(os.Stderr, buf.String())
 			}
 			buf.Reset()
 		}
@@ -76,9 +76,9 @@ func TestTreeAppend(t *testing.T) {
 				t.Errorf("Incorrect match found for exact search, got %v key while looking for %v", match, s)
 			}
 			if match.data == nil {
-				t.Errorf("Incorrect pointer found for exact search of %v/%d, got %v key while looking for %v", s.key, s.ln, match.data, unsafe.Pointer(uintptr(i+100)))
+				t.Errorf("Incorrect pointer found for exact search of %v/%d, got %v key while looking for %v", s.key, s.ln, match.data, ptrs[i])
 			} else if *(*uint64)(match.data) != ptrs[i] {
-				t.Errorf("Incorrect value found for exact search of %v/%d, got %v key while looking for %v", s.key, s.ln, match.data, unsafe.Pointer(uintptr(i+100)))
+				t.Errorf("Incorrect value found for exact search of %v/%d, got %v key while looking for %v", s.key, s.ln, match.data, ptrs[i])
 			}
 			exact, match, _ = T.node.findBestMatch(s.key, s.ln+1)
 			if exact || match.prefixlen != s.ln {
@@ -137,13 +137,13 @@ func TestTreeDelete(t *testing.T) {
 		}
 	}
 	// del all /16
-	if T.Root().delChildNode([]byte{1, 1, 0, 0}, 16) {
+	if ok, _ := T.delNode([]byte{1, 1, 0, 0}, 16); ok {
 		t.Error("Not existing node should not be deleted (1.1.0.0/16)")
 	}
-	if !T.Root().delChildNode([]byte{1, 2, 0, 0}, 16) {
+	if ok, _ := T.delNode([]byte{1, 2, 0, 0}, 16); !ok {
 		t.Error("Existing node could not be deleted (1.2.0.0/16)")
 	}
-	if !T.Root().delChildNode([]byte{1, 3, 0, 0}, 16) {
+	if ok, _ := T.delNode([]byte{1, 3, 0, 0}, 16); !ok {
 		t.Error("Existing node could not be deleted (1.3.0.0/16)")
 	}
 	T.Root().Drill(func(n *Node160) {
@@ -152,3 +152,35 @@ func TestTreeDelete(t *testing.T) {
 		}
 	})
 }
+
+func TestTreeDeleteCollapse(t *testing.T) {
+	var values [10]int
+	T := new(Trie160)
+	for i, s := range testCases[0] {
+		T.addToNode(T.node, s.key, s.ln, unsafe.Pointer(&values[i]), s.repl)
+	}
+	for i, s := range testCases[0] {
+		ok, value := T.Remove(s.key, s.ln)
+		if !ok || value != unsafe.Pointer(&values[i]) {
+			t.Errorf("Unable to remove %v/%d or got wrong value back", s.key, s.ln)
+		}
+		if ok, _ = T.Remove(s.key, s.ln); ok {
+			t.Errorf("%v/%d removed twice", s.key, s.ln)
+		}
+		// every dummy left must be holding two branches
+		if T.Root() == nil {
+			continue
+		}
+		T.Root().Drill(func(n *Node160) {
+			if n.IsDummy() && (n.a == nil || n.b == nil) {
+				t.Errorf("Dummy %s left with one branch after removing %v/%d", keyStr(n.IP(), n.Bits()), s.key, s.ln)
+			}
+		})
+		if T.Root().IsDummy() && i == len(testCases[0])-1 {
+			t.Error("Only dummy left in trie")
+		}
+	}
+	if T.Root() != nil || T.Len() != 0 || T.Stats().Dummies != 0 {
+		t.Errorf("Trie should be empty, got root %v and %+v", T.Root(), T.Stats())
+	}
+}
@@ -8,8 +8,13 @@ import (
 )
 
 type Trie32 struct {
-	node  *Node32
-	nodes []Node32
+	node    *Node32
+	nodes   []Node32
+	free    *Node32 // removed nodes linked thru a, reused first
+	freed   int
+	entries int // real nodes
+	dummies int
+	codec   ValueCodec // for snapshots, see SetCodec
 }
 
 type Node32 struct {
@@ -65,6 +70,11 @@ func (t *Trie32) Root() *Node32 {
 	return t.node
 }
 
+// Width returns number of bits in keys of the trie.
+func (t *Trie32) Width() byte {
+	return 32
+}
+
 func (node *Node32) Bits() byte {
 	return node.prefixlen
 }
@@ -144,22 +154,57 @@ func (node *Node32) bitsMatched(key []uint32, ln byte) byte {
 }
 
 func (t *Trie32) newnode(bits []byte, prefixlen, dummy byte) *Node32 {
-	if len(t.nodes) == 0 {
-		t.nodes = make([]Node32, 20) // 20 nodes at once to prepare
+	var node *Node32
+	if t.free != nil {
+		node, t.free = t.free, t.free.a
+		t.freed--
+		*node = Node32{}
+	} else {
+		if len(t.nodes) == 0 {
+			t.nodes = make([]Node32, 20) // 20 nodes at once to prepare
+		}
+
+		idx := len(t.nodes) - 1
+		node = &(t.nodes[idx])
+		t.nodes = t.nodes[:idx]
 	}
 
-	idx := len(t.nodes) - 1
-	node := &(t.nodes[idx])
-	t.nodes = t.nodes[:idx]
+	node.dummy = dummy
+	node.setKey(bits, prefixlen)
+	t.count(node, 1)
+	return node
+}
+
+// count adds n to entries or dummies depending on node kind.
+func (t *Trie32) count(node *Node32, n int) {
+	if node.dummy != 0 {
+		t.dummies += n
+	} else {
+		t.entries += n
+	}
+}
 
-	node.prefixlen, node.dummy = prefixlen, dummy
+// setKey fills node bits from key masked to prefixlen.
+func (node *Node32) setKey(bits []byte, prefixlen byte) {
+	node.prefixlen = prefixlen
 
 	end := (prefixlen + 31) / 32
 	for pos := byte(0); pos < end; pos++ {
 		node.bits[pos] = mkuint32(bits[pos*4:], prefixlen)
 		prefixlen -= 32
 	}
-	return node
+}
+
+// trimBits clears bits past prefixlen.
+func (node *Node32) trimBits() {
+	for pos := range node.bits {
+		switch plen := int(node.prefixlen) - pos*32; {
+		case plen <= 0:
+			node.bits[pos] = 0
+		case plen < 32:
+			node.bits[pos] &= ^(uint32(0xffffffff) >> plen)
+		}
+	}
 }
 
 func (node *Node32) findBestMatch(key []byte, ln byte) (bool, *Node32, *Node32) {
@@ -193,46 +238,117 @@ func (node *Node32) findBestMatch(key []byte, ln byte) (bool, *Node32, *Node32)
 	return exact, parent, cparent
 }
 
-func (node *Node32) delChildNode(key []byte, ln byte) bool {
-	var parent *Node32
-	for node != nil && node.match(key, ln) {
-		parent = node
-		if hasBit8(key, parent.prefixlen+1) {
-			node = node.a
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.a = nil
-				} else if node.b == nil {
-					// right branch has right side child, trim
-					parent.a = node.a
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+// delNode removes key/ln and returns its value. Dummies left with less
+// than two children on the way to removed node are dropped.
+func (t *Trie32) delNode(key []byte, ln byte) (bool, unsafe.Pointer) {
+	// path keeps links (&t.node, &parent.a, ...) that lead to removed node
+	var path [32 + 2]**Node32
+	depth := 0
+	link := &t.node
+	for node := *link; node != nil && node.prefixlen < ln && node.match(key, ln); node = *link {
+		path[depth] = link
+		depth++
+		if hasBit8(key, node.prefixlen+1) {
+			link = &node.a
 		} else {
-			node = node.b
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.b = nil
-				} else if node.a == nil {
-					// left branch has left side child, trim
-					parent.b = node.b
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+			link = &node.b
+		}
+	}
+	node := *link
+	if node == nil || node.prefixlen != ln || node.dummy != 0 || !node.match(key, ln) {
+		return false, nil
+	}
+
+	value := node.data
+	if node.a != nil && node.b != nil {
+		// still needed to hold both branches
+		t.count(node, -1)
+		node.data, node.dummy = nil, 1
+		t.count(node, 1)
+		return true, value
+	}
+	t.unlink(link)
+
+	// parents might be dummies that are not needed anymore
+	for depth > 0 {
+		depth--
+		link = path[depth]
+		if parent := *link; parent.dummy == 0 || (parent.a != nil && parent.b != nil) {
+			break
 		}
+		t.unlink(link)
+	}
+	return true, value
+}
+
+// unlink replaces node having at most one child with that child.
+func (t *Trie32) unlink(link **Node32) {
+	node := *link
+	if node.a != nil {
+		*link = node.a
+	} else {
+		*link = node.b
+	}
+	t.release(node)
+}
+
+// release puts node that is no longer in the tree to free list.
+func (t *Trie32) release(node *Node32) {
+	t.count(node, -1)
+	node.a, node.b, node.data = t.free, nil, nil
+	t.free = node
+	t.freed++
+}
+
+// Compact moves all nodes into one freshly allocated array and drops free
+// list and preallocated nodes, so memory held by removed nodes could be
+// returned. Nodes obtained before Compact are no longer part of the trie.
+func (t *Trie32) Compact() {
+	var n int
+	if t.node != nil {
+		t.node.Drill(func(*Node32) { n++ })
+	}
+	arena := make([]Node32, n)
+	t.node, _ = t.node.copyInto(arena, nil)
+	t.nodes, t.free, t.freed = nil, nil, 0
+}
+
+// Clone returns deep copy of the trie packed into new array of nodes.
+// Dummy and real nodes stay as they are. Values are shared when value is
+// nil, otherwise value is called for every real node to get its copy.
+func (t *Trie32) Clone(value func(unsafe.Pointer) unsafe.Pointer) *Trie32 {
+	c := new(Trie32)
+	t.cloneTo(c, value)
+	return c
+}
+
+// cloneTo makes empty c a copy of t.
+func (t *Trie32) cloneTo(c *Trie32, value func(unsafe.Pointer) unsafe.Pointer) {
+	c.entries, c.dummies, c.codec = t.entries, t.dummies, t.codec
+	arena := make([]Node32, t.entries+t.dummies)
+	c.node, _ = t.node.copyInto(arena, value)
+}
+
+// copyInto copies subtree into arena, it returns copy of node and part of
+// arena left unused. Values are passed thru value function unless it is nil.
+func (node *Node32) copyInto(arena []Node32, value func(unsafe.Pointer) unsafe.Pointer) (*Node32, []Node32) {
+	if node == nil {
+		return nil, arena
 	}
-	return false
+	c := &arena[0]
+	*c = *node
+	if value != nil && c.dummy == 0 {
+		c.data = value(c.data)
+	}
+	arena = arena[1:]
+	c.a, arena = node.a.copyInto(arena, value)
+	c.b, arena = node.b.copyInto(arena, value)
+	return c, arena
 }
 
-func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node32) {
-	if ln > 32 {
-		panic("Unable to add prefix longer than 32")
+func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node32, err error) {
+	if err = checkKey(key, ln, 32); err != nil {
+		return
 	}
 
 	set = true
@@ -252,7 +368,7 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 	)
 	if exact, node, _ = node.findBestMatch(key, ln); exact {
 		if node.dummy != 0 {
-			node.Assign(value)
+			t.Assign(node, value)
 			if DEBUG != nil {
 				fmt.Fprintf(DEBUG, "setting empty child's %v/%d value\n", key, ln)
 			}
@@ -266,28 +382,30 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 				fmt.Fprintf(DEBUG, "hit previously set %v/%d node\n", key, ln)
 			}
 		}
-		return set, node
+		return set, node, nil
 	}
-	newnode = t.newnode(key, ln, 0)
-	newnode.data = value
 	if node != nil {
 		if hasBit8(key, node.prefixlen+1) {
 			if node.a == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.a = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "a-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.a
 			down = node.a
 		} else {
 			if node.b == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.b = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "b-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.b
 			down = node.b
@@ -299,10 +417,23 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 
 	parent := node
 	if parent != nil && parent.prefixlen >= ln {
-		panic("parent's prefix could not be larger than key len")
+		return false, nil, fmt.Errorf("%w: parent's prefix could not be larger than key len", ErrCorruptTrie)
+	}
+
+	// check the branch before allocating anything, so nothing leaks on error
+	var probe Node32
+	probe.setKey(key, ln)
+	matched := down.bitsMatched(probe.bits[:], ln)
+	if matched == ln {
+		if parent != nil && hasBit(probe.bits[:], parent.prefixlen+1) != hasBit(down.bits[:], parent.prefixlen+1) {
+			return false, nil, fmt.Errorf("%w: something is wrong with branch that we intend to append to", ErrCorruptTrie)
+		}
+	} else if hasBit(down.bits[:], matched+1) == hasBit(probe.bits[:], matched+1) {
+		return false, nil, fmt.Errorf("%w: tangled branches while creating new intermediate parent", ErrCorruptTrie)
 	}
 
-	matched := down.bitsMatched(newnode.bits[:], ln)
+	newnode = t.newnode(key, ln, 0)
+	newnode.data = value
 
 	// Well. We fit somewhere between parent and down
 	// parent.bits match up to parent.prefixlen          1111111111100000000000
@@ -317,11 +448,7 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 			newnode.b = down
 		}
 		if parent != nil {
-			use_a := hasBit(newnode.bits[:], parent.prefixlen+1)
-			if use_a != hasBit(down.bits[:], parent.prefixlen+1) {
-				panic("something is wrong with branch that we intend to append to")
-			}
-			if use_a {
+			if hasBit(newnode.bits[:], parent.prefixlen+1) {
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "insert a-child %s to %s before %s\n", keyStr(key, ln), keyStr(parent.IP(), parent.prefixlen), keyStr(down.IP(), down.prefixlen))
 				}
@@ -346,9 +473,6 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 		// down and newnode should have new dummy parent under parent
 		node = t.newnode(key[:(ln+7)/8], matched, 1)
 		use_a := hasBit(down.bits[:], matched+1)
-		if use_a == hasBit(newnode.bits[:], matched+1) {
-			panic("tangled branches while creating new intermediate parent")
-		}
 		if use_a {
 			node.a = down
 			node.b = newnode
@@ -392,7 +516,16 @@ func (t *Trie32) addToNode(node *Node32, key []byte, ln byte, value unsafe.Point
 }
 
 func (rt *Trie32) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
-	exact, node, ct := rt.node.findBestMatch(ip, mask)
+	if checkKey(ip, mask, 32) != nil {
+		// nothing could be stored under invalid key
+		return false, nil, 0, nil
+	}
+	return rt.node.lookup(ip, mask)
+}
+
+// lookup is Get for a subtree starting at node.
+func (node *Node32) lookup(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	exact, node, ct := node.findBestMatch(ip, mask)
 
 	if node != nil && node.dummy == 0 {
 		// dummy=1 means "no match", we will instead look at valid container
@@ -407,36 +540,86 @@ func (rt *Trie32) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer)
 
 }
 
-func (rt *Trie32) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, false)
-	return set, olval
+// Append adds value unless prefix is already set. It returns ErrPrefixTooLong,
+// ErrKeyTooShort or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie32) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32, error) {
+	return rt.addToNode(rt.node, ip, mask, value, false)
+}
+
+// MustAppend is like Append but panics on error.
+func (rt *Trie32) MustAppend(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32) {
+	set, node, err := rt.Append(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
+}
+
+// Remove deletes prefix and returns value it had. Root could be removed
+// too and dummy nodes left without purpose are dropped along the way.
+// Dropped nodes are reused by later inserts, so pointers to them must not
+// be kept.
+func (rt *Trie32) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 32) != nil {
+		return false, nil
+	}
+	return rt.delNode(ip, mask)
 }
 
-func (rt *Trie32) Remove(ip []byte, mask byte) bool {
-	return rt.node.delChildNode(ip, mask)
+// Set adds or replaces value. It returns ErrPrefixTooLong, ErrKeyTooShort
+// or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie32) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32, error) {
+	return rt.addToNode(rt.node, ip, mask, value, true)
 }
 
-func (rt *Trie32) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, true)
-	return set, olval
+// MustSet is like Set but panics on error.
+func (rt *Trie32) MustSet(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node32) {
+	set, node, err := rt.Set(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
 }
 
-func (rt *Trie32) GetNode(ip []byte, mask byte) (bool, *Node32) {
+// GetNode finds node for prefix adding it (without value) when missing.
+// Note that it changes the tree and needs a write lock if trie is shared.
+func (rt *Trie32) GetNode(ip []byte, mask byte) (bool, *Node32, error) {
+	if err := checkKey(ip, mask, 32); err != nil {
+		return false, nil, err
+	}
 	exact, node, ct := rt.node.findBestMatch(ip, mask)
 	if exact {
-		return node.IsDummy(), node // if node is a dummy it needs to look like "just added"
+		return node.IsDummy(), node, nil // if node is a dummy it needs to look like "just added"
 	}
+	var err error
 	if node != nil {
-		_, node = rt.addToNode(node, ip, mask, nil, false)
+		_, node, err = rt.addToNode(node, ip, mask, nil, false)
 	} else {
 		if ct != nil {
-			_, node = rt.addToNode(ct, ip, mask, nil, false)
+			_, node, err = rt.addToNode(ct, ip, mask, nil, false)
 		} else {
-			_, node = rt.addToNode(rt.node, ip, mask, nil, false)
+			_, node, err = rt.addToNode(rt.node, ip, mask, nil, false)
 		}
 	}
-	return true, node
+	if err != nil {
+		return false, nil, err
+	}
+	return true, node, nil
+
+}
+
+// MustGetNode is like GetNode but panics on error.
+func (rt *Trie32) MustGetNode(ip []byte, mask byte) (bool, *Node32) {
+	added, node, err := rt.GetNode(ip, mask)
+	if err != nil {
+		panic(err)
+	}
+	return added, node
+}
 
+// Validate checks ip/mask against trie width, see ValidatePrefix.
+func (rt *Trie32) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return ValidatePrefix(ip, mask, 32, mode)
 }
 
 func (n *Node32) Data() unsafe.Pointer {
@@ -447,19 +630,48 @@ func (n *Node32) IsDummy() bool {
 	return n.dummy != 0
 }
 
+// Assign changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: node does not know its trie, so Len, Stats and snapshots of
+// the trie go out of step. Use Trie32.Assign.
 func (n *Node32) Assign(value unsafe.Pointer) {
 	n.data = value
 	n.dummy = 0
 }
 
+// Strip changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: like Assign it leaves counters of the trie alone, and also
+// dummies it makes are never removed. Use Trie32.Strip.
 func (n *Node32) Strip() {
 	n.data = nil
 	n.dummy = 1
 }
 
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *Trie32) Assign(node *Node32, value unsafe.Pointer) {
+	t.count(node, -1)
+	node.data, node.dummy = value, 0
+	t.count(node, 1)
+}
+
+// Strip drops value of a node that belongs to t. Node holding two branches
+// becomes a dummy, otherwise it is removed along with dummies that are no
+// longer needed, just like Remove does, and must not be used after that.
+func (t *Trie32) Strip(node *Node32) {
+	if node.dummy == 0 {
+		t.delNode(node.IP(), node.prefixlen)
+	}
+}
+
 type Trie64 struct {
-	node  *Node64
-	nodes []Node64
+	node    *Node64
+	nodes   []Node64
+	free    *Node64 // removed nodes linked thru a, reused first
+	freed   int
+	entries int // real nodes
+	dummies int
+	codec   ValueCodec // for snapshots, see SetCodec
 }
 
 type Node64 struct {
@@ -515,6 +727,11 @@ func (t *Trie64) Root() *Node64 {
 	return t.node
 }
 
+// Width returns number of bits in keys of the trie.
+func (t *Trie64) Width() byte {
+	return 64
+}
+
 func (node *Node64) Bits() byte {
 	return node.prefixlen
 }
@@ -594,22 +811,57 @@ func (node *Node64) bitsMatched(key []uint32, ln byte) byte {
 }
 
 func (t *Trie64) newnode(bits []byte, prefixlen, dummy byte) *Node64 {
-	if len(t.nodes) == 0 {
-		t.nodes = make([]Node64, 20) // 20 nodes at once to prepare
+	var node *Node64
+	if t.free != nil {
+		node, t.free = t.free, t.free.a
+		t.freed--
+		*node = Node64{}
+	} else {
+		if len(t.nodes) == 0 {
+			t.nodes = make([]Node64, 20) // 20 nodes at once to prepare
+		}
+
+		idx := len(t.nodes) - 1
+		node = &(t.nodes[idx])
+		t.nodes = t.nodes[:idx]
 	}
 
-	idx := len(t.nodes) - 1
-	node := &(t.nodes[idx])
-	t.nodes = t.nodes[:idx]
+	node.dummy = dummy
+	node.setKey(bits, prefixlen)
+	t.count(node, 1)
+	return node
+}
+
+// count adds n to entries or dummies depending on node kind.
+func (t *Trie64) count(node *Node64, n int) {
+	if node.dummy != 0 {
+		t.dummies += n
+	} else {
+		t.entries += n
+	}
+}
 
-	node.prefixlen, node.dummy = prefixlen, dummy
+// setKey fills node bits from key masked to prefixlen.
+func (node *Node64) setKey(bits []byte, prefixlen byte) {
+	node.prefixlen = prefixlen
 
 	end := (prefixlen + 31) / 32
 	for pos := byte(0); pos < end; pos++ {
 		node.bits[pos] = mkuint32(bits[pos*4:], prefixlen)
 		prefixlen -= 32
 	}
-	return node
+}
+
+// trimBits clears bits past prefixlen.
+func (node *Node64) trimBits() {
+	for pos := range node.bits {
+		switch plen := int(node.prefixlen) - pos*32; {
+		case plen <= 0:
+			node.bits[pos] = 0
+		case plen < 32:
+			node.bits[pos] &= ^(uint32(0xffffffff) >> plen)
+		}
+	}
 }
 
 func (node *Node64) findBestMatch(key []byte, ln byte) (bool, *Node64, *Node64) {
@@ -643,46 +895,117 @@ func (node *Node64) findBestMatch(key []byte, ln byte) (bool, *Node64, *Node64)
 	return exact, parent, cparent
 }
 
-func (node *Node64) delChildNode(key []byte, ln byte) bool {
-	var parent *Node64
-	for node != nil && node.match(key, ln) {
-		parent = node
-		if hasBit8(key, parent.prefixlen+1) {
-			node = node.a
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.a = nil
-				} else if node.b == nil {
-					// right branch has right side child, trim
-					parent.a = node.a
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+// delNode removes key/ln and returns its value. Dummies left with less
+// than two children on the way to removed node are dropped.
+func (t *Trie64) delNode(key []byte, ln byte) (bool, unsafe.Pointer) {
+	// path keeps links (&t.node, &parent.a, ...) that lead to removed node
+	var path [64 + 2]**Node64
+	depth := 0
+	link := &t.node
+	for node := *link; node != nil && node.prefixlen < ln && node.match(key, ln); node = *link {
+		path[depth] = link
+		depth++
+		if hasBit8(key, node.prefixlen+1) {
+			link = &node.a
 		} else {
-			node = node.b
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.b = nil
-				} else if node.a == nil {
-					// left branch has left side child, trim
-					parent.b = node.b
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+			link = &node.b
+		}
+	}
+	node := *link
+	if node == nil || node.prefixlen != ln || node.dummy != 0 || !node.match(key, ln) {
+		return false, nil
+	}
+
+	value := node.data
+	if node.a != nil && node.b != nil {
+		// still needed to hold both branches
+		t.count(node, -1)
+		node.data, node.dummy = nil, 1
+		t.count(node, 1)
+		return true, value
+	}
+	t.unlink(link)
+
+	// parents might be dummies that are not needed anymore
+	for depth > 0 {
+		depth--
+		link = path[depth]
+		if parent := *link; parent.dummy == 0 || (parent.a != nil && parent.b != nil) {
+			break
 		}
+		t.unlink(link)
+	}
+	return true, value
+}
+
+// unlink replaces node having at most one child with that child.
+func (t *Trie64) unlink(link **Node64) {
+	node := *link
+	if node.a != nil {
+		*link = node.a
+	} else {
+		*link = node.b
 	}
-	return false
+	t.release(node)
+}
+
+// release puts node that is no longer in the tree to free list.
+func (t *Trie64) release(node *Node64) {
+	t.count(node, -1)
+	node.a, node.b, node.data = t.free, nil, nil
+	t.free = node
+	t.freed++
 }
 
-func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node64) {
-	if ln > 64 {
-		panic("Unable to add prefix longer than 64")
+// Compact moves all nodes into one freshly allocated array and drops free
+// list and preallocated nodes, so memory held by removed nodes could be
+// returned. Nodes obtained before Compact are no longer part of the trie.
+func (t *Trie64) Compact() {
+	var n int
+	if t.node != nil {
+		t.node.Drill(func(*Node64) { n++ })
+	}
+	arena := make([]Node64, n)
+	t.node, _ = t.node.copyInto(arena, nil)
+	t.nodes, t.free, t.freed = nil, nil, 0
+}
+
+// Clone returns deep copy of the trie packed into new array of nodes.
+// Dummy and real nodes stay as they are. Values are shared when value is
+// nil, otherwise value is called for every real node to get its copy.
+func (t *Trie64) Clone(value func(unsafe.Pointer) unsafe.Pointer) *Trie64 {
+	c := new(Trie64)
+	t.cloneTo(c, value)
+	return c
+}
+
+// cloneTo makes empty c a copy of t.
+func (t *Trie64) cloneTo(c *Trie64, value func(unsafe.Pointer) unsafe.Pointer) {
+	c.entries, c.dummies, c.codec = t.entries, t.dummies, t.codec
+	arena := make([]Node64, t.entries+t.dummies)
+	c.node, _ = t.node.copyInto(arena, value)
+}
+
+// copyInto copies subtree into arena, it returns copy of node and part of
+// arena left unused. Values are passed thru value function unless it is nil.
+func (node *Node64) copyInto(arena []Node64, value func(unsafe.Pointer) unsafe.Pointer) (*Node64, []Node64) {
+	if node == nil {
+		return nil, arena
+	}
+	c := &arena[0]
+	*c = *node
+	if value != nil && c.dummy == 0 {
+		c.data = value(c.data)
+	}
+	arena = arena[1:]
+	c.a, arena = node.a.copyInto(arena, value)
+	c.b, arena = node.b.copyInto(arena, value)
+	return c, arena
+}
+
+func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node64, err error) {
+	if err = checkKey(key, ln, 64); err != nil {
+		return
 	}
 
 	set = true
@@ -702,7 +1025,7 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 	)
 	if exact, node, _ = node.findBestMatch(key, ln); exact {
 		if node.dummy != 0 {
-			node.Assign(value)
+			t.Assign(node, value)
 			if DEBUG != nil {
 				fmt.Fprintf(DEBUG, "setting empty child's %v/%d value\n", key, ln)
 			}
@@ -716,28 +1039,30 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 				fmt.Fprintf(DEBUG, "hit previously set %v/%d node\n", key, ln)
 			}
 		}
-		return set, node
+		return set, node, nil
 	}
-	newnode = t.newnode(key, ln, 0)
-	newnode.data = value
 	if node != nil {
 		if hasBit8(key, node.prefixlen+1) {
 			if node.a == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.a = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "a-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.a
 			down = node.a
 		} else {
 			if node.b == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.b = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "b-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.b
 			down = node.b
@@ -749,10 +1074,23 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 
 	parent := node
 	if parent != nil && parent.prefixlen >= ln {
-		panic("parent's prefix could not be larger than key len")
+		return false, nil, fmt.Errorf("%w: parent's prefix could not be larger than key len", ErrCorruptTrie)
+	}
+
+	// check the branch before allocating anything, so nothing leaks on error
+	var probe Node64
+	probe.setKey(key, ln)
+	matched := down.bitsMatched(probe.bits[:], ln)
+	if matched == ln {
+		if parent != nil && hasBit(probe.bits[:], parent.prefixlen+1) != hasBit(down.bits[:], parent.prefixlen+1) {
+			return false, nil, fmt.Errorf("%w: something is wrong with branch that we intend to append to", ErrCorruptTrie)
+		}
+	} else if hasBit(down.bits[:], matched+1) == hasBit(probe.bits[:], matched+1) {
+		return false, nil, fmt.Errorf("%w: tangled branches while creating new intermediate parent", ErrCorruptTrie)
 	}
 
-	matched := down.bitsMatched(newnode.bits[:], ln)
+	newnode = t.newnode(key, ln, 0)
+	newnode.data = value
 
 	// Well. We fit somewhere between parent and down
 	// parent.bits match up to parent.prefixlen          1111111111100000000000
@@ -767,11 +1105,7 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 			newnode.b = down
 		}
 		if parent != nil {
-			use_a := hasBit(newnode.bits[:], parent.prefixlen+1)
-			if use_a != hasBit(down.bits[:], parent.prefixlen+1) {
-				panic("something is wrong with branch that we intend to append to")
-			}
-			if use_a {
+			if hasBit(newnode.bits[:], parent.prefixlen+1) {
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "insert a-child %s to %s before %s\n", keyStr(key, ln), keyStr(parent.IP(), parent.prefixlen), keyStr(down.IP(), down.prefixlen))
 				}
@@ -796,9 +1130,6 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 		// down and newnode should have new dummy parent under parent
 		node = t.newnode(key[:(ln+7)/8], matched, 1)
 		use_a := hasBit(down.bits[:], matched+1)
-		if use_a == hasBit(newnode.bits[:], matched+1) {
-			panic("tangled branches while creating new intermediate parent")
-		}
 		if use_a {
 			node.a = down
 			node.b = newnode
@@ -842,7 +1173,16 @@ func (t *Trie64) addToNode(node *Node64, key []byte, ln byte, value unsafe.Point
 }
 
 func (rt *Trie64) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
-	exact, node, ct := rt.node.findBestMatch(ip, mask)
+	if checkKey(ip, mask, 64) != nil {
+		// nothing could be stored under invalid key
+		return false, nil, 0, nil
+	}
+	return rt.node.lookup(ip, mask)
+}
+
+// lookup is Get for a subtree starting at node.
+func (node *Node64) lookup(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	exact, node, ct := node.findBestMatch(ip, mask)
 
 	if node != nil && node.dummy == 0 {
 		// dummy=1 means "no match", we will instead look at valid container
@@ -857,38 +1197,88 @@ func (rt *Trie64) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer)
 
 }
 
-func (rt *Trie64) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, false)
-	return set, olval
+// Append adds value unless prefix is already set. It returns ErrPrefixTooLong,
+// ErrKeyTooShort or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie64) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64, error) {
+	return rt.addToNode(rt.node, ip, mask, value, false)
+}
+
+// MustAppend is like Append but panics on error.
+func (rt *Trie64) MustAppend(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64) {
+	set, node, err := rt.Append(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
+}
+
+// Remove deletes prefix and returns value it had. Root could be removed
+// too and dummy nodes left without purpose are dropped along the way.
+// Dropped nodes are reused by later inserts, so pointers to them must not
+// be kept.
+func (rt *Trie64) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 64) != nil {
+		return false, nil
+	}
+	return rt.delNode(ip, mask)
 }
 
-func (rt *Trie64) Remove(ip []byte, mask byte) bool {
-	return rt.node.delChildNode(ip, mask)
+// Set adds or replaces value. It returns ErrPrefixTooLong, ErrKeyTooShort
+// or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie64) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64, error) {
+	return rt.addToNode(rt.node, ip, mask, value, true)
 }
 
-func (rt *Trie64) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, true)
-	return set, olval
+// MustSet is like Set but panics on error.
+func (rt *Trie64) MustSet(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node64) {
+	set, node, err := rt.Set(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
 }
 
-func (rt *Trie64) GetNode(ip []byte, mask byte) (bool, *Node64) {
+// GetNode finds node for prefix adding it (without value) when missing.
+// Note that it changes the tree and needs a write lock if trie is shared.
+func (rt *Trie64) GetNode(ip []byte, mask byte) (bool, *Node64, error) {
+	if err := checkKey(ip, mask, 64); err != nil {
+		return false, nil, err
+	}
 	exact, node, ct := rt.node.findBestMatch(ip, mask)
 	if exact {
-		return node.IsDummy(), node // if node is a dummy it needs to look like "just added"
+		return node.IsDummy(), node, nil // if node is a dummy it needs to look like "just added"
 	}
+	var err error
 	if node != nil {
-		_, node = rt.addToNode(node, ip, mask, nil, false)
+		_, node, err = rt.addToNode(node, ip, mask, nil, false)
 	} else {
 		if ct != nil {
-			_, node = rt.addToNode(ct, ip, mask, nil, false)
+			_, node, err = rt.addToNode(ct, ip, mask, nil, false)
 		} else {
-			_, node = rt.addToNode(rt.node, ip, mask, nil, false)
+			_, node, err = rt.addToNode(rt.node, ip, mask, nil, false)
 		}
 	}
-	return true, node
+	if err != nil {
+		return false, nil, err
+	}
+	return true, node, nil
 
 }
 
+// MustGetNode is like GetNode but panics on error.
+func (rt *Trie64) MustGetNode(ip []byte, mask byte) (bool, *Node64) {
+	added, node, err := rt.GetNode(ip, mask)
+	if err != nil {
+		panic(err)
+	}
+	return added, node
+}
+
+// Validate checks ip/mask against trie width, see ValidatePrefix.
+func (rt *Trie64) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return ValidatePrefix(ip, mask, 64, mode)
+}
+
 func (n *Node64) Data() unsafe.Pointer {
 	return n.data
 }
@@ -897,19 +1287,48 @@ func (n *Node64) IsDummy() bool {
 	return n.dummy != 0
 }
 
+// Assign changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: node does not know its trie, so Len, Stats and snapshots of
+// the trie go out of step. Use Trie64.Assign.
 func (n *Node64) Assign(value unsafe.Pointer) {
 	n.data = value
 	n.dummy = 0
 }
 
+// Strip changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: like Assign it leaves counters of the trie alone, and also
+// dummies it makes are never removed. Use Trie64.Strip.
 func (n *Node64) Strip() {
 	n.data = nil
 	n.dummy = 1
 }
 
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *Trie64) Assign(node *Node64, value unsafe.Pointer) {
+	t.count(node, -1)
+	node.data, node.dummy = value, 0
+	t.count(node, 1)
+}
+
+// Strip drops value of a node that belongs to t. Node holding two branches
+// becomes a dummy, otherwise it is removed along with dummies that are no
+// longer needed, just like Remove does, and must not be used after that.
+func (t *Trie64) Strip(node *Node64) {
+	if node.dummy == 0 {
+		t.delNode(node.IP(), node.prefixlen)
+	}
+}
+
 type Trie128 struct {
-	node  *Node128
-	nodes []Node128
+	node    *Node128
+	nodes   []Node128
+	free    *Node128 // removed nodes linked thru a, reused first
+	freed   int
+	entries int // real nodes
+	dummies int
+	codec   ValueCodec // for snapshots, see SetCodec
 }
 
 type Node128 struct {
@@ -965,6 +1384,11 @@ func (t *Trie128) Root() *Node128 {
 	return t.node
 }
 
+// Width returns number of bits in keys of the trie.
+func (t *Trie128) Width() byte {
+	return 128
+}
+
 func (node *Node128) Bits() byte {
 	return node.prefixlen
 }
@@ -1044,22 +1468,57 @@ func (node *Node128) bitsMatched(key []uint32, ln byte) byte {
 }
 
 func (t *Trie128) newnode(bits []byte, prefixlen, dummy byte) *Node128 {
-	if len(t.nodes) == 0 {
-		t.nodes = make([]Node128, 20) // 20 nodes at once to prepare
+	var node *Node128
+	if t.free != nil {
+		node, t.free = t.free, t.free.a
+		t.freed--
+		*node = Node128{}
+	} else {
+		if len(t.nodes) == 0 {
+			t.nodes = make([]Node128, 20) // 20 nodes at once to prepare
+		}
+
+		idx := len(t.nodes) - 1
+		node = &(t.nodes[idx])
+		t.nodes = t.nodes[:idx]
 	}
 
-	idx := len(t.nodes) - 1
-	node := &(t.nodes[idx])
-	t.nodes = t.nodes[:idx]
+	node.dummy = dummy
+	node.setKey(bits, prefixlen)
+	t.count(node, 1)
+	return node
+}
+
+// count adds n to entries or dummies depending on node kind.
+func (t *Trie128) count(node *Node128, n int) {
+	if node.dummy != 0 {
+		t.dummies += n
+	} else {
+		t.entries += n
+	}
+}
 
-	node.prefixlen, node.dummy = prefixlen, dummy
+// setKey fills node bits from key masked to prefixlen.
+func (node *Node128) setKey(bits []byte, prefixlen byte) {
+	node.prefixlen = prefixlen
 
 	end := (prefixlen + 31) / 32
 	for pos := byte(0); pos < end; pos++ {
 		node.bits[pos] = mkuint32(bits[pos*4:], prefixlen)
 		prefixlen -= 32
 	}
-	return node
+}
+
+// trimBits clears bits past prefixlen.
+func (node *Node128) trimBits() {
+	for pos := range node.bits {
+		switch plen := int(node.prefixlen) - pos*32; {
+		case plen <= 0:
+			node.bits[pos] = 0
+		case plen < 32:
+			node.bits[pos] &= ^(uint32(0xffffffff) >> plen)
+		}
+	}
 }
 
 func (node *Node128) findBestMatch(key []byte, ln byte) (bool, *Node128, *Node128) {
@@ -1093,46 +1552,117 @@ func (node *Node128) findBestMatch(key []byte, ln byte) (bool, *Node128, *Node12
 	return exact, parent, cparent
 }
 
-func (node *Node128) delChildNode(key []byte, ln byte) bool {
-	var parent *Node128
-	for node != nil && node.match(key, ln) {
-		parent = node
-		if hasBit8(key, parent.prefixlen+1) {
-			node = node.a
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.a = nil
-				} else if node.b == nil {
-					// right branch has right side child, trim
-					parent.a = node.a
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+// delNode removes key/ln and returns its value. Dummies left with less
+// than two children on the way to removed node are dropped.
+func (t *Trie128) delNode(key []byte, ln byte) (bool, unsafe.Pointer) {
+	// path keeps links (&t.node, &parent.a, ...) that lead to removed node
+	var path [128 + 2]**Node128
+	depth := 0
+	link := &t.node
+	for node := *link; node != nil && node.prefixlen < ln && node.match(key, ln); node = *link {
+		path[depth] = link
+		depth++
+		if hasBit8(key, node.prefixlen+1) {
+			link = &node.a
 		} else {
-			node = node.b
-			if node.prefixlen == ln {
-				if node.a == nil && node.b == nil {
-					parent.b = nil
-				} else if node.a == nil {
-					// left branch has left side child, trim
-					parent.b = node.b
-				} else {
-					node.data = nil
-					node.dummy = 1
-				}
-				return true
-			}
+			link = &node.b
+		}
+	}
+	node := *link
+	if node == nil || node.prefixlen != ln || node.dummy != 0 || !node.match(key, ln) {
+		return false, nil
+	}
+
+	value := node.data
+	if node.a != nil && node.b != nil {
+		// still needed to hold both branches
+		t.count(node, -1)
+		node.data, node.dummy = nil, 1
+		t.count(node, 1)
+		return true, value
+	}
+	t.unlink(link)
+
+	// parents might be dummies that are not needed anymore
+	for depth > 0 {
+		depth--
+		link = path[depth]
+		if parent := *link; parent.dummy == 0 || (parent.a != nil && parent.b != nil) {
+			break
 		}
+		t.unlink(link)
+	}
+	return true, value
+}
+
+// unlink replaces node having at most one child with that child.
+func (t *Trie128) unlink(link **Node128) {
+	node := *link
+	if node.a != nil {
+		*link = node.a
+	} else {
+		*link = node.b
 	}
-	return false
+	t.release(node)
 }
 
-func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node128) {
-	if ln > 128 {
-		panic("Unable to add prefix longer than 128")
+// release puts node that is no longer in the tree to free list.
+func (t *Trie128) release(node *Node128) {
+	t.count(node, -1)
+	node.a, node.b, node.data = t.free, nil, nil
+	t.free = node
+	t.freed++
+}
+
+// Compact moves all nodes into one freshly allocated array and drops free
+// list and preallocated nodes, so memory held by removed nodes could be
+// returned. Nodes obtained before Compact are no longer part of the trie.
+func (t *Trie128) Compact() {
+	var n int
+	if t.node != nil {
+		t.node.Drill(func(*Node128) { n++ })
+	}
+	arena := make([]Node128, n)
+	t.node, _ = t.node.copyInto(arena, nil)
+	t.nodes, t.free, t.freed = nil, nil, 0
+}
+
+// Clone returns deep copy of the trie packed into new array of nodes.
+// Dummy and real nodes stay as they are. Values are shared when value is
+// nil, otherwise value is called for every real node to get its copy.
+func (t *Trie128) Clone(value func(unsafe.Pointer) unsafe.Pointer) *Trie128 {
+	c := new(Trie128)
+	t.cloneTo(c, value)
+	return c
+}
+
+// cloneTo makes empty c a copy of t.
+func (t *Trie128) cloneTo(c *Trie128, value func(unsafe.Pointer) unsafe.Pointer) {
+	c.entries, c.dummies, c.codec = t.entries, t.dummies, t.codec
+	arena := make([]Node128, t.entries+t.dummies)
+	c.node, _ = t.node.copyInto(arena, value)
+}
+
+// copyInto copies subtree into arena, it returns copy of node and part of
+// arena left unused. Values are passed thru value function unless it is nil.
+func (node *Node128) copyInto(arena []Node128, value func(unsafe.Pointer) unsafe.Pointer) (*Node128, []Node128) {
+	if node == nil {
+		return nil, arena
+	}
+	c := &arena[0]
+	*c = *node
+	if value != nil && c.dummy == 0 {
+		c.data = value(c.data)
+	}
+	arena = arena[1:]
+	c.a, arena = node.a.copyInto(arena, value)
+	c.b, arena = node.b.copyInto(arena, value)
+	return c, arena
+}
+
+func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Pointer, replace bool) (set bool, newnode *Node128, err error) {
+	if err = checkKey(key, ln, 128); err != nil {
+		return
 	}
 
 	set = true
@@ -1152,7 +1682,7 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 	)
 	if exact, node, _ = node.findBestMatch(key, ln); exact {
 		if node.dummy != 0 {
-			node.Assign(value)
+			t.Assign(node, value)
 			if DEBUG != nil {
 				fmt.Fprintf(DEBUG, "setting empty child's %v/%d value\n", key, ln)
 			}
@@ -1166,28 +1696,30 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 				fmt.Fprintf(DEBUG, "hit previously set %v/%d node\n", key, ln)
 			}
 		}
-		return set, node
+		return set, node, nil
 	}
-	newnode = t.newnode(key, ln, 0)
-	newnode.data = value
 	if node != nil {
 		if hasBit8(key, node.prefixlen+1) {
 			if node.a == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.a = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "a-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.a
 			down = node.a
 		} else {
 			if node.b == nil {
+				newnode = t.newnode(key, ln, 0)
+				newnode.data = value
 				node.b = newnode
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "b-child %s for %s\n", keyStr(key, ln), keyStr(node.IP(), node.prefixlen))
 				}
-				return set, newnode
+				return set, newnode, nil
 			}
 			// newnode fits between node and node.b
 			down = node.b
@@ -1199,10 +1731,23 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 
 	parent := node
 	if parent != nil && parent.prefixlen >= ln {
-		panic("parent's prefix could not be larger than key len")
+		return false, nil, fmt.Errorf("%w: parent's prefix could not be larger than key len", ErrCorruptTrie)
 	}
 
-	matched := down.bitsMatched(newnode.bits[:], ln)
+	// check the branch before allocating anything, so nothing leaks on error
+	var probe Node128
+	probe.setKey(key, ln)
+	matched := down.bitsMatched(probe.bits[:], ln)
+	if matched == ln {
+		if parent != nil && hasBit(probe.bits[:], parent.prefixlen+1) != hasBit(down.bits[:], parent.prefixlen+1) {
+			return false, nil, fmt.Errorf("%w: something is wrong with branch that we intend to append to", ErrCorruptTrie)
+		}
+	} else if hasBit(down.bits[:], matched+1) == hasBit(probe.bits[:], matched+1) {
+		return false, nil, fmt.Errorf("%w: tangled branches while creating new intermediate parent", ErrCorruptTrie)
+	}
+
+	newnode = t.newnode(key, ln, 0)
+	newnode.data = value
 
 	// Well. We fit somewhere between parent and down
 	// parent.bits match up to parent.prefixlen          1111111111100000000000
@@ -1217,11 +1762,7 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 			newnode.b = down
 		}
 		if parent != nil {
-			use_a := hasBit(newnode.bits[:], parent.prefixlen+1)
-			if use_a != hasBit(down.bits[:], parent.prefixlen+1) {
-				panic("something is wrong with branch that we intend to append to")
-			}
-			if use_a {
+			if hasBit(newnode.bits[:], parent.prefixlen+1) {
 				if DEBUG != nil {
 					fmt.Fprintf(DEBUG, "insert a-child %s to %s before %s\n", keyStr(key, ln), keyStr(parent.IP(), parent.prefixlen), keyStr(down.IP(), down.prefixlen))
 				}
@@ -1246,9 +1787,6 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 		// down and newnode should have new dummy parent under parent
 		node = t.newnode(key[:(ln+7)/8], matched, 1)
 		use_a := hasBit(down.bits[:], matched+1)
-		if use_a == hasBit(newnode.bits[:], matched+1) {
-			panic("tangled branches while creating new intermediate parent")
-		}
 		if use_a {
 			node.a = down
 			node.b = newnode
@@ -1292,7 +1830,16 @@ func (t *Trie128) addToNode(node *Node128, key []byte, ln byte, value unsafe.Poi
 }
 
 func (rt *Trie128) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
-	exact, node, ct := rt.node.findBestMatch(ip, mask)
+	if checkKey(ip, mask, 128) != nil {
+		// nothing could be stored under invalid key
+		return false, nil, 0, nil
+	}
+	return rt.node.lookup(ip, mask)
+}
+
+// lookup is Get for a subtree starting at node.
+func (node *Node128) lookup(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer) {
+	exact, node, ct := node.findBestMatch(ip, mask)
 
 	if node != nil && node.dummy == 0 {
 		// dummy=1 means "no match", we will instead look at valid container
@@ -1307,36 +1854,86 @@ func (rt *Trie128) Get(ip []byte, mask byte) (bool, []byte, byte, unsafe.Pointer
 
 }
 
-func (rt *Trie128) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, false)
-	return set, olval
+// Append adds value unless prefix is already set. It returns ErrPrefixTooLong,
+// ErrKeyTooShort or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie128) Append(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128, error) {
+	return rt.addToNode(rt.node, ip, mask, value, false)
 }
 
-func (rt *Trie128) Remove(ip []byte, mask byte) bool {
-	return rt.node.delChildNode(ip, mask)
+// MustAppend is like Append but panics on error.
+func (rt *Trie128) MustAppend(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128) {
+	set, node, err := rt.Append(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
+}
+
+// Remove deletes prefix and returns value it had. Root could be removed
+// too and dummy nodes left without purpose are dropped along the way.
+// Dropped nodes are reused by later inserts, so pointers to them must not
+// be kept.
+func (rt *Trie128) Remove(ip []byte, mask byte) (bool, unsafe.Pointer) {
+	if checkKey(ip, mask, 128) != nil {
+		return false, nil
+	}
+	return rt.delNode(ip, mask)
 }
 
-func (rt *Trie128) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128) {
-	set, olval := rt.addToNode(rt.node, ip, mask, value, true)
-	return set, olval
+// Set adds or replaces value. It returns ErrPrefixTooLong, ErrKeyTooShort
+// or ErrCorruptTrie instead of panicking on bad input.
+func (rt *Trie128) Set(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128, error) {
+	return rt.addToNode(rt.node, ip, mask, value, true)
 }
 
-func (rt *Trie128) GetNode(ip []byte, mask byte) (bool, *Node128) {
+// MustSet is like Set but panics on error.
+func (rt *Trie128) MustSet(ip []byte, mask byte, value unsafe.Pointer) (bool, *Node128) {
+	set, node, err := rt.Set(ip, mask, value)
+	if err != nil {
+		panic(err)
+	}
+	return set, node
+}
+
+// GetNode finds node for prefix adding it (without value) when missing.
+// Note that it changes the tree and needs a write lock if trie is shared.
+func (rt *Trie128) GetNode(ip []byte, mask byte) (bool, *Node128, error) {
+	if err := checkKey(ip, mask, 128); err != nil {
+		return false, nil, err
+	}
 	exact, node, ct := rt.node.findBestMatch(ip, mask)
 	if exact {
-		return node.IsDummy(), node // if node is a dummy it needs to look like "just added"
+		return node.IsDummy(), node, nil // if node is a dummy it needs to look like "just added"
 	}
+	var err error
 	if node != nil {
-		_, node = rt.addToNode(node, ip, mask, nil, false)
+		_, node, err = rt.addToNode(node, ip, mask, nil, false)
 	} else {
 		if ct != nil {
-			_, node = rt.addToNode(ct, ip, mask, nil, false)
+			_, node, err = rt.addToNode(ct, ip, mask, nil, false)
 		} else {
-			_, node = rt.addToNode(rt.node, ip, mask, nil, false)
+			_, node, err = rt.addToNode(rt.node, ip, mask, nil, false)
 		}
 	}
-	return true, node
+	if err != nil {
+		return false, nil, err
+	}
+	return true, node, nil
+
+}
 
+// MustGetNode is like GetNode but panics on error.
+func (rt *Trie128) MustGetNode(ip []byte, mask byte) (bool, *Node128) {
+	added, node, err := rt.GetNode(ip, mask)
+	if err != nil {
+		panic(err)
+	}
+	return added, node
+}
+
+// Validate checks ip/mask against trie width, see ValidatePrefix.
+func (rt *Trie128) Validate(ip []byte, mask byte, mode ValidationMode) ([]byte, bool, error) {
+	return ValidatePrefix(ip, mask, 128, mode)
 }
 
 func (n *Node128) Data() unsafe.Pointer {
@@ -1347,12 +1944,36 @@ func (n *Node128) IsDummy() bool {
 	return n.dummy != 0
 }
 
+// Assign changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: node does not know its trie, so Len, Stats and snapshots of
+// the trie go out of step. Use Trie128.Assign.
 func (n *Node128) Assign(value unsafe.Pointer) {
 	n.data = value
 	n.dummy = 0
 }
 
+// Strip changes node in place, it needs a write lock if trie is shared.
+//
+// Deprecated: like Assign it leaves counters of the trie alone, and also
+// dummies it makes are never removed. Use Trie128.Strip.
 func (n *Node128) Strip() {
 	n.data = nil
 	n.dummy = 1
 }
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *Trie128) Assign(node *Node128, value unsafe.Pointer) {
+	t.count(node, -1)
+	node.data, node.dummy = value, 0
+	t.count(node, 1)
+}
+
+// Strip drops value of a node that belongs to t. Node holding two branches
+// becomes a dummy, otherwise it is removed along with dummies that are no
+// longer needed, just like Remove does, and must not be used after that.
+func (t *Trie128) Strip(node *Node128) {
+	if node.dummy == 0 {
+		t.delNode(node.IP(), node.prefixlen)
+	}
+}
@@ -1,12 +1,13 @@
 //+build ignore
 
 // This one generates types to work with prefix trees.
-// Four types are currently generated:
-// tree32
-// tree64
-// tree128
+// Three widths are currently generated from every template:
+// 32
+// 64
+// 128
 //
-// Code in tree.go for tree160 is used as "standard".
+// Code in tree160.go for tree160 is used as "standard", other templates
+// (e.g. typed160.go) are passed with -i and follow the same rules.
 package main
 
 import (
@@ -15,6 +16,8 @@ import (
 	"fmt"
 	"go/format"
 	"go/importer"
+	"go/parser"
+	"go/token"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -26,13 +29,9 @@ var packageHdr = `// *** AUTOGENERATED BY "go generate" ***
 
 package iptrie
 
-import (
-        "fmt"
-        "unsafe"
-)
-
 `
 
+var flagIn = flag.String("i", "tree160.go", "Template to read")
 var flagOut = flag.String("o", "tree_auto.go", "Where to write result")
 var genMAXBITS = []string{"32", "64", "128"}
 
@@ -45,7 +44,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	f, err := os.Open("tree160.go")
+	f, err := os.Open(*flagIn)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "could not read:", err)
 		os.Exit(1)
@@ -63,8 +62,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	// generated code needs same imports as template
+	imports, err := parser.ParseFile(token.NewFileSet(), *flagIn, content, parser.ImportsOnly)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "err imports:", err)
+		os.Exit(1)
+	}
+
 	dst := bytes.NewBuffer(nil)
 	dst.WriteString(packageHdr)
+	if len(imports.Imports) > 0 {
+		dst.WriteString("import (\n")
+		for _, spec := range imports.Imports {
+			if spec.Name != nil {
+				dst.WriteString(spec.Name.Name + " ")
+			}
+			dst.WriteString(spec.Path.Value + "\n")
+		}
+		dst.WriteString(")\n\n")
+	}
 
 	pos := bytes.Index(content, []byte("//go:generate"))
 	content = content[pos:]
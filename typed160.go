@@ -0,0 +1,148 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+import "unsafe"
+
+// Command below marks beginning of template for auto-generated code.
+// DO NOT REMOVE IT!
+
+//go:generate go run ./tree_generate.go -i typed160.go -o typed_auto.go
+
+// TypedTrie160 is a Trie160 that keeps values of type V instead of
+// unsafe.Pointer. Every value lives in its own heap cell referenced from
+// the node, so it stays reachable for as long as the trie holds it.
+// Use Trie160 directly when that extra allocation is not welcome.
+type TypedTrie160[V any] struct {
+	trie Trie160
+}
+
+// TypedNode160 is a Node160 seen through value type V, converting
+// between the two costs nothing.
+type TypedNode160[V any] Node160
+
+// Trie gives access to underlying unsafe trie (e.g. for Drill and Sweep).
+// Pointers stored there are *V.
+func (t *TypedTrie160[V]) Trie() *Trie160 {
+	return &t.trie
+}
+
+// Width returns number of bits in keys of the trie.
+func (t *TypedTrie160[V]) Width() byte {
+	return MAXBITS
+}
+
+func (t *TypedTrie160[V]) Root() *TypedNode160[V] {
+	return (*TypedNode160[V])(t.trie.Root())
+}
+
+func (t *TypedTrie160[V]) Get(ip []byte, mask byte) (bool, []byte, byte, V) {
+	exact, ip, ln, value := t.trie.Get(ip, mask)
+	return exact, ip, ln, unboxValue[V](value)
+}
+
+// Append adds value unless prefix is already set, see Trie160.Append for errors.
+func (t *TypedTrie160[V]) Append(ip []byte, mask byte, value V) (bool, *TypedNode160[V], error) {
+	set, node, err := t.trie.Append(ip, mask, boxValue(value))
+	return set, (*TypedNode160[V])(node), err
+}
+
+// MustAppend is like Append but panics on error.
+func (t *TypedTrie160[V]) MustAppend(ip []byte, mask byte, value V) (bool, *TypedNode160[V]) {
+	set, node := t.trie.MustAppend(ip, mask, boxValue(value))
+	return set, (*TypedNode160[V])(node)
+}
+
+// Set adds or replaces value, see Trie160.Set for errors.
+func (t *TypedTrie160[V]) Set(ip []byte, mask byte, value V) (bool, *TypedNode160[V], error) {
+	set, node, err := t.trie.Set(ip, mask, boxValue(value))
+	return set, (*TypedNode160[V])(node), err
+}
+
+// MustSet is like Set but panics on error.
+func (t *TypedTrie160[V]) MustSet(ip []byte, mask byte, value V) (bool, *TypedNode160[V]) {
+	set, node := t.trie.MustSet(ip, mask, boxValue(value))
+	return set, (*TypedNode160[V])(node)
+}
+
+func (t *TypedTrie160[V]) GetNode(ip []byte, mask byte) (bool, *TypedNode160[V], error) {
+	added, node, err := t.trie.GetNode(ip, mask)
+	return added, (*TypedNode160[V])(node), err
+}
+
+// MustGetNode is like GetNode but panics on error.
+func (t *TypedTrie160[V]) MustGetNode(ip []byte, mask byte) (bool, *TypedNode160[V]) {
+	added, node := t.trie.MustGetNode(ip, mask)
+	return added, (*TypedNode160[V])(node)
+}
+
+// Remove deletes prefix and returns value it had.
+func (t *TypedTrie160[V]) Remove(ip []byte, mask byte) (bool, V) {
+	removed, value := t.trie.Remove(ip, mask)
+	return removed, unboxValue[V](value)
+}
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *TypedTrie160[V]) Assign(node *TypedNode160[V], value V) {
+	t.trie.Assign(node.Node(), boxValue(value))
+}
+
+// Strip drops value of a node that belongs to t, see Trie160.Strip.
+func (t *TypedTrie160[V]) Strip(node *TypedNode160[V]) {
+	t.trie.Strip(node.Node())
+}
+
+// Compact moves nodes into one array, see Trie160.Compact.
+func (t *TypedTrie160[V]) Compact() {
+	t.trie.Compact()
+}
+
+// Clone returns deep copy of the trie. Values are shared when value is
+// nil, otherwise value is called for every entry to get its copy.
+func (t *TypedTrie160[V]) Clone(value func(V) V) *TypedTrie160[V] {
+	var copyValue func(unsafe.Pointer) unsafe.Pointer
+	if value != nil {
+		copyValue = func(p unsafe.Pointer) unsafe.Pointer {
+			return boxValue(value(unboxValue[V](p)))
+		}
+	}
+	c := new(TypedTrie160[V])
+	t.trie.cloneTo(&c.trie, copyValue)
+	return c
+}
+
+// Node returns same node in its unsafe form.
+func (n *TypedNode160[V]) Node() *Node160 {
+	return (*Node160)(n)
+}
+
+func (n *TypedNode160[V]) Bits() byte {
+	return n.prefixlen
+}
+
+func (n *TypedNode160[V]) IP() []byte {
+	return n.Node().IP()
+}
+
+// Data returns zero V for dummy nodes and nodes added by GetNode.
+func (n *TypedNode160[V]) Data() V {
+	return unboxValue[V](n.data)
+}
+
+func (n *TypedNode160[V]) IsDummy() bool {
+	return n.dummy != 0
+}
+
+// Assign changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie160.Assign.
+func (n *TypedNode160[V]) Assign(value V) {
+	n.data, n.dummy = boxValue(value), 0
+}
+
+// Strip changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie160.Strip.
+func (n *TypedNode160[V]) Strip() {
+	n.data, n.dummy = nil, 1
+}
@@ -0,0 +1,421 @@
+// *** AUTOGENERATED BY "go generate" ***
+
+package iptrie
+
+import (
+	"unsafe"
+)
+
+// TypedTrie32 is a Trie32 that keeps values of type V instead of
+// unsafe.Pointer. Every value lives in its own heap cell referenced from
+// the node, so it stays reachable for as long as the trie holds it.
+// Use Trie32 directly when that extra allocation is not welcome.
+type TypedTrie32[V any] struct {
+	trie Trie32
+}
+
+// TypedNode32 is a Node32 seen through value type V, converting
+// between the two costs nothing.
+type TypedNode32[V any] Node32
+
+// Trie gives access to underlying unsafe trie (e.g. for Drill and Sweep).
+// Pointers stored there are *V.
+func (t *TypedTrie32[V]) Trie() *Trie32 {
+	return &t.trie
+}
+
+// Width returns number of bits in keys of the trie.
+func (t *TypedTrie32[V]) Width() byte {
+	return 32
+}
+
+func (t *TypedTrie32[V]) Root() *TypedNode32[V] {
+	return (*TypedNode32[V])(t.trie.Root())
+}
+
+func (t *TypedTrie32[V]) Get(ip []byte, mask byte) (bool, []byte, byte, V) {
+	exact, ip, ln, value := t.trie.Get(ip, mask)
+	return exact, ip, ln, unboxValue[V](value)
+}
+
+// Append adds value unless prefix is already set, see Trie32.Append for errors.
+func (t *TypedTrie32[V]) Append(ip []byte, mask byte, value V) (bool, *TypedNode32[V], error) {
+	set, node, err := t.trie.Append(ip, mask, boxValue(value))
+	return set, (*TypedNode32[V])(node), err
+}
+
+// MustAppend is like Append but panics on error.
+func (t *TypedTrie32[V]) MustAppend(ip []byte, mask byte, value V) (bool, *TypedNode32[V]) {
+	set, node := t.trie.MustAppend(ip, mask, boxValue(value))
+	return set, (*TypedNode32[V])(node)
+}
+
+// Set adds or replaces value, see Trie32.Set for errors.
+func (t *TypedTrie32[V]) Set(ip []byte, mask byte, value V) (bool, *TypedNode32[V], error) {
+	set, node, err := t.trie.Set(ip, mask, boxValue(value))
+	return set, (*TypedNode32[V])(node), err
+}
+
+// MustSet is like Set but panics on error.
+func (t *TypedTrie32[V]) MustSet(ip []byte, mask byte, value V) (bool, *TypedNode32[V]) {
+	set, node := t.trie.MustSet(ip, mask, boxValue(value))
+	return set, (*TypedNode32[V])(node)
+}
+
+func (t *TypedTrie32[V]) GetNode(ip []byte, mask byte) (bool, *TypedNode32[V], error) {
+	added, node, err := t.trie.GetNode(ip, mask)
+	return added, (*TypedNode32[V])(node), err
+}
+
+// MustGetNode is like GetNode but panics on error.
+func (t *TypedTrie32[V]) MustGetNode(ip []byte, mask byte) (bool, *TypedNode32[V]) {
+	added, node := t.trie.MustGetNode(ip, mask)
+	return added, (*TypedNode32[V])(node)
+}
+
+// Remove deletes prefix and returns value it had.
+func (t *TypedTrie32[V]) Remove(ip []byte, mask byte) (bool, V) {
+	removed, value := t.trie.Remove(ip, mask)
+	return removed, unboxValue[V](value)
+}
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *TypedTrie32[V]) Assign(node *TypedNode32[V], value V) {
+	t.trie.Assign(node.Node(), boxValue(value))
+}
+
+// Strip drops value of a node that belongs to t, see Trie32.Strip.
+func (t *TypedTrie32[V]) Strip(node *TypedNode32[V]) {
+	t.trie.Strip(node.Node())
+}
+
+// Compact moves nodes into one array, see Trie32.Compact.
+func (t *TypedTrie32[V]) Compact() {
+	t.trie.Compact()
+}
+
+// Clone returns deep copy of the trie. Values are shared when value is
+// nil, otherwise value is called for every entry to get its copy.
+func (t *TypedTrie32[V]) Clone(value func(V) V) *TypedTrie32[V] {
+	var copyValue func(unsafe.Pointer) unsafe.Pointer
+	if value != nil {
+		copyValue = func(p unsafe.Pointer) unsafe.Pointer {
+			return boxValue(value(unboxValue[V](p)))
+		}
+	}
+	c := new(TypedTrie32[V])
+	t.trie.cloneTo(&c.trie, copyValue)
+	return c
+}
+
+// Node returns same node in its unsafe form.
+func (n *TypedNode32[V]) Node() *Node32 {
+	return (*Node32)(n)
+}
+
+func (n *TypedNode32[V]) Bits() byte {
+	return n.prefixlen
+}
+
+func (n *TypedNode32[V]) IP() []byte {
+	return n.Node().IP()
+}
+
+// Data returns zero V for dummy nodes and nodes added by GetNode.
+func (n *TypedNode32[V]) Data() V {
+	return unboxValue[V](n.data)
+}
+
+func (n *TypedNode32[V]) IsDummy() bool {
+	return n.dummy != 0
+}
+
+// Assign changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie32.Assign.
+func (n *TypedNode32[V]) Assign(value V) {
+	n.data, n.dummy = boxValue(value), 0
+}
+
+// Strip changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie32.Strip.
+func (n *TypedNode32[V]) Strip() {
+	n.data, n.dummy = nil, 1
+}
+
+// TypedTrie64 is a Trie64 that keeps values of type V instead of
+// unsafe.Pointer. Every value lives in its own heap cell referenced from
+// the node, so it stays reachable for as long as the trie holds it.
+// Use Trie64 directly when that extra allocation is not welcome.
+type TypedTrie64[V any] struct {
+	trie Trie64
+}
+
+// TypedNode64 is a Node64 seen through value type V, converting
+// between the two costs nothing.
+type TypedNode64[V any] Node64
+
+// Trie gives access to underlying unsafe trie (e.g. for Drill and Sweep).
+// Pointers stored there are *V.
+func (t *TypedTrie64[V]) Trie() *Trie64 {
+	return &t.trie
+}
+
+// Width returns number of bits in keys of the trie.
+func (t *TypedTrie64[V]) Width() byte {
+	return 64
+}
+
+func (t *TypedTrie64[V]) Root() *TypedNode64[V] {
+	return (*TypedNode64[V])(t.trie.Root())
+}
+
+func (t *TypedTrie64[V]) Get(ip []byte, mask byte) (bool, []byte, byte, V) {
+	exact, ip, ln, value := t.trie.Get(ip, mask)
+	return exact, ip, ln, unboxValue[V](value)
+}
+
+// Append adds value unless prefix is already set, see Trie64.Append for errors.
+func (t *TypedTrie64[V]) Append(ip []byte, mask byte, value V) (bool, *TypedNode64[V], error) {
+	set, node, err := t.trie.Append(ip, mask, boxValue(value))
+	return set, (*TypedNode64[V])(node), err
+}
+
+// MustAppend is like Append but panics on error.
+func (t *TypedTrie64[V]) MustAppend(ip []byte, mask byte, value V) (bool, *TypedNode64[V]) {
+	set, node := t.trie.MustAppend(ip, mask, boxValue(value))
+	return set, (*TypedNode64[V])(node)
+}
+
+// Set adds or replaces value, see Trie64.Set for errors.
+func (t *TypedTrie64[V]) Set(ip []byte, mask byte, value V) (bool, *TypedNode64[V], error) {
+	set, node, err := t.trie.Set(ip, mask, boxValue(value))
+	return set, (*TypedNode64[V])(node), err
+}
+
+// MustSet is like Set but panics on error.
+func (t *TypedTrie64[V]) MustSet(ip []byte, mask byte, value V) (bool, *TypedNode64[V]) {
+	set, node := t.trie.MustSet(ip, mask, boxValue(value))
+	return set, (*TypedNode64[V])(node)
+}
+
+func (t *TypedTrie64[V]) GetNode(ip []byte, mask byte) (bool, *TypedNode64[V], error) {
+	added, node, err := t.trie.GetNode(ip, mask)
+	return added, (*TypedNode64[V])(node), err
+}
+
+// MustGetNode is like GetNode but panics on error.
+func (t *TypedTrie64[V]) MustGetNode(ip []byte, mask byte) (bool, *TypedNode64[V]) {
+	added, node := t.trie.MustGetNode(ip, mask)
+	return added, (*TypedNode64[V])(node)
+}
+
+// Remove deletes prefix and returns value it had.
+func (t *TypedTrie64[V]) Remove(ip []byte, mask byte) (bool, V) {
+	removed, value := t.trie.Remove(ip, mask)
+	return removed, unboxValue[V](value)
+}
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *TypedTrie64[V]) Assign(node *TypedNode64[V], value V) {
+	t.trie.Assign(node.Node(), boxValue(value))
+}
+
+// Strip drops value of a node that belongs to t, see Trie64.Strip.
+func (t *TypedTrie64[V]) Strip(node *TypedNode64[V]) {
+	t.trie.Strip(node.Node())
+}
+
+// Compact moves nodes into one array, see Trie64.Compact.
+func (t *TypedTrie64[V]) Compact() {
+	t.trie.Compact()
+}
+
+// Clone returns deep copy of the trie. Values are shared when value is
+// nil, otherwise value is called for every entry to get its copy.
+func (t *TypedTrie64[V]) Clone(value func(V) V) *TypedTrie64[V] {
+	var copyValue func(unsafe.Pointer) unsafe.Pointer
+	if value != nil {
+		copyValue = func(p unsafe.Pointer) unsafe.Pointer {
+			return boxValue(value(unboxValue[V](p)))
+		}
+	}
+	c := new(TypedTrie64[V])
+	t.trie.cloneTo(&c.trie, copyValue)
+	return c
+}
+
+// Node returns same node in its unsafe form.
+func (n *TypedNode64[V]) Node() *Node64 {
+	return (*Node64)(n)
+}
+
+func (n *TypedNode64[V]) Bits() byte {
+	return n.prefixlen
+}
+
+func (n *TypedNode64[V]) IP() []byte {
+	return n.Node().IP()
+}
+
+// Data returns zero V for dummy nodes and nodes added by GetNode.
+func (n *TypedNode64[V]) Data() V {
+	return unboxValue[V](n.data)
+}
+
+func (n *TypedNode64[V]) IsDummy() bool {
+	return n.dummy != 0
+}
+
+// Assign changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie64.Assign.
+func (n *TypedNode64[V]) Assign(value V) {
+	n.data, n.dummy = boxValue(value), 0
+}
+
+// Strip changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie64.Strip.
+func (n *TypedNode64[V]) Strip() {
+	n.data, n.dummy = nil, 1
+}
+
+// TypedTrie128 is a Trie128 that keeps values of type V instead of
+// unsafe.Pointer. Every value lives in its own heap cell referenced from
+// the node, so it stays reachable for as long as the trie holds it.
+// Use Trie128 directly when that extra allocation is not welcome.
+type TypedTrie128[V any] struct {
+	trie Trie128
+}
+
+// TypedNode128 is a Node128 seen through value type V, converting
+// between the two costs nothing.
+type TypedNode128[V any] Node128
+
+// Trie gives access to underlying unsafe trie (e.g. for Drill and Sweep).
+// Pointers stored there are *V.
+func (t *TypedTrie128[V]) Trie() *Trie128 {
+	return &t.trie
+}
+
+// Width returns number of bits in keys of the trie.
+func (t *TypedTrie128[V]) Width() byte {
+	return 128
+}
+
+func (t *TypedTrie128[V]) Root() *TypedNode128[V] {
+	return (*TypedNode128[V])(t.trie.Root())
+}
+
+func (t *TypedTrie128[V]) Get(ip []byte, mask byte) (bool, []byte, byte, V) {
+	exact, ip, ln, value := t.trie.Get(ip, mask)
+	return exact, ip, ln, unboxValue[V](value)
+}
+
+// Append adds value unless prefix is already set, see Trie128.Append for errors.
+func (t *TypedTrie128[V]) Append(ip []byte, mask byte, value V) (bool, *TypedNode128[V], error) {
+	set, node, err := t.trie.Append(ip, mask, boxValue(value))
+	return set, (*TypedNode128[V])(node), err
+}
+
+// MustAppend is like Append but panics on error.
+func (t *TypedTrie128[V]) MustAppend(ip []byte, mask byte, value V) (bool, *TypedNode128[V]) {
+	set, node := t.trie.MustAppend(ip, mask, boxValue(value))
+	return set, (*TypedNode128[V])(node)
+}
+
+// Set adds or replaces value, see Trie128.Set for errors.
+func (t *TypedTrie128[V]) Set(ip []byte, mask byte, value V) (bool, *TypedNode128[V], error) {
+	set, node, err := t.trie.Set(ip, mask, boxValue(value))
+	return set, (*TypedNode128[V])(node), err
+}
+
+// MustSet is like Set but panics on error.
+func (t *TypedTrie128[V]) MustSet(ip []byte, mask byte, value V) (bool, *TypedNode128[V]) {
+	set, node := t.trie.MustSet(ip, mask, boxValue(value))
+	return set, (*TypedNode128[V])(node)
+}
+
+func (t *TypedTrie128[V]) GetNode(ip []byte, mask byte) (bool, *TypedNode128[V], error) {
+	added, node, err := t.trie.GetNode(ip, mask)
+	return added, (*TypedNode128[V])(node), err
+}
+
+// MustGetNode is like GetNode but panics on error.
+func (t *TypedTrie128[V]) MustGetNode(ip []byte, mask byte) (bool, *TypedNode128[V]) {
+	added, node := t.trie.MustGetNode(ip, mask)
+	return added, (*TypedNode128[V])(node)
+}
+
+// Remove deletes prefix and returns value it had.
+func (t *TypedTrie128[V]) Remove(ip []byte, mask byte) (bool, V) {
+	removed, value := t.trie.Remove(ip, mask)
+	return removed, unboxValue[V](value)
+}
+
+// Assign sets value of a node that belongs to t and keeps counters of t.
+func (t *TypedTrie128[V]) Assign(node *TypedNode128[V], value V) {
+	t.trie.Assign(node.Node(), boxValue(value))
+}
+
+// Strip drops value of a node that belongs to t, see Trie128.Strip.
+func (t *TypedTrie128[V]) Strip(node *TypedNode128[V]) {
+	t.trie.Strip(node.Node())
+}
+
+// Compact moves nodes into one array, see Trie128.Compact.
+func (t *TypedTrie128[V]) Compact() {
+	t.trie.Compact()
+}
+
+// Clone returns deep copy of the trie. Values are shared when value is
+// nil, otherwise value is called for every entry to get its copy.
+func (t *TypedTrie128[V]) Clone(value func(V) V) *TypedTrie128[V] {
+	var copyValue func(unsafe.Pointer) unsafe.Pointer
+	if value != nil {
+		copyValue = func(p unsafe.Pointer) unsafe.Pointer {
+			return boxValue(value(unboxValue[V](p)))
+		}
+	}
+	c := new(TypedTrie128[V])
+	t.trie.cloneTo(&c.trie, copyValue)
+	return c
+}
+
+// Node returns same node in its unsafe form.
+func (n *TypedNode128[V]) Node() *Node128 {
+	return (*Node128)(n)
+}
+
+func (n *TypedNode128[V]) Bits() byte {
+	return n.prefixlen
+}
+
+func (n *TypedNode128[V]) IP() []byte {
+	return n.Node().IP()
+}
+
+// Data returns zero V for dummy nodes and nodes added by GetNode.
+func (n *TypedNode128[V]) Data() V {
+	return unboxValue[V](n.data)
+}
+
+func (n *TypedNode128[V]) IsDummy() bool {
+	return n.dummy != 0
+}
+
+// Assign changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie128.Assign.
+func (n *TypedNode128[V]) Assign(value V) {
+	n.data, n.dummy = boxValue(value), 0
+}
+
+// Strip changes node in place.
+//
+// Deprecated: counters of the trie are not updated, use TypedTrie128.Strip.
+func (n *TypedNode128[V]) Strip() {
+	n.data, n.dummy = nil, 1
+}
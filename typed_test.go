@@ -0,0 +1,67 @@
+package iptrie
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+func TestTypedTrie(t *testing.T) {
+	var T = new(TypedTrie32[string])
+	if set, _, err := T.Set([]byte{1, 2, 3, 0}, 24, "a"); !set || err != nil {
+		t.Error("Unable to set 1.2.3.0/24")
+	}
+	if set, _ := T.MustAppend([]byte{1, 2, 3, 0}, 24, "b"); set {
+		t.Error("Should not be possible to replace with append!")
+	}
+	T.Set([]byte{1, 2, 0, 0}, 16, "c")
+
+	exact, ip, ln, value := T.Get([]byte{1, 2, 3, 5}, 32)
+	if exact || ln != 24 || !bytes.Equal(ip, []byte{1, 2, 3, 0}) || value != "a" {
+		t.Errorf("Expected to find 1.2.3/24=a but got: %v/%d=%q", ip, ln, value)
+	}
+	_, _, ln, value = T.Get([]byte{1, 2, 4, 5}, 32)
+	if ln != 16 || value != "c" {
+		t.Errorf("Expected to find 1.2/16=c but got: /%d=%q", ln, value)
+	}
+	if _, _, _, value = T.Get([]byte{2, 0, 0, 0}, 8); value != "" {
+		t.Errorf("Expected zero value on miss but got %q", value)
+	}
+
+	added, node, _ := T.GetNode([]byte{1, 2, 5, 0}, 24)
+	if !added || node.Data() != "" {
+		t.Error("GetNode should add node without value")
+	}
+	T.Assign(node, "d")
+	if _, _, _, value = T.Get([]byte{1, 2, 5, 1}, 32); value != "d" {
+		t.Errorf("Expected assigned value d but got %q", value)
+	}
+	T.Strip(node)
+	if _, _, ln, value := T.Get([]byte{1, 2, 5, 1}, 32); ln != 16 || value != "c" {
+		t.Errorf("Expected 1.2/16=c after Strip but got /%d=%q", ln, value)
+	}
+	if ok, value := T.Remove([]byte{1, 2, 3, 0}, 24); !ok || value != "a" {
+		t.Error("Unable to remove 1.2.3.0/24")
+	}
+	if _, _, _, value = T.Get([]byte{1, 2, 3, 5}, 32); value != "c" {
+		t.Errorf("Expected 1.2/16=c after removal but got %q", value)
+	}
+}
+
+func TestTypedTrieKeepsValues(t *testing.T) {
+	type payload struct {
+		asn  uint32
+		name []byte
+	}
+	var T = new(TypedTrie128[*payload])
+	for i := 0; i < 100; i++ {
+		T.Set([]byte{0x20, 1, 0xd, 0xb8, 0, byte(i)}, 48, &payload{uint32(i), make([]byte, 1024)})
+	}
+	runtime.GC()
+	for i := 0; i < 100; i++ {
+		_, _, _, value := T.Get([]byte{0x20, 1, 0xd, 0xb8, 0, byte(i), 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, 128)
+		if value == nil || value.asn != uint32(i) || len(value.name) != 1024 {
+			t.Errorf("Value for %d was lost: %v", i, value)
+		}
+	}
+}
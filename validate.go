@@ -0,0 +1,48 @@
+package iptrie
+
+// Copyright (c) 2016 Alex Sergeyev. All rights reserved. See LICENSE file for terms of use.
+
+// ValidationMode tells ValidatePrefix what to do with bits set past the mask.
+type ValidationMode byte
+
+const (
+	// Strict rejects prefixes like 1.2.3.4/24 with ErrHostBits.
+	Strict ValidationMode = iota
+	// Canonicalize turns 1.2.3.4/24 into 1.2.3.0/24 and reports it did so.
+	Canonicalize
+)
+
+// ValidatePrefix checks that key/ln could be stored in a trie of width bits.
+// It returns key to use (a masked copy if host bits had to be cleared) and
+// whether any host bits were cleared. Tries mask keys on their own, this
+// is for callers who want to know that input was not a proper prefix.
+func ValidatePrefix(key []byte, ln, width byte, mode ValidationMode) ([]byte, bool, error) {
+	if err := checkKey(key, ln, width); err != nil {
+		return nil, false, err
+	}
+	if len(key) > (int(width)+7)/8 {
+		return nil, false, ErrKeyTooLong
+	}
+
+	full := int(ln) / 8
+	dirty := false
+	if rem := ln % 8; rem != 0 && key[full]&(0xff>>rem) != 0 {
+		dirty = true
+	}
+	for i := (int(ln) + 7) / 8; i < len(key) && !dirty; i++ {
+		dirty = key[i] != 0
+	}
+	if !dirty {
+		return key, false, nil
+	}
+	if mode == Strict {
+		return nil, false, ErrHostBits
+	}
+
+	canon := make([]byte, len(key))
+	copy(canon, key[:full])
+	if rem := ln % 8; rem != 0 {
+		canon[full] = key[full] &^ (0xff >> rem)
+	}
+	return canon, true, nil
+}
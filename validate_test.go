@@ -0,0 +1,47 @@
+package iptrie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestValidatePrefix(t *testing.T) {
+	var tests = []struct {
+		key    []byte
+		ln     byte
+		mode   ValidationMode
+		canon  []byte
+		masked bool
+		err    error
+	}{
+		{[]byte{1, 2, 3, 0}, 24, Strict, []byte{1, 2, 3, 0}, false, nil},
+		{[]byte{1, 2, 3}, 24, Strict, []byte{1, 2, 3}, false, nil},
+		{[]byte{1, 2, 3, 4}, 24, Strict, nil, false, ErrHostBits},
+		{[]byte{1, 2, 3, 4}, 24, Canonicalize, []byte{1, 2, 3, 0}, true, nil},
+		{[]byte{1, 2, 3, 4}, 22, Canonicalize, []byte{1, 2, 0, 0}, true, nil},
+		{[]byte{1, 2, 255, 0}, 17, Canonicalize, []byte{1, 2, 128, 0}, true, nil},
+		{[]byte{1, 2, 128, 0}, 17, Strict, []byte{1, 2, 128, 0}, false, nil},
+		{[]byte{1, 2}, 24, Canonicalize, nil, false, ErrKeyTooShort},
+		{[]byte{1, 2, 3, 4}, 33, Canonicalize, nil, false, ErrPrefixTooLong},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 1, 2, 3, 4}, 32, Canonicalize, nil, false, ErrKeyTooLong},
+		{nil, 0, Strict, nil, false, nil},
+	}
+	var T = new(Trie32)
+	for _, tst := range tests {
+		canon, masked, err := T.Validate(tst.key, tst.ln, tst.mode)
+		if err != tst.err || masked != tst.masked || !bytes.Equal(canon, tst.canon) {
+			t.Errorf("%v/%d (mode %d): expected %v %t %v, got %v %t %v", tst.key, tst.ln, tst.mode, tst.canon, tst.masked, tst.err, canon, masked, err)
+		}
+	}
+
+	// same key is fine for wider trie
+	if _, _, err := new(Trie128).Validate(tests[9].key, 128, Strict); err != nil {
+		t.Error("16 byte key should be valid for 128 bit trie, got", err)
+	}
+	// input is never modified
+	key := []byte{10, 1, 2, 3}
+	ValidatePrefix(key, 8, 32, Canonicalize)
+	if !bytes.Equal(key, []byte{10, 1, 2, 3}) {
+		t.Error("Canonicalize modified its input")
+	}
+}